@@ -22,6 +22,7 @@ import (
 	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
 	libp2p "github.com/ipfs/go-ipfs/core/node/libp2p"
 	nodeMount "github.com/ipfs/go-ipfs/fuse/node"
+	plugin "github.com/ipfs/go-ipfs/plugin"
 	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
 	migrate "github.com/ipfs/go-ipfs/repo/fsrepo/migrations"
 
@@ -258,11 +259,17 @@ func daemonFunc(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment
 
 		if !domigrate {
 			fmt.Println("Not running migrations of fs-repo now.")
-			fmt.Println("Please get fs-repo-migrations from https://dist.ipfs.io")
+			fmt.Println("Please run 'ipfs repo migrate', or for repos older than version")
+			fmt.Printf("%d, get fs-repo-migrations from https://dist.ipfs.io\n", migrate.FirstBuiltinVersion)
 			return fmt.Errorf("fs-repo requires migration")
 		}
 
-		err = migrate.RunMigration(fsrepo.RepoVersion)
+		err = fsrepo.Migrate(cctx.ConfigRoot, fsrepo.RepoVersion, migrate.RunMigrations(os.Stdout))
+		if _, ok := err.(*migrate.NoMigrationError); ok {
+			fmt.Printf("  => %s.\n", err)
+			fmt.Println("  => Without network access, install fs-repo-migrations in the PATH.")
+			err = migrate.RunMigration(fsrepo.RepoVersion)
+		}
 		if err != nil {
 			fmt.Println("The migrations of fs-repo failed:")
 			fmt.Printf("  %s\n", err)
@@ -329,7 +336,12 @@ func daemonFunc(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment
 	case routingOptionNoneKwd:
 		ncfg.Routing = libp2p.NilRouterOption
 	default:
-		return fmt.Errorf("unrecognized routing option: %s", routingOption)
+		// routing options added by plugins
+		opt, ok := libp2p.NamedRoutingOption(routingOption)
+		if !ok {
+			return fmt.Errorf("unrecognized routing option: %s", routingOption)
+		}
+		ncfg.Routing = opt
 	}
 
 	node, err := core.NewNode(req.Context, ncfg)
@@ -494,6 +506,8 @@ func serveHTTPApi(req *cmds.Request, cctx *oldcmds.Context) (<-chan error, error
 		opts = append(opts, corehttp.RedirectOption("", cfg.Gateway.RootRedirect))
 	}
 
+	opts = append(opts, corehttp.PluginsOption(cctx.Plugins.HTTPPlugins(), plugin.HTTPAPI))
+
 	node, err := cctx.ConstructNode()
 	if err != nil {
 		return nil, fmt.Errorf("serveHTTPApi: ConstructNode() failed: %s", err)
@@ -608,6 +622,8 @@ func serveHTTPGateway(req *cmds.Request, cctx *oldcmds.Context) (<-chan error, e
 		opts = append(opts, corehttp.RedirectOption("", cfg.Gateway.RootRedirect))
 	}
 
+	opts = append(opts, corehttp.PluginsOption(cctx.Plugins.HTTPPlugins(), plugin.HTTPGateway))
+
 	node, err := cctx.ConstructNode()
 	if err != nil {
 		return nil, fmt.Errorf("serveHTTPGateway: ConstructNode() failed: %s", err)
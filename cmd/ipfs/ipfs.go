@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	commands "github.com/ipfs/go-ipfs/core/commands"
+	loader "github.com/ipfs/go-ipfs/plugin/loader"
 
 	cmds "github.com/ipfs/go-ipfs-cmds"
 )
@@ -39,6 +40,19 @@ func init() {
 	}
 }
 
+// addPluginCommands adds the commands of the plugins to the roots of the HTTP
+// API and to the CLI root.
+func addPluginCommands(plugins *loader.PluginLoader) error {
+	pluginCommands := plugins.Commands()
+	if err := commands.AddPluginCommands(pluginCommands, localCommands); err != nil {
+		return err
+	}
+	for name, c := range pluginCommands {
+		Root.Subcommands[name] = c.Command
+	}
+	return nil
+}
+
 // NB: when necessary, properties are described using negatives in order to
 // provide desirable defaults
 type cmdDetails struct {
@@ -84,13 +98,16 @@ func (d *cmdDetails) usesRepo() bool          { return !d.doesNotUseRepo }
 // properties so that other code can make decisions about whether to invoke a
 // command or return an error to the user.
 var cmdDetailsMap = map[string]cmdDetails{
-	"init":        {doesNotUseConfigAsInput: true, cannotRunOnDaemon: true, doesNotUseRepo: true},
-	"daemon":      {doesNotUseConfigAsInput: true, cannotRunOnDaemon: true},
-	"commands":    {doesNotUseRepo: true},
-	"version":     {doesNotUseConfigAsInput: true, doesNotUseRepo: true}, // must be permitted to run before init
-	"log":         {cannotRunOnClient: true},
-	"diag/cmds":   {cannotRunOnClient: true},
-	"repo/fsck":   {cannotRunOnDaemon: true},
-	"config/edit": {cannotRunOnDaemon: true, doesNotUseRepo: true},
-	"cid":         {doesNotUseRepo: true},
+	"init":         {doesNotUseConfigAsInput: true, cannotRunOnDaemon: true, doesNotUseRepo: true},
+	"daemon":       {doesNotUseConfigAsInput: true, cannotRunOnDaemon: true},
+	"commands":     {doesNotUseRepo: true},
+	"version":      {doesNotUseConfigAsInput: true, doesNotUseRepo: true}, // must be permitted to run before init
+	"log":          {cannotRunOnClient: true},
+	"diag/cmds":    {cannotRunOnClient: true},
+	"repo/fsck":    {cannotRunOnDaemon: true},
+	"repo/convert": {cannotRunOnDaemon: true, doesNotUseRepo: true},
+	"repo/restore": {cannotRunOnDaemon: true, doesNotUseRepo: true},
+	"repo/migrate": {cannotRunOnDaemon: true, doesNotUseRepo: true},
+	"config/edit":  {cannotRunOnDaemon: true, doesNotUseRepo: true},
+	"cid":          {doesNotUseRepo: true},
 }
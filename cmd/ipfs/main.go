@@ -8,6 +8,7 @@ import (
 	"math/rand"
 	"os"
 	"path/filepath"
+	"reflect"
 	"runtime/pprof"
 	"strings"
 	"time"
@@ -48,7 +49,7 @@ const (
 func loadPlugins(repoPath string) (*loader.PluginLoader, error) {
 	pluginpath := filepath.Join(repoPath, "plugins")
 
-	plugins, err := loader.NewPluginLoader()
+	plugins, err := loader.NewRepoPluginLoader(repoPath)
 	if err != nil {
 		return nil, fmt.Errorf("error loading preloaded plugins: %s", err)
 	}
@@ -74,6 +75,24 @@ func loadPlugins(repoPath string) (*loader.PluginLoader, error) {
 	return plugins, nil
 }
 
+// loadPluginsFromArgs loads the plugins of the repo selected by the unparsed
+// command line args, and adds their commands to the command roots.
+func loadPluginsFromArgs(ctx context.Context, args []string) (*loader.PluginLoader, error) {
+	repoPath, err := repoPathFromArgs(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+
+	plugins, err := loadPlugins(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := addPluginCommands(plugins); err != nil {
+		return nil, err
+	}
+	return plugins, nil
+}
+
 // main roadmap:
 // - parse the commandline to get a cmdInvocation
 // - if user requests help, print it and exit.
@@ -131,6 +150,11 @@ func mainRet() int {
 	// so we need to make sure it's stable
 	os.Args[0] = "ipfs"
 
+	// Plugins may add commands, so they are loaded before the command line is
+	// parsed. Failures are reported once the command builds its environment,
+	// so that commands like 'ipfs --help' still run.
+	plugins, pluginErr := loadPluginsFromArgs(ctx, os.Args[1:])
+
 	buildEnv := func(ctx context.Context, req *cmds.Request) (cmds.Environment, error) {
 		checkDebug(req)
 		repoPath, err := getRepoPath(req)
@@ -139,9 +163,8 @@ func mainRet() int {
 		}
 		log.Debugf("config path is %s", repoPath)
 
-		plugins, err := loadPlugins(repoPath)
-		if err != nil {
-			return nil, err
+		if pluginErr != nil {
+			return nil, pluginErr
 		}
 
 		// this sets up the function that will initialize the node
@@ -325,6 +348,72 @@ func getRepoPath(req *cmds.Request) (string, error) {
 	return repoPath, nil
 }
 
+// repoPathFromArgs is getRepoPath for command line args that weren't parsed
+// yet, as the plugins are loaded before the command line is parsed.
+func repoPathFromArgs(ctx context.Context, args []string) (string, error) {
+	req, err := cli.Parse(ctx, args, nil, Root)
+	if err == nil {
+		if req.Files != nil {
+			req.Files.Close()
+		}
+		return getRepoPath(req)
+	}
+
+	// The args may run a command of a plugin, unknown to the parser until
+	// the plugins are loaded.
+	return globalRepoPath(args)
+}
+
+// globalRepoPath looks for the config option among the options of the root
+// command in args, following the syntax of the cli parser: options may appear
+// anywhere before "--", and short options may be grouped, the last one of a
+// group taking its value after "=" or from the next arg. The options of other
+// commands are assumed not to take a value.
+func globalRepoPath(args []string) (string, error) {
+	opts := make(map[string]cmds.Option)
+	for _, opt := range Root.Options {
+		for _, name := range opt.Names() {
+			opts[name] = opt
+		}
+	}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			break
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			continue
+		}
+
+		name := arg[1:]
+		var value string
+		hasValue := false
+		if eq := strings.IndexByte(name, '='); eq >= 0 {
+			name, value, hasValue = name[:eq], name[eq+1:], true
+		}
+		if strings.HasPrefix(name, "-") {
+			name = name[1:]
+		} else if name != "" {
+			// Only the last option of a group may take a value.
+			name = name[len(name)-1:]
+		}
+
+		opt, ok := opts[name]
+		if !ok {
+			continue
+		}
+		if !hasValue && opt.Type() != reflect.Bool && i+1 < len(args) {
+			i++
+			value = args[i]
+		}
+		if opt.Name() == corecmds.ConfigOption && value != "" {
+			return value, nil
+		}
+	}
+	return fsrepo.BestKnownPath()
+}
+
 func loadConfig(path string) (*config.Config, error) {
 	return fsrepo.ConfigAt(path)
 }
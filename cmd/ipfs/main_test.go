@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/go-ipfs-cmds/cli"
+)
+
+func TestRepoPathFromArgs(t *testing.T) {
+	cmdlines := [][]string{
+		{"id"},
+		{"-c", "/repo", "id"},
+		{"--config", "/repo", "id"},
+		{"--config=/repo", "id"},
+		{"-c=/repo", "id"},
+		{"id", "-c", "/repo"},
+		{"-Dc", "/repo", "id"},
+		{"-Dc=/repo", "id"},
+		{"-D", "id", "--config", "/repo"},
+		{"--api", "-c", "id"},
+		{"name", "publish", "--key", "c", "-c", "/repo", "/ipfs/QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn"},
+		{"name", "publish", "--key", "-c", "/ipfs/QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn"},
+		{"cat", "--", "-c"},
+	}
+
+	parsed := 0
+	for _, args := range cmdlines {
+		req, err := cli.Parse(context.Background(), args, nil, Root)
+		if err != nil {
+			// Args the cli rejects select no repo.
+			continue
+		}
+		parsed++
+		expected, err := getRepoPath(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual, err := repoPathFromArgs(context.Background(), args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual != expected {
+			t.Errorf("%q: expected repo %s, got %s", args, expected, actual)
+		}
+	}
+	if parsed == 0 {
+		t.Fatal("no command line was parsed")
+	}
+}
+
+// The commands of plugins are unknown when the repo path is looked up.
+func TestRepoPathFromArgsPluginCommand(t *testing.T) {
+	root := *Root
+	root.Subcommands = make(map[string]*cmds.Command)
+	for name, sub := range Root.Subcommands {
+		root.Subcommands[name] = sub
+	}
+	root.Subcommands["fromplugin"] = &cmds.Command{
+		Options: []cmds.Option{
+			cmds.BoolOption("flag", "f", ""),
+			cmds.StringOption("name", ""),
+		},
+		Arguments: []cmds.Argument{
+			cmds.StringArg("args", false, true, ""),
+		},
+		Run: func(*cmds.Request, cmds.ResponseEmitter, cmds.Environment) error { return nil },
+	}
+
+	cmdlines := [][]string{
+		{"fromplugin"},
+		{"-c", "/repo", "fromplugin"},
+		{"fromplugin", "--config=/repo"},
+		{"fromplugin", "-fc", "/repo", "arg"},
+		{"fromplugin", "--flag", "-c=/repo"},
+		{"fromplugin", "--name=x", "--config", "/repo"},
+		{"fromplugin", "--", "-c", "/repo"},
+	}
+	for _, args := range cmdlines {
+		req, err := cli.Parse(context.Background(), args, nil, &root)
+		if err != nil {
+			t.Fatalf("%q: %s", args, err)
+		}
+		expected, err := getRepoPath(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual, err := repoPathFromArgs(context.Background(), args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual != expected {
+			t.Errorf("%q: expected repo %s, got %s", args, expected, actual)
+		}
+	}
+}
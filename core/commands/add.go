@@ -9,6 +9,7 @@ import (
 	"strings"
 
 	"github.com/ipfs/go-ipfs/core/commands/cmdenv"
+	"github.com/ipfs/go-ipfs/core/coreunix"
 
 	cmds "github.com/ipfs/go-ipfs-cmds"
 	"github.com/ipfs/go-ipfs-files"
@@ -45,6 +46,8 @@ const (
 	hashOptionName        = "hash"
 	inlineOptionName      = "inline"
 	inlineLimitOptionName = "inline-limit"
+	workersOptionName     = "workers"
+	resumeOptionName      = "resume"
 )
 
 const adderOutChanSize = 8
@@ -102,6 +105,21 @@ You can now check what blocks have been created by:
   QmY6yj1GsermExDXoosVE3aSPxdMNYr6aKuw3nA8LoWPRS 2059
   QmerURi9k4XzKCaaPbsK6BL5pMEjF7PGphjDvkkjDtsVf3 868
   QmQB28iwSriSUSMqG2nXDTLtdPHgWb4rebBrU7Q1j4vxPv 338
+
+The workers option, '--workers', sets how many files are chunked and
+hashed at the same time. Files streamed to a running daemon are still read
+one after the other, but each is hashed while the next ones are read. The
+hashes don't depend on the number of workers.
+
+The resume option, '--resume', records each added file in the repo, so
+that running the same add again after it was interrupted skips the files
+added already. Large files are also recorded in parts as they are added,
+unless they are added with '--trickle', '--nocopy' or a rabin chunker, so
+that an interrupted file resumes from its last recorded part. Files are
+recognized by their path, size and modification time; they must be added
+with the same options. Only files read by the node from its own filesystem
+are recorded: files streamed to a running daemon are always added again.
+The records are removed once the add completes.
 `,
 	},
 
@@ -129,6 +147,8 @@ You can now check what blocks have been created by:
 		cmds.StringOption(hashOptionName, "Hash function to use. Implies CIDv1 if not sha2-256. (experimental)").WithDefault("sha2-256"),
 		cmds.BoolOption(inlineOptionName, "Inline small blocks into CIDs. (experimental)"),
 		cmds.IntOption(inlineLimitOptionName, "Maximum block size to inline. (experimental)").WithDefault(32),
+		cmds.IntOption(workersOptionName, "Number of files to chunk and hash in parallel.").WithDefault(1),
+		cmds.BoolOption(resumeOptionName, "Record the added files, and skip the ones recorded by an interrupted add."),
 	},
 	PreRun: func(req *cmds.Request, env cmds.Environment) error {
 		quiet, _ := req.Options[quietOptionName].(bool)
@@ -169,6 +189,12 @@ You can now check what blocks have been created by:
 		hashFunStr, _ := req.Options[hashOptionName].(string)
 		inline, _ := req.Options[inlineOptionName].(bool)
 		inlineLimit, _ := req.Options[inlineLimitOptionName].(int)
+		workers, _ := req.Options[workersOptionName].(int)
+		resume, _ := req.Options[resumeOptionName].(bool)
+
+		if workers < 1 {
+			return fmt.Errorf("--%s must be at least 1", workersOptionName)
+		}
 
 		hashFunCode, ok := mh.Names[strings.ToLower(hashFunStr)]
 		if !ok {
@@ -202,6 +228,9 @@ You can now check what blocks have been created by:
 
 			options.Unixfs.Progress(progress),
 			options.Unixfs.Silent(silent),
+
+			coreunix.AddWorkers(workers),
+			coreunix.AddResume(resume),
 		}
 
 		if cidVerSet {
@@ -313,9 +342,10 @@ You can now check what blocks have been created by:
 					bar.Start()
 				}
 
-				lastFile := ""
 				lastHash := ""
-				var totalProgress, prevFiles, lastBytes int64
+				// Files added in parallel send their progress interleaved:
+				// count the bytes of each file by its name.
+				fileBytes := make(map[string]int64)
 
 			LOOP:
 				for {
@@ -350,16 +380,13 @@ You can now check what blocks have been created by:
 								continue
 							}
 
-							if len(lastFile) == 0 {
-								lastFile = output.Name
-							}
-							if output.Name != lastFile || output.Bytes < lastBytes {
-								prevFiles += lastBytes
-								lastFile = output.Name
+							prev := fileBytes[output.Name]
+							if output.Bytes < prev {
+								// Another file with the same name.
+								prev = 0
 							}
-							lastBytes = output.Bytes
-							delta := prevFiles + lastBytes - totalProgress
-							totalProgress = bar.Add64(delta)
+							fileBytes[output.Name] = output.Bytes
+							bar.Add64(output.Bytes - prev)
 						}
 
 						if progress {
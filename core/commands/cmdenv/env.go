@@ -1,11 +1,13 @@
 package cmdenv
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 
 	"github.com/ipfs/go-ipfs/commands"
 	"github.com/ipfs/go-ipfs/core"
+	"github.com/ipfs/go-ipfs/plugin/loader"
 
 	cmds "github.com/ipfs/go-ipfs-cmds"
 	config "github.com/ipfs/go-ipfs-config"
@@ -70,3 +72,16 @@ func GetConfigRoot(env cmds.Environment) (string, error) {
 
 	return ctx.ConfigRoot, nil
 }
+
+// GetPlugins extracts the plugin loader from the environment.
+func GetPlugins(env cmds.Environment) (*loader.PluginLoader, error) {
+	ctx, ok := env.(*commands.Context)
+	if !ok {
+		return nil, fmt.Errorf("expected env to be of type %T, got %T", ctx, env)
+	}
+	if ctx.Plugins == nil {
+		return nil, errors.New("no plugins loaded")
+	}
+
+	return ctx.Plugins, nil
+}
@@ -130,10 +130,15 @@ func TestCommands(t *testing.T) {
 		"/get",
 		"/id",
 		"/key",
+		"/key/export",
 		"/key/gen",
+		"/key/import",
 		"/key/list",
+		"/key/lock",
 		"/key/rename",
 		"/key/rm",
+		"/key/rotate",
+		"/key/unlock",
 		"/log",
 		"/log/level",
 		"/log/ls",
@@ -141,12 +146,25 @@ func TestCommands(t *testing.T) {
 		"/ls",
 		"/mount",
 		"/name",
+		"/name/cache",
+		"/name/cache/clear",
+		"/name/cache/ls",
+		"/name/cache/rm",
+		"/name/get",
+		"/name/history",
+		"/name/inspect",
 		"/name/publish",
+		"/name/put",
 		"/name/pubsub",
 		"/name/pubsub/state",
 		"/name/pubsub/subs",
 		"/name/pubsub/cancel",
 		"/name/resolve",
+		"/name/rollback",
+		"/name/watch",
+		"/name/watch/add",
+		"/name/watch/ls",
+		"/name/watch/rm",
 		"/object",
 		"/object/data",
 		"/object/diff",
@@ -175,7 +193,10 @@ func TestCommands(t *testing.T) {
 		"/pin/rm",
 		"/pin/update",
 		"/pin/verify",
+		"/plugin",
+		"/plugin/ls",
 		"/pubsub",
+		"/pubsub/history",
 		"/pubsub/ls",
 		"/pubsub/peers",
 		"/pubsub/pub",
@@ -183,8 +204,12 @@ func TestCommands(t *testing.T) {
 		"/refs",
 		"/refs/local",
 		"/repo",
+		"/repo/backup",
+		"/repo/convert",
 		"/repo/fsck",
 		"/repo/gc",
+		"/repo/migrate",
+		"/repo/restore",
 		"/repo/stat",
 		"/repo/verify",
 		"/repo/version",
@@ -1,14 +1,30 @@
 package commands
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"os"
+	"strings"
 	"text/tabwriter"
+	"time"
 
+	core "github.com/ipfs/go-ipfs/core"
 	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+	"github.com/ipfs/go-ipfs/core/commands/e"
+	keystore "github.com/ipfs/go-ipfs/keystore"
+	namesys "github.com/ipfs/go-ipfs/namesys"
 
+	ds "github.com/ipfs/go-datastore"
 	cmds "github.com/ipfs/go-ipfs-cmds"
+	path "github.com/ipfs/go-path"
 	options "github.com/ipfs/interface-go-ipfs-core/options"
+	crypto "github.com/libp2p/go-libp2p-core/crypto"
+	peer "github.com/libp2p/go-libp2p-core/peer"
 )
 
 var KeyCmd = &cmds.Command{
@@ -26,6 +42,14 @@ publish'.
   > ipfs key list
   self
   mykey
+
+'ipfs key export' and 'ipfs key import' move keys between nodes.
+
+  > ipfs key export --format=pem-pkcs8 -o mykey.pem mykey
+  > ipfs key import mykey mykey.pem
+
+When the keystore is encrypted (Keystore.Type set to "encrypted"), its keys
+can't be used before 'ipfs key unlock' is run.
 		`,
 	},
 	Subcommands: map[string]*cmds.Command{
@@ -33,6 +57,11 @@ publish'.
 		"list":   keyListCmd,
 		"rename": keyRenameCmd,
 		"rm":     keyRmCmd,
+		"unlock": keyUnlockCmd,
+		"lock":   keyLockCmd,
+		"export": keyExportCmd,
+		"import": keyImportCmd,
+		"rotate": keyRotateCmd,
 	},
 }
 
@@ -45,6 +74,14 @@ type KeyOutputList struct {
 	Keys []KeyOutput
 }
 
+// KeyRotateOutput is the output type of keyRotateCmd
+type KeyRotateOutput struct {
+	Name     string
+	Was      string
+	Now      string
+	Archived string
+}
+
 // KeyRenameOutput define the output type of keyRenameCmd
 type KeyRenameOutput struct {
 	Was       string
@@ -230,6 +267,515 @@ var keyRmCmd = &cmds.Command{
 	Type: KeyOutputList{},
 }
 
+var keyUnlockCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Unlock an encrypted keystore",
+		ShortDescription: `
+'ipfs key unlock' decrypts the keys of an encrypted keystore with the given
+passphrase, so that they can be used until 'ipfs key lock' is run or the
+daemon stops.
+
+The first time, this sets the passphrase of the keystore and encrypts the
+keys that were stored in plaintext. The passphrase can't be set through the
+API of a running daemon: stop the daemon and run 'ipfs key unlock' first.
+
+  > ipfs key unlock < passphrase-file
+
+The keystore can also be unlocked when the daemon starts by setting the
+IPFS_KEYSTORE_PASSPHRASE environment variable.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("passphrase", true, false, "Passphrase of the keystore.").EnableStdin(),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		ks, err := lockableKeystore(env)
+		if err != nil {
+			return err
+		}
+
+		// Otherwise, the first API client to unlock the keystore would
+		// choose its passphrase.
+		if nd.IsDaemon {
+			set, err := ks.HasPassphrase()
+			if err != nil {
+				return err
+			}
+			if !set {
+				return errors.New("the keystore has no passphrase yet, stop the daemon and run 'ipfs key unlock' to set it")
+			}
+		}
+
+		return ks.Unlock([]byte(strings.TrimRight(req.Arguments[0], "\r\n")))
+	},
+}
+
+var keyLockCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Lock an encrypted keystore",
+		ShortDescription: `
+'ipfs key lock' forgets the passphrase of an encrypted keystore. Its keys can't
+be used, e.g. to publish IPNS records, until 'ipfs key unlock' is run.
+`,
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		ks, err := lockableKeystore(env)
+		if err != nil {
+			return err
+		}
+
+		ks.Lock()
+		return nil
+	},
+}
+
+const (
+	keyRotateLifetimeOptionName     = "lifetime"
+	keyRotateAllowOfflineOptionName = "allow-offline"
+)
+
+var keyRotateCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Replace the key of an IPNS name",
+		ShortDescription: `
+'ipfs key rotate' replaces a key, e.g. because it was compromised, and moves
+the followers of its IPNS name over to the new key:
+
+  - a new key is generated, of the same type unless '--type' is given;
+  - the value currently published under the old key is published under the
+    new key;
+  - a final record pointing to /ipns/<new key> is published under the old key;
+  - the old key is kept as '<name>-<old key>', so that the republisher keeps
+    the final record alive, and the new key takes over the name.
+
+The rotation is recorded, and 'ipfs name resolve --rotations' reports the
+chain of keys a name went through.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("name", true, false, "name of key to rotate"),
+	},
+	Options: []cmds.Option{
+		cmds.StringOption(keyStoreTypeOptionName, "t", "type of the new key [rsa, ed25519], defaults to the type of the old key"),
+		cmds.IntOption(keyStoreSizeOptionName, "s", "size of the new key to generate"),
+		cmds.StringOption(keyRotateLifetimeOptionName, "Time duration the final record of the old key will be valid for.").WithDefault("8760h"),
+		cmds.BoolOption(keyRotateAllowOfflineOptionName, "When offline, save the IPNS records to the the local datastore without broadcasting to the network instead of simply failing."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		allowOffline, _ := req.Options[keyRotateAllowOfflineOptionName].(bool)
+		if !nd.IsOnline && !allowOffline {
+			return errors.New("can't publish while offline: pass `--allow-offline` to override")
+		}
+
+		lifetime, _ := req.Options[keyRotateLifetimeOptionName].(string)
+		validTime, err := time.ParseDuration(lifetime)
+		if err != nil {
+			return fmt.Errorf("error parsing lifetime option: %s", err)
+		}
+
+		name := req.Arguments[0]
+		if name == "self" {
+			return fmt.Errorf("cannot rotate key with name 'self'")
+		}
+
+		ks := nd.Repo.Keystore()
+		oldKey, err := ks.Get(name)
+		if err != nil {
+			return fmt.Errorf("key with name '%s' could not be read: %s", name, err)
+		}
+		oldID, err := peer.IDFromPrivateKey(oldKey)
+		if err != nil {
+			return err
+		}
+
+		typ, _ := req.Options[keyStoreTypeOptionName].(string)
+		if typ == "" {
+			switch oldKey.Type() {
+			case crypto.RSA:
+				typ = "rsa"
+			case crypto.Ed25519:
+				typ = "ed25519"
+			default:
+				return fmt.Errorf("please specify a key type with --type")
+			}
+		}
+		size, sizefound := req.Options[keyStoreSizeOptionName].(int)
+		if !sizefound {
+			size = options.DefaultRSALen
+		}
+
+		var newKey crypto.PrivKey
+		switch typ {
+		case "rsa":
+			newKey, _, err = crypto.GenerateKeyPairWithReader(crypto.RSA, size, rand.Reader)
+		case "ed25519":
+			newKey, _, err = crypto.GenerateEd25519Key(rand.Reader)
+		default:
+			return fmt.Errorf("unrecognized key type: %s", typ)
+		}
+		if err != nil {
+			return err
+		}
+		newID, err := peer.IDFromPrivateKey(newKey)
+		if err != nil {
+			return err
+		}
+
+		// Keep the new key under a temporary name until the records are
+		// published: the keystore is only changed once nothing can fail but
+		// the keystore itself.
+		archived := name + "-" + oldID.Pretty()
+		if has, err := ks.Has(archived); err != nil {
+			return err
+		} else if has {
+			return fmt.Errorf("cannot archive the old key: a key named '%s' exists", archived)
+		}
+		pending := name + "-rotating-" + newID.Pretty()
+		if err := ks.Put(pending, newKey); err != nil {
+			return fmt.Errorf("storing new key: %s", err)
+		}
+
+		if err := publishRotation(req.Context, nd, oldKey, newKey, validTime); err != nil {
+			if err := ks.Delete(pending); err != nil {
+				log.Errorf("removing key %s: %s", pending, err)
+			}
+			return err
+		}
+
+		if err := swapRotatedKey(ks, name, archived, pending, oldKey, newKey); err != nil {
+			return err
+		}
+		if err := namesys.RecordRotation(nd.Repo.Datastore(), oldID, newID); err != nil {
+			return err
+		}
+
+		return cmds.EmitOnce(res, &KeyRotateOutput{
+			Name:     name,
+			Was:      oldID.Pretty(),
+			Now:      newID.Pretty(),
+			Archived: archived,
+		})
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *KeyRotateOutput) error {
+			_, err := fmt.Fprintf(w, "Key %s rotated from %s to %s, old key kept as %s\n", out.Name, out.Was, out.Now, out.Archived)
+			return err
+		}),
+	},
+	Type: KeyRotateOutput{},
+}
+
+// publishRotation publishes the current value of oldKey under newKey, and a
+// final record pointing to newKey under oldKey.
+func publishRotation(ctx context.Context, nd *core.IpfsNode, oldKey, newKey crypto.PrivKey, validTime time.Duration) error {
+	oldID, err := peer.IDFromPrivateKey(oldKey)
+	if err != nil {
+		return err
+	}
+	newID, err := peer.IDFromPrivateKey(newKey)
+	if err != nil {
+		return err
+	}
+
+	eol := time.Now().Add(validTime)
+	data, err := nd.Repo.Datastore().Get(namesys.IpnsDsKey(oldID))
+	switch err {
+	case nil:
+		entry, err := namesys.UnmarshalRecord(data)
+		if err != nil {
+			return err
+		}
+		current := path.Path(entry.GetValue())
+		if err := nd.Namesys.PublishWithEOL(ctx, newKey, current, eol); err != nil {
+			return fmt.Errorf("publishing current value under new key: %s", err)
+		}
+	case ds.ErrNotFound:
+		// nothing was published under the old key yet
+	default:
+		return err
+	}
+
+	final := path.FromString("/ipns/" + newID.Pretty())
+	if err := nd.Namesys.PublishWithEOL(ctx, oldKey, final, eol); err != nil {
+		return fmt.Errorf("publishing final record under old key: %s", err)
+	}
+	return nil
+}
+
+// swapRotatedKey archives the old key of name and moves the new key from
+// pending to name. On failure, name is left with the old key.
+func swapRotatedKey(ks keystore.Keystore, name, archived, pending string, oldKey, newKey crypto.PrivKey) error {
+	if err := ks.Put(archived, oldKey); err != nil {
+		return fmt.Errorf("archiving old key: %s, the new key is kept as '%s'", err, pending)
+	}
+	if err := ks.Delete(name); err != nil {
+		return fmt.Errorf("removing old key: %s, the new key is kept as '%s'", err, pending)
+	}
+	if err := ks.Put(name, newKey); err != nil {
+		if rerr := ks.Put(name, oldKey); rerr != nil {
+			return fmt.Errorf("storing new key: %s, restoring old key: %s, the keys are kept as '%s' and '%s'", err, rerr, archived, pending)
+		}
+		return fmt.Errorf("storing new key: %s, the new key is kept as '%s'", err, pending)
+	}
+	if err := ks.Delete(pending); err != nil {
+		log.Errorf("removing key %s: %s", pending, err)
+	}
+	return nil
+}
+
+const (
+	keyFormatOptionName     = "format"
+	keyPassphraseOptionName = "passphrase"
+
+	keyFormatProtobuf = "libp2p-protobuf"
+	keyFormatPEM      = "pem-pkcs8"
+
+	// maxKeyFileSize bounds the size of imported key files.
+	maxKeyFileSize = 64 << 10
+)
+
+var keyExportCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Export a keypair",
+		ShortDescription: `
+Exports a named key, including 'self', so that it can be imported on another
+node with 'ipfs key import'. The key is written to './<name>.key' by default.
+Use '--output=-' to write it to stdout instead.
+
+The identity of the node, 'self', is never sent over the API: export it while
+the daemon is stopped.
+
+Supported formats are:
+  - libp2p-protobuf: the libp2p protobuf encoding, as stored in the keystore.
+  - pem-pkcs8: a PKCS #8 PEM block, readable by e.g. OpenSSL.
+
+With '--passphrase', a pem-pkcs8 key is encrypted (PBES2 with scrypt and
+AES-256-CBC). Note that options are visible to other local users while the
+command runs.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("name", true, false, "name of key to export"),
+	},
+	Options: []cmds.Option{
+		cmds.StringOption(outputOptionName, "o", "The path where the key should be written."),
+		cmds.StringOption(keyFormatOptionName, "f", "Format of the exported key [libp2p-protobuf, pem-pkcs8].").WithDefault(keyFormatProtobuf),
+		cmds.StringOption(keyPassphraseOptionName, "Passphrase to encrypt the exported key with (pem-pkcs8 only)."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		name := req.Arguments[0]
+		format, _ := req.Options[keyFormatOptionName].(string)
+		passphrase, _ := req.Options[keyPassphraseOptionName].(string)
+
+		var sk crypto.PrivKey
+		if name == "self" {
+			// Any API client could take over the identity of the node.
+			if nd.IsDaemon {
+				return errors.New("cannot export 'self' through the daemon, stop the daemon and run 'ipfs key export self'")
+			}
+			sk = nd.PrivateKey
+		} else {
+			sk, err = nd.Repo.Keystore().Get(name)
+			if err != nil {
+				return fmt.Errorf("key with name '%s' could not be read: %s", name, err)
+			}
+		}
+
+		var data []byte
+		switch format {
+		case keyFormatProtobuf:
+			if passphrase != "" {
+				return fmt.Errorf("only the %s format can be encrypted", keyFormatPEM)
+			}
+			data, err = crypto.MarshalPrivateKey(sk)
+		case keyFormatPEM:
+			data, err = keystore.MarshalPEM(sk, []byte(passphrase))
+		default:
+			return fmt.Errorf("unrecognized key format: %s", format)
+		}
+		if err != nil {
+			return err
+		}
+
+		return res.Emit(bytes.NewReader(data))
+	},
+	PostRun: cmds.PostRunMap{
+		cmds.CLI: func(res cmds.Response, re cmds.ResponseEmitter) error {
+			req := res.Request()
+
+			v, err := res.Next()
+			if err != nil {
+				return err
+			}
+
+			outReader, ok := v.(io.Reader)
+			if !ok {
+				return e.New(e.TypeErr(outReader, v))
+			}
+
+			outPath, _ := req.Options[outputOptionName].(string)
+			if outPath == "-" {
+				_, err := io.Copy(os.Stdout, outReader)
+				return err
+			}
+			if outPath == "" {
+				outPath = req.Arguments[0] + ".key"
+			}
+
+			// Never overwrite existing files, and keep the key private.
+			file, err := os.OpenFile(outPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+
+			_, err = io.Copy(file, outReader)
+			return err
+		},
+	},
+}
+
+var keyImportCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Import a keypair",
+		ShortDescription: `
+Imports a key exported with 'ipfs key export', under the given name. The
+format is detected automatically unless '--format' is given. Encrypted
+pem-pkcs8 keys require '--passphrase'.
+
+Importing fails if a key with the same name already exists, or if the key is
+already present under another name.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("name", true, false, "name to associate with the imported key"),
+		cmds.FileArg("key", true, false, "key file to import").EnableStdin(),
+	},
+	Options: []cmds.Option{
+		cmds.StringOption(keyFormatOptionName, "f", "Format of the imported key [libp2p-protobuf, pem-pkcs8]."),
+		cmds.StringOption(keyPassphraseOptionName, "Passphrase to decrypt the imported key with."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		name := req.Arguments[0]
+		if name == "self" {
+			return fmt.Errorf("cannot import key with name 'self'")
+		}
+
+		file, err := cmdenv.GetFileArg(req.Files.Entries())
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		data, err := ioutil.ReadAll(io.LimitReader(file, maxKeyFileSize+1))
+		if err != nil {
+			return err
+		}
+		if len(data) > maxKeyFileSize {
+			return fmt.Errorf("key file is larger than %d bytes", maxKeyFileSize)
+		}
+
+		format, _ := req.Options[keyFormatOptionName].(string)
+		if format == "" {
+			format = keyFormatProtobuf
+			if bytes.HasPrefix(bytes.TrimSpace(data), []byte("-----BEGIN")) {
+				format = keyFormatPEM
+			}
+		}
+		passphrase, _ := req.Options[keyPassphraseOptionName].(string)
+
+		var sk crypto.PrivKey
+		switch format {
+		case keyFormatProtobuf:
+			sk, err = crypto.UnmarshalPrivateKey(data)
+		case keyFormatPEM:
+			sk, err = keystore.UnmarshalPEM(data, []byte(passphrase))
+		default:
+			return fmt.Errorf("unrecognized key format: %s", format)
+		}
+		if err != nil {
+			return fmt.Errorf("could not decode key: %s", err)
+		}
+
+		pid, err := peer.IDFromPrivateKey(sk)
+		if err != nil {
+			return err
+		}
+
+		ks := nd.Repo.Keystore()
+		if has, err := ks.Has(name); err != nil {
+			return err
+		} else if has {
+			return fmt.Errorf("key with name '%s' already exists", name)
+		}
+		if pid == nd.Identity {
+			return fmt.Errorf("key is the node's own key 'self'")
+		}
+		names, err := ks.List()
+		if err != nil {
+			return err
+		}
+		for _, other := range names {
+			otherKey, err := ks.Get(other)
+			if err != nil {
+				return err
+			}
+			if otherKey.Equals(sk) {
+				return fmt.Errorf("key is already imported as '%s'", other)
+			}
+		}
+
+		if err := ks.Put(name, sk); err != nil {
+			return err
+		}
+
+		return cmds.EmitOnce(res, &KeyOutput{
+			Name: name,
+			Id:   pid.Pretty(),
+		})
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, ko *KeyOutput) error {
+			_, err := w.Write([]byte(ko.Id + "\n"))
+			return err
+		}),
+	},
+	Type: KeyOutput{},
+}
+
+func lockableKeystore(env cmds.Environment) (keystore.Locker, error) {
+	nd, err := cmdenv.GetNode(env)
+	if err != nil {
+		return nil, err
+	}
+
+	ks, ok := nd.Repo.Keystore().(keystore.Locker)
+	if !ok {
+		return nil, fmt.Errorf("keystore is not encrypted, set Keystore.Type to \"encrypted\" to enable this")
+	}
+	return ks, nil
+}
+
 func keyOutputListEncoders() cmds.EncoderFunc {
 	return cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, list *KeyOutputList) error {
 		withID, _ := req.Options["l"].(bool)
@@ -0,0 +1,74 @@
+package commands
+
+import (
+	"crypto/rand"
+	"errors"
+	"testing"
+
+	keystore "github.com/ipfs/go-ipfs/keystore"
+
+	crypto "github.com/libp2p/go-libp2p-core/crypto"
+)
+
+// failingKeystore fails to store keys under the given name.
+type failingKeystore struct {
+	keystore.Keystore
+	failPut string
+}
+
+func (ks *failingKeystore) Put(name string, k crypto.PrivKey) error {
+	if name == ks.failPut {
+		ks.failPut = ""
+		return errors.New("put failed")
+	}
+	return ks.Keystore.Put(name, k)
+}
+
+func TestSwapRotatedKey(t *testing.T) {
+	oldKey, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newKey, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, failPut := range []string{"", "archived", "name"} {
+		ks := &failingKeystore{Keystore: keystore.NewMemKeystore()}
+		if err := ks.Put("name", oldKey); err != nil {
+			t.Fatal(err)
+		}
+		if err := ks.Put("pending", newKey); err != nil {
+			t.Fatal(err)
+		}
+		ks.failPut = failPut
+
+		err := swapRotatedKey(ks, "name", "archived", "pending", oldKey, newKey)
+		if (err == nil) != (failPut == "") {
+			t.Fatalf("failing put of %q: unexpected error %v", failPut, err)
+		}
+
+		// The name always holds a key, the new one only on success.
+		k, err := ks.Get("name")
+		if err != nil {
+			t.Fatalf("failing put of %q: %s", failPut, err)
+		}
+		want := oldKey
+		if failPut == "" {
+			want = newKey
+		}
+		if !k.Equals(want) {
+			t.Fatalf("failing put of %q: unexpected key", failPut)
+		}
+
+		// The new key is never lost.
+		has, err := ks.Has("pending")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if has == (failPut == "") {
+			t.Fatalf("failing put of %q: pending key present: %t", failPut, has)
+		}
+	}
+}
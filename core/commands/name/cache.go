@@ -0,0 +1,128 @@
+package name
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+	namesys "github.com/ipfs/go-ipfs/namesys"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+// CacheEntryList is the output of 'ipfs name cache ls'.
+type CacheEntryList struct {
+	Entries []namesys.CacheEntry
+}
+
+// IpnsCacheCmd inspects and manages the cache of resolved names.
+var IpnsCacheCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Inspect and manage the cache of resolved names.",
+		ShortDescription: `
+Names resolved by the node are cached until their TTL runs out. These commands
+list the cached entries and evict them, so that the next resolution goes to the
+network again.
+
+The cache can be persisted in the datastore across restarts by setting
+Namesys.PersistCache in the config file.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"ls":    ipnsCacheLsCmd,
+		"rm":    ipnsCacheRmCmd,
+		"clear": ipnsCacheClearCmd,
+	},
+}
+
+var ipnsCacheLsCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "List the cached names.",
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		cache, err := getNameCache(env)
+		if err != nil {
+			return err
+		}
+
+		entries := cache.CacheEntries()
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].Name < entries[j].Name
+		})
+
+		return cmds.EmitOnce(res, &CacheEntryList{Entries: entries})
+	},
+	Type: CacheEntryList{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, list *CacheEntryList) error {
+			tw := tabwriter.NewWriter(w, 1, 2, 1, ' ', 0)
+			for _, e := range list.Entries {
+				ttl := time.Until(e.EOL).Round(time.Second)
+				fmt.Fprintf(tw, "/ipns/%s\t%s\t%s\n", e.Name, e.Value, ttl)
+			}
+			return tw.Flush()
+		}),
+	},
+}
+
+var ipnsCacheRmCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Evict names from the cache.",
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("name", true, true, "The names to evict."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		cache, err := getNameCache(env)
+		if err != nil {
+			return err
+		}
+
+		var removed []string
+		for _, name := range req.Arguments {
+			name = strings.TrimPrefix(name, "/ipns/")
+			if !cache.CacheRemove(name) {
+				return cmds.Errorf(cmds.ErrClient, "%s is not cached", name)
+			}
+			removed = append(removed, "/ipns/"+name)
+		}
+
+		return cmds.EmitOnce(res, &stringList{removed})
+	},
+	Type: stringList{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: stringListEncoder(),
+	},
+}
+
+var ipnsCacheClearCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Evict all names from the cache.",
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		cache, err := getNameCache(env)
+		if err != nil {
+			return err
+		}
+
+		cache.CachePurge()
+		return nil
+	},
+}
+
+func getNameCache(env cmds.Environment) (namesys.Cache, error) {
+	n, err := cmdenv.GetNode(env)
+	if err != nil {
+		return nil, err
+	}
+
+	cache, ok := n.Namesys.(namesys.Cache)
+	if !ok {
+		return nil, fmt.Errorf("the name system does not support cache inspection")
+	}
+	return cache, nil
+}
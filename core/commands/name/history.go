@@ -0,0 +1,226 @@
+package name
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	core "github.com/ipfs/go-ipfs/core"
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+	namesys "github.com/ipfs/go-ipfs/namesys"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	iface "github.com/ipfs/interface-go-ipfs-core"
+	ci "github.com/libp2p/go-libp2p-core/crypto"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+)
+
+const (
+	seqOptionName = "seq"
+)
+
+// IpnsHistory is the output of 'ipfs name history'.
+type IpnsHistory struct {
+	Name    string
+	Entries []namesys.HistoryEntry
+}
+
+var IpnsHistoryCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Show the values previously published under a key.",
+		ShortDescription: `
+Lists the values this node published under the given key, oldest first, along
+with their sequence numbers and publication times. Only the most recent
+publications are kept.
+
+Any of these values can be published again with 'ipfs name rollback'.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("key", false, false, "Name of the key or a valid PeerID, as listed by 'ipfs key list -l'. Defaults to 'self'."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		kname := "self"
+		if len(req.Arguments) > 0 {
+			kname = req.Arguments[0]
+		}
+
+		id, err := keyID(req.Context, api, kname)
+		if err != nil {
+			return err
+		}
+
+		history, err := namesys.PublishHistory(nd.Repo.Datastore(), id)
+		if err != nil {
+			return err
+		}
+
+		return cmds.EmitOnce(res, &IpnsHistory{
+			Name:    id.Pretty(),
+			Entries: history,
+		})
+	},
+	Type: IpnsHistory{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, h *IpnsHistory) error {
+			tw := tabwriter.NewWriter(w, 1, 2, 1, ' ', 0)
+			for _, e := range h.Entries {
+				fmt.Fprintf(tw, "%d\t%s\t%s\n", e.Sequence, e.Time.Format(time.RFC3339), e.Value)
+			}
+			return tw.Flush()
+		}),
+	},
+}
+
+var IpnsRollbackCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Publish a previously published value again.",
+		ShortDescription: `
+Looks up the value published under the given key with sequence number <seq>
+in the publish history (see 'ipfs name history') and publishes it again. The
+new record gets a sequence number higher than the current one, so it replaces
+the current value everywhere.
+
+  > ipfs name history mykey
+  0  2019-06-01T10:00:00Z  /ipfs/QmatmE9msSfkKxoffpHwNLNKgwZG8eT9Bud6YoPab52vpy
+  1  2019-06-02T10:00:00Z  /ipfs/QmSiTko9JZyabH56y2fussEt1A5oDqsFXB3CkvAqraFryz
+  > ipfs name rollback --seq=0 mykey
+  Published to QmSrPmbaUKA3ZodhzPWZnpFgcPMFWF4QsxXbkWfEptTBJd: /ipfs/QmatmE9msSfkKxoffpHwNLNKgwZG8eT9Bud6YoPab52vpy
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("key", false, false, "Name of the key or a valid PeerID, as listed by 'ipfs key list -l'. Defaults to 'self'."),
+	},
+	Options: []cmds.Option{
+		cmds.Uint64Option(seqOptionName, "Sequence number of the value to roll back to."),
+		cmds.StringOption(lifeTimeOptionName, "t",
+			`Time duration that the record will be valid for. <<default>>
+    This accepts durations such as "300s", "1.5h" or "2h45m". Valid time units are
+    "ns", "us" (or "µs"), "ms", "s", "m", "h".`).WithDefault("24h"),
+		cmds.BoolOption(allowOfflineOptionName, "When offline, save the IPNS record to the the local datastore without broadcasting to the network instead of simply failing."),
+		cmds.StringOption(ttlOptionName, "Time duration this record should be cached for. Uses the same syntax as the lifetime option. (caution: experimental)"),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		seq, ok := req.Options[seqOptionName].(uint64)
+		if !ok {
+			return cmds.Errorf(cmds.ErrClient, "please specify the sequence number to roll back to with --seq")
+		}
+
+		kname := "self"
+		if len(req.Arguments) > 0 {
+			kname = req.Arguments[0]
+		}
+
+		k, err := privateKey(req.Context, api, nd, kname)
+		if err != nil {
+			return err
+		}
+		id, err := peer.IDFromPrivateKey(k)
+		if err != nil {
+			return err
+		}
+
+		if nd.Mounts.Ipns != nil && nd.Mounts.Ipns.IsActive() {
+			return errors.New("cannot manually publish while IPNS is mounted")
+		}
+		allowOffline, _ := req.Options[allowOfflineOptionName].(bool)
+		if !nd.IsOnline && !allowOffline {
+			return errAllowOffline
+		}
+
+		validTimeOpt, _ := req.Options[lifeTimeOptionName].(string)
+		validTime, err := time.ParseDuration(validTimeOpt)
+		if err != nil {
+			return fmt.Errorf("error parsing lifetime option: %s", err)
+		}
+
+		ctx := req.Context
+		if ttl, found := req.Options[ttlOptionName].(string); found {
+			d, err := time.ParseDuration(ttl)
+			if err != nil {
+				return err
+			}
+
+			ctx = context.WithValue(ctx, "ipns-publish-ttl", d)
+		}
+
+		value, err := nd.Namesys.Rollback(ctx, k, seq, time.Now().Add(validTime))
+		if err != nil {
+			if err == namesys.ErrNoHistoryEntry {
+				return cmds.Errorf(cmds.ErrClient, "sequence number %d: %s", seq, err)
+			}
+			return err
+		}
+
+		return cmds.EmitOnce(res, &IpnsEntry{
+			Name:  id.Pretty(),
+			Value: value.String(),
+		})
+	},
+	Type: IpnsEntry{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, ie *IpnsEntry) error {
+			_, err := fmt.Fprintf(w, "Published to %s: %s\n", ie.Name, ie.Value)
+			return err
+		}),
+	},
+}
+
+// keyID returns the peer ID of the key with the given name or peer ID.
+func keyID(ctx context.Context, api iface.CoreAPI, k string) (peer.ID, error) {
+	keys, err := api.Key().List(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	for _, key := range keys {
+		if key.Name() == k || key.ID().Pretty() == k {
+			return key.ID(), nil
+		}
+	}
+
+	return "", fmt.Errorf("no key by the given name or PeerID was found")
+}
+
+// privateKey returns the private key of the key with the given name or peer
+// ID.
+func privateKey(ctx context.Context, api iface.CoreAPI, nd *core.IpfsNode, k string) (ci.PrivKey, error) {
+	keys, err := api.Key().List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range keys {
+		if key.Name() == k || key.ID().Pretty() == k {
+			if key.Name() == "self" {
+				return nd.PrivateKey, nil
+			}
+			return nd.Repo.Keystore().Get(key.Name())
+		}
+	}
+
+	return nil, fmt.Errorf("no key by the given name or PeerID was found")
+}
@@ -21,6 +21,10 @@ var log = logging.Logger("core/commands/ipns")
 
 type ResolvedPath struct {
 	Path path.Path
+
+	// Rotations lists the keys the name went through, oldest first, when
+	// requested with --rotations.
+	Rotations []string `json:",omitempty"`
 }
 
 const (
@@ -29,6 +33,7 @@ const (
 	dhtRecordCountOptionName = "dht-record-count"
 	dhtTimeoutOptionName     = "dht-timeout"
 	streamOptionName         = "stream"
+	rotationsOptionName      = "rotations"
 )
 
 var IpnsCmd = &cmds.Command{
@@ -66,6 +71,13 @@ Resolve the value of a dnslink:
   > ipfs name resolve ipfs.io
   /ipfs/QmaBvfZooxWkrv7D3r8LS9moNjzD2o525XMZze69hhoxf5
 
+Show the keys a name went through with 'ipfs key rotate':
+
+  > ipfs name resolve --rotations QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ
+  /ipfs/QmSiTko9JZyabH56y2fussEt1A5oDqsFXB3CkvAqraFryz
+  rotated: QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ -> QmVdNa9Mic4eYAmQC1Ljun3YjqoWQEaUvt9SWTkmhqvHKx
+
+Only the rotations made by this node are known.
 `,
 	},
 
@@ -78,6 +90,7 @@ Resolve the value of a dnslink:
 		cmds.UintOption(dhtRecordCountOptionName, "dhtrc", "Number of records to request for DHT resolution."),
 		cmds.StringOption(dhtTimeoutOptionName, "dhtt", "Max time to collect values during DHT resolution eg \"30s\". Pass 0 for no timeout."),
 		cmds.BoolOption(streamOptionName, "s", "Stream entries as they are found."),
+		cmds.BoolOption(rotationsOptionName, "Report the key rotations of the name."),
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		api, err := cmdenv.GetApi(env, req)
@@ -128,13 +141,21 @@ Resolve the value of a dnslink:
 			name = "/ipns/" + name
 		}
 
+		var rotations []string
+		if showRotations, _ := req.Options[rotationsOptionName].(bool); showRotations {
+			rotations, err = nameRotations(env, name)
+			if err != nil {
+				return err
+			}
+		}
+
 		if !stream {
 			output, err := api.Name().Resolve(req.Context, name, opts...)
 			if err != nil && (recursive || err != namesys.ErrResolveRecursion) {
 				return err
 			}
 
-			return cmds.EmitOnce(res, &ResolvedPath{path.FromString(output.String())})
+			return cmds.EmitOnce(res, &ResolvedPath{path.FromString(output.String()), rotations})
 		}
 
 		output, err := api.Name().Search(req.Context, name, opts...)
@@ -146,7 +167,7 @@ Resolve the value of a dnslink:
 			if v.Err != nil && (recursive || v.Err != namesys.ErrResolveRecursion) {
 				return v.Err
 			}
-			if err := res.Emit(&ResolvedPath{path.FromString(v.Path.String())}); err != nil {
+			if err := res.Emit(&ResolvedPath{path.FromString(v.Path.String()), rotations}); err != nil {
 				return err
 			}
 
@@ -156,9 +177,41 @@ Resolve the value of a dnslink:
 	},
 	Encoders: cmds.EncoderMap{
 		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, rp *ResolvedPath) error {
-			_, err := fmt.Fprintln(w, rp.Path)
-			return err
+			if _, err := fmt.Fprintln(w, rp.Path); err != nil {
+				return err
+			}
+			if len(rp.Rotations) > 0 {
+				_, err := fmt.Fprintf(w, "rotated: %s\n", strings.Join(rp.Rotations, " -> "))
+				return err
+			}
+			return nil
 		}),
 	},
 	Type: ResolvedPath{},
 }
+
+// nameRotations returns the keys an IPNS name went through, if it was
+// rotated by this node.
+func nameRotations(env cmds.Environment, name string) ([]string, error) {
+	id, err := namesys.ParseIpnsName(strings.SplitN(strings.TrimPrefix(name, "/ipns/"), "/", 2)[0])
+	if err != nil {
+		// not a key, e.g. a domain name
+		return nil, nil
+	}
+
+	nd, err := cmdenv.GetNode(env)
+	if err != nil {
+		return nil, err
+	}
+
+	chain, err := namesys.Rotations(nd.Repo.Datastore(), id)
+	if err != nil || len(chain) == 0 {
+		return nil, err
+	}
+
+	keys := []string{id.Pretty()}
+	for _, r := range chain {
+		keys = append(keys, r.To.Pretty())
+	}
+	return keys, nil
+}
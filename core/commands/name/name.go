@@ -55,12 +55,23 @@ Resolve the value of a dnslink:
   > ipfs name resolve ipfs.io
   /ipfs/QmaBvfZooxWkrv7D3r8LS9moNjzD2o525XMZze69hhoxf5
 
+Fetch and inspect the signed record of a name:
+
+  > ipfs name get QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ | ipfs name inspect
+
 `,
 	},
 
 	Subcommands: map[string]*cmds.Command{
-		"publish": PublishCmd,
-		"resolve": IpnsCmd,
-		"pubsub":  IpnsPubsubCmd,
+		"publish":  PublishCmd,
+		"resolve":  IpnsCmd,
+		"pubsub":   IpnsPubsubCmd,
+		"inspect":  IpnsInspectCmd,
+		"get":      IpnsGetCmd,
+		"put":      IpnsPutCmd,
+		"watch":    IpnsWatchCmd,
+		"history":  IpnsHistoryCmd,
+		"rollback": IpnsRollbackCmd,
+		"cache":    IpnsCacheCmd,
 	},
 }
@@ -0,0 +1,352 @@
+package name
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+	namesys "github.com/ipfs/go-ipfs/namesys"
+	republisher "github.com/ipfs/go-ipfs/namesys/republisher"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	files "github.com/ipfs/go-ipfs-files"
+	ipns "github.com/ipfs/go-ipns"
+	pb "github.com/ipfs/go-ipns/pb"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	routing "github.com/libp2p/go-libp2p-core/routing"
+)
+
+// maxRecordSize bounds the size of IPNS records read from the user. Routing
+// systems refuse values much smaller than this anyway.
+const maxRecordSize = 10 << 10
+
+const (
+	verifyOptionName = "verify"
+	watchOptionName  = "watch"
+)
+
+// IpnsInspectEntry is the decoded form of an IPNS record.
+type IpnsInspectEntry struct {
+	Value        string
+	Sequence     uint64
+	ValidityType string
+	Validity     *time.Time     `json:",omitempty"`
+	TTL          *time.Duration `json:",omitempty"`
+	PublicKey    string
+	Signature    string
+}
+
+// IpnsInspectValidation is the result of validating a record against a name.
+type IpnsInspectValidation struct {
+	Name   string
+	Valid  bool
+	Reason string
+}
+
+// IpnsInspectResult is the output of 'ipfs name inspect'.
+type IpnsInspectResult struct {
+	Entry      IpnsInspectEntry
+	Validation *IpnsInspectValidation `json:",omitempty"`
+}
+
+var IpnsInspectCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Inspect an IPNS record.",
+		ShortDescription: `
+Prints the contents of a serialized IPNS record: its value, sequence number,
+validity, TTL, signature and embedded public key.
+`,
+		LongDescription: `
+Prints the contents of a serialized IPNS record: its value, sequence number,
+validity, TTL, signature and embedded public key.
+
+The argument is either a file holding the record or an IPNS name, in which
+case the record currently stored in the routing system for the name is
+inspected, as if fetched with 'ipfs name get'. Without an argument, the record
+is read from standard input:
+
+  > ipfs name inspect QmSrPmbaUKA3ZodhzPWZnpFgcPMFWF4QsxXbkWfEptTBJd
+  > ipfs name get QmSrPmbaUKA3ZodhzPWZnpFgcPMFWF4QsxXbkWfEptTBJd | ipfs name inspect
+
+A file named like an IPNS name takes precedence over the name.
+
+Passing '--verify=<name>' additionally checks that the record is signed by the
+key behind <name> and has not expired.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("record", false, false, "A file holding the IPNS record, or the IPNS name to inspect the record of."),
+	},
+	Options: []cmds.Option{
+		cmds.StringOption(verifyOptionName, "Verify the record against the given IPNS name."),
+	},
+	// The record files are read by the client, which uploads them: only IPNS
+	// names are left as arguments.
+	PreRun: func(req *cmds.Request, env cmds.Environment) error {
+		var r io.Reader
+		switch {
+		case len(req.Arguments) == 0 || req.Arguments[0] == "-":
+			r = os.Stdin
+		default:
+			if _, err := os.Stat(req.Arguments[0]); err != nil {
+				if _, perr := namesys.ParseIpnsName(req.Arguments[0]); perr == nil {
+					return nil
+				}
+				return err
+			}
+			f, err := os.Open(req.Arguments[0])
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			r = f
+		}
+
+		data, err := readRecord(r)
+		if err != nil {
+			return err
+		}
+		req.Arguments = nil
+		req.Files = files.NewMapDirectory(map[string]files.Node{
+			"record": files.NewBytesFile(data),
+		})
+		return nil
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		var data []byte
+		if len(req.Arguments) > 0 {
+			id, err := namesys.ParseIpnsName(req.Arguments[0])
+			if err != nil {
+				return cmds.Errorf(cmds.ErrClient, "invalid IPNS name: %s", err)
+			}
+			data, err = getRecord(req, nd.Routing, id)
+		} else {
+			data, err = readRecordArg(req)
+		}
+		if err != nil {
+			return err
+		}
+
+		entry, err := namesys.UnmarshalRecord(data)
+		if err != nil {
+			return cmds.Errorf(cmds.ErrClient, "invalid IPNS record: %s", err)
+		}
+
+		out := &IpnsInspectResult{
+			Entry: IpnsInspectEntry{
+				Value:        string(entry.GetValue()),
+				Sequence:     entry.GetSequence(),
+				ValidityType: entry.GetValidityType().String(),
+				PublicKey:    base64.StdEncoding.EncodeToString(entry.GetPubKey()),
+				Signature:    base64.StdEncoding.EncodeToString(entry.GetSignature()),
+			},
+		}
+
+		if entry.GetValidityType() == pb.IpnsEntry_EOL {
+			if eol, err := ipns.GetEOL(entry); err == nil {
+				out.Entry.Validity = &eol
+			}
+		}
+		if entry.Ttl != nil {
+			ttl := time.Duration(entry.GetTtl())
+			out.Entry.TTL = &ttl
+		}
+
+		if name, ok := req.Options[verifyOptionName].(string); ok {
+			id, err := namesys.ParseIpnsName(name)
+			if err != nil {
+				return cmds.Errorf(cmds.ErrClient, "invalid IPNS name: %s", err)
+			}
+
+			v := &IpnsInspectValidation{Name: id.Pretty(), Valid: true}
+			if _, err := namesys.ValidateRecord(req.Context, nd.Routing, id, entry); err != nil {
+				v.Valid = false
+				v.Reason = err.Error()
+			}
+			out.Validation = v
+		}
+
+		return cmds.EmitOnce(res, out)
+	},
+	Type: IpnsInspectResult{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *IpnsInspectResult) error {
+			fmt.Fprintf(w, "Value:         %s\n", out.Entry.Value)
+			fmt.Fprintf(w, "Sequence:      %d\n", out.Entry.Sequence)
+			fmt.Fprintf(w, "Validity Type: %s\n", out.Entry.ValidityType)
+			if out.Entry.Validity != nil {
+				fmt.Fprintf(w, "Validity:      %s\n", out.Entry.Validity.Format(time.RFC3339Nano))
+			}
+			if out.Entry.TTL != nil {
+				fmt.Fprintf(w, "TTL:           %s\n", out.Entry.TTL)
+			}
+			if out.Entry.PublicKey != "" {
+				fmt.Fprintf(w, "Public Key:    %s\n", out.Entry.PublicKey)
+			} else {
+				fmt.Fprintln(w, "Public Key:    (not embedded)")
+			}
+			fmt.Fprintf(w, "Signature:     %s\n", out.Entry.Signature)
+
+			if v := out.Validation; v != nil {
+				fmt.Fprintf(w, "\nValidation results:\n")
+				fmt.Fprintf(w, "  Name:        %s\n", v.Name)
+				fmt.Fprintf(w, "  Valid:       %t\n", v.Valid)
+				if v.Reason != "" {
+					fmt.Fprintf(w, "  Reason:      %s\n", v.Reason)
+				}
+			}
+			return nil
+		}),
+	},
+}
+
+var IpnsGetCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Get the signed IPNS record for a name from the routing system.",
+		ShortDescription: `
+Outputs the raw, signed IPNS record currently stored in the routing system for
+the given name. The output can be decoded with 'ipfs name inspect' or stored
+elsewhere with 'ipfs name put'.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("name", true, false, "The IPNS name to get the record for."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		id, err := namesys.ParseIpnsName(req.Arguments[0])
+		if err != nil {
+			return cmds.Errorf(cmds.ErrClient, "invalid IPNS name: %s", err)
+		}
+
+		val, err := getRecord(req, nd.Routing, id)
+		if err != nil {
+			return err
+		}
+
+		return res.Emit(bytes.NewReader(val))
+	},
+}
+
+var IpnsPutCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Store a signed IPNS record in the routing system.",
+		ShortDescription: `
+Stores an IPNS record signed by another node under the given name and
+publishes it to the routing system. The record must be validly signed by the
+key behind <name> and must not have expired.
+
+This makes it possible to keep serving the names of a node that went offline:
+
+  > ipfs name get QmSrPmbaUKA3ZodhzPWZnpFgcPMFWF4QsxXbkWfEptTBJd > record
+  > ipfs name put QmSrPmbaUKA3ZodhzPWZnpFgcPMFWF4QsxXbkWfEptTBJd record
+
+Pass '--watch' to also add the name to the names kept alive by the republisher
+(see 'ipfs name watch').
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("name", true, false, "The IPNS name the record belongs to."),
+		cmds.FileArg("record", true, false, "The IPNS record to store.").EnableStdin(),
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption(allowOfflineOptionName, "When offline, save the IPNS record to the the local datastore without broadcasting to the network instead of simply failing."),
+		cmds.BoolOption(watchOptionName, "Keep republishing the record as part of the watched names."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		allowOffline, _ := req.Options[allowOfflineOptionName].(bool)
+		if !nd.IsOnline && !allowOffline {
+			return errAllowOffline
+		}
+
+		id, err := namesys.ParseIpnsName(req.Arguments[0])
+		if err != nil {
+			return cmds.Errorf(cmds.ErrClient, "invalid IPNS name: %s", err)
+		}
+
+		data, err := readRecordArg(req)
+		if err != nil {
+			return err
+		}
+
+		entry, err := namesys.UnmarshalRecord(data)
+		if err != nil {
+			return cmds.Errorf(cmds.ErrClient, "invalid IPNS record: %s", err)
+		}
+
+		pk, err := namesys.ValidateRecord(req.Context, nd.Routing, id, entry)
+		if err != nil {
+			return cmds.Errorf(cmds.ErrClient, "record is not valid for %s: %s", id.Pretty(), err)
+		}
+
+		if err := namesys.PutRecordToRouting(req.Context, nd.Routing, pk, entry); err != nil {
+			return err
+		}
+
+		if watch, _ := req.Options[watchOptionName].(bool); watch {
+			if err := republisher.Watch(nd.Repo.Datastore(), id, entry); err != nil {
+				return err
+			}
+		}
+
+		return cmds.EmitOnce(res, &IpnsEntry{
+			Name:  id.Pretty(),
+			Value: string(entry.GetValue()),
+		})
+	},
+	Type: IpnsEntry{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, ie *IpnsEntry) error {
+			_, err := fmt.Fprintf(w, "Stored record for %s: %s\n", ie.Name, ie.Value)
+			return err
+		}),
+	},
+}
+
+// getRecord fetches the record of id from the routing system.
+func getRecord(req *cmds.Request, r routing.ValueStore, id peer.ID) ([]byte, error) {
+	return r.GetValue(req.Context, ipns.RecordKey(id))
+}
+
+func readRecordArg(req *cmds.Request) ([]byte, error) {
+	if req.Files == nil {
+		return nil, cmds.Errorf(cmds.ErrClient, "missing IPNS record")
+	}
+	file, err := cmdenv.GetFileArg(req.Files.Entries())
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return readRecord(file)
+}
+
+func readRecord(r io.Reader) ([]byte, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(r, maxRecordSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxRecordSize {
+		return nil, cmds.Errorf(cmds.ErrClient, "IPNS record exceeds %d bytes", maxRecordSize)
+	}
+	return data, nil
+}
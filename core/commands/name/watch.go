@@ -0,0 +1,183 @@
+package name
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+	namesys "github.com/ipfs/go-ipfs/namesys"
+	republisher "github.com/ipfs/go-ipfs/namesys/republisher"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	ipns "github.com/ipfs/go-ipns"
+	pb "github.com/ipfs/go-ipns/pb"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+)
+
+// WatchedName describes a third-party name kept alive by the republisher.
+type WatchedName struct {
+	Name     string
+	Value    string `json:",omitempty"`
+	Sequence uint64
+}
+
+// WatchedNameList is the output of 'ipfs name watch ls'.
+type WatchedNameList struct {
+	Names []WatchedName
+}
+
+// IpnsWatchCmd manages the third-party names kept alive by the republisher.
+var IpnsWatchCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Manage the IPNS names republished on behalf of other nodes.",
+		ShortDescription: `
+Watched names are IPNS names signed by other nodes that this node keeps alive.
+The republisher periodically looks up the latest valid record for each watched
+name and puts it back into the routing system, without needing the private key.
+This keeps the names of a node that went offline resolvable.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"add": ipnsWatchAddCmd,
+		"rm":  ipnsWatchRmCmd,
+		"ls":  ipnsWatchLsCmd,
+	},
+}
+
+var ipnsWatchAddCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Add a name to the list of watched names.",
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("name", true, false, "The IPNS name to watch."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		id, err := namesys.ParseIpnsName(req.Arguments[0])
+		if err != nil {
+			return cmds.Errorf(cmds.ErrClient, "invalid IPNS name: %s", err)
+		}
+
+		// Remember the current record if we can find one. If we can't, the
+		// republisher will keep looking for it.
+		ctx, cancel := context.WithTimeout(req.Context, republisher.WatchLookupTimeout)
+		defer cancel()
+
+		var entry *pb.IpnsEntry
+		if val, err := nd.Routing.GetValue(ctx, ipns.RecordKey(id)); err == nil {
+			entry, err = namesys.UnmarshalRecord(val)
+			if err == nil {
+				_, err = namesys.ValidateRecord(ctx, nd.Routing, id, entry)
+			}
+			if err != nil {
+				log.Debugf("ignoring invalid record for %s: %s", id, err)
+				entry = nil
+			}
+		}
+
+		if err := republisher.Watch(nd.Repo.Datastore(), id, entry); err != nil {
+			return err
+		}
+
+		return cmds.EmitOnce(res, watchedName(id, entry))
+	},
+	Type: WatchedName{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, wn *WatchedName) error {
+			_, err := fmt.Fprintf(w, "watching %s\n", wn.Name)
+			return err
+		}),
+	},
+}
+
+var ipnsWatchRmCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Remove a name from the list of watched names.",
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("name", true, false, "The IPNS name to stop watching."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		id, err := namesys.ParseIpnsName(req.Arguments[0])
+		if err != nil {
+			return cmds.Errorf(cmds.ErrClient, "invalid IPNS name: %s", err)
+		}
+
+		if err := republisher.Unwatch(nd.Repo.Datastore(), id); err != nil {
+			if err == republisher.ErrNotWatched {
+				return cmds.Errorf(cmds.ErrClient, "%s: %s", id.Pretty(), err)
+			}
+			return err
+		}
+
+		return cmds.EmitOnce(res, &WatchedName{Name: "/ipns/" + id.Pretty()})
+	},
+	Type: WatchedName{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, wn *WatchedName) error {
+			_, err := fmt.Fprintf(w, "stopped watching %s\n", wn.Name)
+			return err
+		}),
+	},
+}
+
+var ipnsWatchLsCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "List watched names and their latest known values.",
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		watched, err := republisher.Watched(nd.Repo.Datastore())
+		if err != nil {
+			return err
+		}
+
+		out := &WatchedNameList{Names: make([]WatchedName, 0, len(watched))}
+		for id, entry := range watched {
+			out.Names = append(out.Names, *watchedName(id, entry))
+		}
+		sort.Slice(out.Names, func(i, j int) bool {
+			return out.Names[i].Name < out.Names[j].Name
+		})
+
+		return cmds.EmitOnce(res, out)
+	},
+	Type: WatchedNameList{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, list *WatchedNameList) error {
+			tw := tabwriter.NewWriter(w, 1, 2, 1, ' ', 0)
+			for _, wn := range list.Names {
+				value := wn.Value
+				if value == "" {
+					value = "(no record yet)"
+				}
+				fmt.Fprintf(tw, "%s\t%d\t%s\n", wn.Name, wn.Sequence, value)
+			}
+			return tw.Flush()
+		}),
+	},
+}
+
+func watchedName(id peer.ID, entry *pb.IpnsEntry) *WatchedName {
+	return &WatchedName{
+		Name:     "/ipns/" + id.Pretty(),
+		Value:    string(entry.GetValue()),
+		Sequence: entry.GetSequence(),
+	}
+}
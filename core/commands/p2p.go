@@ -13,7 +13,9 @@ import (
 	core "github.com/ipfs/go-ipfs/core"
 	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
 	p2p "github.com/ipfs/go-ipfs/p2p"
+	repo "github.com/ipfs/go-ipfs/repo"
 
+	humanize "github.com/dustin/go-humanize"
 	cmds "github.com/ipfs/go-ipfs-cmds"
 	peer "github.com/libp2p/go-libp2p-core/peer"
 	pstore "github.com/libp2p/go-libp2p-core/peerstore"
@@ -30,6 +32,13 @@ type P2PListenerInfoOutput struct {
 	Protocol      string
 	ListenAddress string
 	TargetAddress string
+
+	AllowedPeers []string `json:",omitempty"`
+	DeniedPeers  []string `json:",omitempty"`
+
+	MaxStreams  int           `json:",omitempty"`
+	Bandwidth   int64         `json:",omitempty"`
+	IdleTimeout time.Duration `json:",omitempty"`
 }
 
 // P2PStreamInfoOutput is output type of streams command
@@ -38,6 +47,11 @@ type P2PStreamInfoOutput struct {
 	Protocol      string
 	OriginAddress string
 	TargetAddress string
+
+	BytesIn  uint64
+	BytesOut uint64
+	Duration time.Duration
+	Idle     time.Duration
 }
 
 // P2PLsOutput is output type of ls command
@@ -53,8 +67,21 @@ type P2PStreamsOutput struct {
 const (
 	allowCustomProtocolOptionName = "allow-custom-protocol"
 	reportPeerIDOptionName        = "report-peer-id"
+	allowPeerOptionName           = "allow-peer"
+	denyPeerOptionName            = "deny-peer"
+	persistOptionName             = "persist"
+	maxStreamsOptionName          = "max-streams"
+	bandwidthOptionName           = "bandwidth"
+	idleTimeoutOptionName         = "idle-timeout"
 )
 
+// p2pLimitsOptions are the options of the commands creating listeners
+var p2pLimitsOptions = []cmds.Option{
+	cmds.IntOption(maxStreamsOptionName, "Maximum number of concurrent streams (0 for no limit)."),
+	cmds.StringOption(bandwidthOptionName, "Maximum rate in each direction, shared by all the streams, in bytes per second (e.g. 1MB)."),
+	cmds.StringOption(idleTimeoutOptionName, "Close streams without traffic for this long (e.g. 10m)."),
+}
+
 var resolveTimeout = 10 * time.Second
 
 // P2PCmd is the 'ipfs p2p' command
@@ -86,10 +113,20 @@ Forward connections made to <listen-address> to <target-address>.
 <protocol> specifies the libp2p protocol name to use for libp2p
 connections and/or handlers. It must be prefixed with '` + P2PProtoPrefix + `'.
 
+<listen-address> can be a TCP or UDP address, or a unix socket path as
+/unix/<path>. UDP datagrams are forwarded over one libp2p stream per client,
+so the service must listen on UDP too.
+
+Use --max-streams, --bandwidth and --idle-timeout to bound the resources used
+by the forwarded connections.
+
 Example:
   ipfs p2p forward ` + P2PProtoPrefix + `myproto /ip4/127.0.0.1/tcp/4567 /ipfs/QmPeer
     - Forward connections to 127.0.0.1:4567 to '` + P2PProtoPrefix + `myproto' service on /ipfs/QmPeer
 
+  ipfs p2p forward ` + P2PProtoPrefix + `dns /ip4/127.0.0.1/udp/5353 /ipfs/QmPeer
+    - Forward DNS queries sent to 127.0.0.1:5353 to '` + P2PProtoPrefix + `dns' service on /ipfs/QmPeer
+
 `,
 	},
 	Arguments: []cmds.Argument{
@@ -97,9 +134,10 @@ Example:
 		cmds.StringArg("listen-address", true, false, "Listening endpoint."),
 		cmds.StringArg("target-address", true, false, "Target endpoint."),
 	},
-	Options: []cmds.Option{
+	Options: append([]cmds.Option{
 		cmds.BoolOption(allowCustomProtocolOptionName, "Don't require /x/ prefix"),
-	},
+		cmds.BoolOption(persistOptionName, "Save the forward in the config, to create it again when the daemon starts."),
+	}, p2pLimitsOptions...),
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		n, err := p2pGetNode(env)
 		if err != nil {
@@ -128,7 +166,39 @@ Example:
 			return errors.New("protocol name must be within '" + P2PProtoPrefix + "' namespace")
 		}
 
-		return forwardLocal(n.Context(), n.P2P, n.Peerstore, proto, listen, targets)
+		limitsCfg, limits, err := p2pLimits(req)
+		if err != nil {
+			return err
+		}
+
+		if err := forwardLocal(n.Context(), n.P2P, n.Peerstore, proto, listen, targets, limits); err != nil {
+			return err
+		}
+
+		if persist, _ := req.Options[persistOptionName].(bool); persist {
+			// Forwards are restored without resolving /dnsaddr addresses.
+			target := "/ipfs/" + targets.ID.Pretty()
+			if maddr, err := ma.NewMultiaddr(targetOpt); err == nil {
+				if _, err := peer.AddrInfoFromP2pAddr(maddr); err == nil {
+					target = targetOpt
+				}
+			}
+			return p2pUpdateConfig(n, func(cfg *p2p.Config) {
+				forwards := cfg.Forwards[:0]
+				for _, f := range cfg.Forwards {
+					if f.ListenAddress != listen.String() {
+						forwards = append(forwards, f)
+					}
+				}
+				cfg.Forwards = append(forwards, p2p.ForwardConfig{
+					Protocol:      string(proto),
+					ListenAddress: listen.String(),
+					TargetAddress: target,
+					LimitsConfig:  limitsCfg,
+				})
+			})
+		}
+		return nil
 	},
 }
 
@@ -186,20 +256,45 @@ Create libp2p service and forward connections made to <target-address>.
 
 <protocol> specifies the libp2p handler name. It must be prefixed with '` + P2PProtoPrefix + `'.
 
+<target-address> can be a TCP or UDP address, or a unix socket path as
+/unix/<path>.
+
+By default, any peer can open streams to the service. Use --allow-peer to
+only accept the given peers, and --deny-peer to reject some peers. Both take a
+comma separated list of peer IDs.
+
+Use --max-streams, --bandwidth and --idle-timeout to bound the resources used
+by the incoming streams, so a single service can't starve the node.
+
 Example:
   ipfs p2p listen ` + P2PProtoPrefix + `myproto /ip4/127.0.0.1/tcp/1234
     - Forward connections to 'myproto' libp2p service to 127.0.0.1:1234
 
+  ipfs p2p listen --allow-peer=QmPeer1,QmPeer2 ` + P2PProtoPrefix + `myproto /ip4/127.0.0.1/tcp/1234
+    - Same, accepting connections from QmPeer1 and QmPeer2 only
+
+  ipfs p2p listen --max-streams=10 --bandwidth=1MB ` + P2PProtoPrefix + `myproto /ip4/127.0.0.1/tcp/1234
+    - Same, with at most 10 streams forwarding up to 1MB/s in each direction
+
+  ipfs p2p listen ` + P2PProtoPrefix + `dns /ip4/127.0.0.1/udp/53
+    - Forward DNS queries sent to 'dns' libp2p service to 127.0.0.1:53
+
+  ipfs p2p listen ` + P2PProtoPrefix + `myapp /unix/run/myapp.sock
+    - Forward connections to 'myapp' libp2p service to the /run/myapp.sock socket
+
 `,
 	},
 	Arguments: []cmds.Argument{
 		cmds.StringArg("protocol", true, false, "Protocol name."),
 		cmds.StringArg("target-address", true, false, "Target endpoint."),
 	},
-	Options: []cmds.Option{
+	Options: append([]cmds.Option{
 		cmds.BoolOption(allowCustomProtocolOptionName, "Don't require /x/ prefix"),
 		cmds.BoolOption(reportPeerIDOptionName, "r", "Send remote base58 peerid to target when a new connection is established"),
-	},
+		cmds.StringOption(allowPeerOptionName, "Comma separated list of the only peers allowed to connect"),
+		cmds.StringOption(denyPeerOptionName, "Comma separated list of peers not allowed to connect"),
+		cmds.BoolOption(persistOptionName, "Save the listener in the config, to create it again when the daemon starts."),
+	}, p2pLimitsOptions...),
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		n, err := p2pGetNode(env)
 		if err != nil {
@@ -228,14 +323,85 @@ Example:
 			return errors.New("protocol name must be within '" + P2PProtoPrefix + "' namespace")
 		}
 
-		_, err = n.P2P.ForwardRemote(n.Context(), proto, target, reportPeerID)
-		return err
+		limitsCfg, limits, err := p2pLimits(req)
+		if err != nil {
+			return err
+		}
+
+		allow, _ := req.Options[allowPeerOptionName].(string)
+		deny, _ := req.Options[denyPeerOptionName].(string)
+		lcfg := p2p.ListenerConfig{
+			Protocol:      string(proto),
+			TargetAddress: target.String(),
+			ReportPeerID:  reportPeerID,
+			AllowPeers:    splitList(allow),
+			DenyPeers:     splitList(deny),
+			LimitsConfig:  limitsCfg,
+		}
+		acl, err := lcfg.ACL()
+		if err != nil {
+			return err
+		}
+
+		if _, err := n.P2P.ForwardRemote(n.Context(), proto, target, reportPeerID, acl, limits); err != nil {
+			return err
+		}
+
+		if persist, _ := req.Options[persistOptionName].(bool); persist {
+			return p2pUpdateConfig(n, func(cfg *p2p.Config) {
+				listeners := cfg.Listeners[:0]
+				for _, l := range cfg.Listeners {
+					if l.Protocol != lcfg.Protocol {
+						listeners = append(listeners, l)
+					}
+				}
+				cfg.Listeners = append(listeners, lcfg)
+			})
+		}
+		return nil
 	},
 }
 
+// p2pLimits reads the stream limits options
+func p2pLimits(req *cmds.Request) (p2p.LimitsConfig, p2p.Limits, error) {
+	var cfg p2p.LimitsConfig
+	cfg.MaxStreams, _ = req.Options[maxStreamsOptionName].(int)
+	cfg.Bandwidth, _ = req.Options[bandwidthOptionName].(string)
+	cfg.IdleTimeout, _ = req.Options[idleTimeoutOptionName].(string)
+
+	limits, err := cfg.Limits()
+	return cfg, limits, err
+}
+
+// splitList splits a comma separated list
+func splitList(list string) []string {
+	var out []string
+	for _, s := range strings.Split(list, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// p2pUpdateConfig updates the "P2P" config section, which declares the
+// forwards and listeners created when the daemon starts
+func p2pUpdateConfig(n *core.IpfsNode, update func(*p2p.Config)) error {
+	var cfg p2p.Config
+	if err := repo.ConfigSection(n.Repo, "P2P", &cfg); err != nil {
+		return err
+	}
+	update(&cfg)
+	return n.Repo.SetConfigKey("P2P", cfg)
+}
+
 // checkPort checks whether target multiaddr contains tcp or udp protocol
-// and whether the port is equal to 0
+// and whether the port is equal to 0. Unix socket addresses have no port.
 func checkPort(target ma.Multiaddr) error {
+	if _, err := target.ValueForProtocol(ma.P_UNIX); err == nil {
+		return nil
+	}
+
 	// get tcp or udp port from multiaddr
 	getPort := func() (string, error) {
 		sport, _ := target.ValueForProtocol(ma.P_TCP)
@@ -247,7 +413,7 @@ func checkPort(target ma.Multiaddr) error {
 		if sport != "" {
 			return sport, nil
 		}
-		return "", fmt.Errorf("address does not contain tcp or udp protocol")
+		return "", fmt.Errorf("address does not contain tcp, udp or unix protocol")
 	}
 
 	sport, err := getPort()
@@ -268,10 +434,10 @@ func checkPort(target ma.Multiaddr) error {
 }
 
 // forwardLocal forwards local connections to a libp2p service
-func forwardLocal(ctx context.Context, p *p2p.P2P, ps pstore.Peerstore, proto protocol.ID, bindAddr ma.Multiaddr, addr *peer.AddrInfo) error {
+func forwardLocal(ctx context.Context, p *p2p.P2P, ps pstore.Peerstore, proto protocol.ID, bindAddr ma.Multiaddr, addr *peer.AddrInfo, limits p2p.Limits) error {
 	ps.AddAddrs(addr.ID, addr.Addrs, pstore.TempAddrTTL)
 	// TODO: return some info
-	_, err := p.ForwardLocal(ctx, addr.ID, proto, bindAddr)
+	_, err := p.ForwardLocal(ctx, addr.ID, proto, bindAddr, limits)
 	return err
 }
 
@@ -296,21 +462,34 @@ var p2pLsCmd = &cmds.Command{
 
 		n.P2P.ListenersLocal.Lock()
 		for _, listener := range n.P2P.ListenersLocal.Listeners {
-			output.Listeners = append(output.Listeners, P2PListenerInfoOutput{
+			info := P2PListenerInfoOutput{
 				Protocol:      string(listener.Protocol()),
 				ListenAddress: listener.ListenAddress().String(),
 				TargetAddress: listener.TargetAddress().String(),
-			})
+			}
+			addListenerLimits(&info, listener)
+			output.Listeners = append(output.Listeners, info)
 		}
 		n.P2P.ListenersLocal.Unlock()
 
 		n.P2P.ListenersP2P.Lock()
 		for _, listener := range n.P2P.ListenersP2P.Listeners {
-			output.Listeners = append(output.Listeners, P2PListenerInfoOutput{
+			info := P2PListenerInfoOutput{
 				Protocol:      string(listener.Protocol()),
 				ListenAddress: listener.ListenAddress().String(),
 				TargetAddress: listener.TargetAddress().String(),
-			})
+			}
+			if l, ok := listener.(interface{ ACL() p2p.PeerACL }); ok {
+				acl := l.ACL()
+				for _, p := range acl.Allow {
+					info.AllowedPeers = append(info.AllowedPeers, p.Pretty())
+				}
+				for _, p := range acl.Deny {
+					info.DeniedPeers = append(info.DeniedPeers, p.Pretty())
+				}
+			}
+			addListenerLimits(&info, listener)
+			output.Listeners = append(output.Listeners, info)
 		}
 		n.P2P.ListenersP2P.Unlock()
 
@@ -326,7 +505,23 @@ var p2pLsCmd = &cmds.Command{
 					fmt.Fprintln(tw, "Protocol\tListen Address\tTarget Address")
 				}
 
-				fmt.Fprintf(tw, "%s\t%s\t%s\n", listener.Protocol, listener.ListenAddress, listener.TargetAddress)
+				fmt.Fprintf(tw, "%s\t%s\t%s", listener.Protocol, listener.ListenAddress, listener.TargetAddress)
+				if len(listener.AllowedPeers) > 0 {
+					fmt.Fprintf(tw, "\tallow=%s", strings.Join(listener.AllowedPeers, ","))
+				}
+				if len(listener.DeniedPeers) > 0 {
+					fmt.Fprintf(tw, "\tdeny=%s", strings.Join(listener.DeniedPeers, ","))
+				}
+				if listener.MaxStreams > 0 {
+					fmt.Fprintf(tw, "\tmax-streams=%d", listener.MaxStreams)
+				}
+				if listener.Bandwidth > 0 {
+					fmt.Fprintf(tw, "\tbandwidth=%s/s", humanize.Bytes(uint64(listener.Bandwidth)))
+				}
+				if listener.IdleTimeout > 0 {
+					fmt.Fprintf(tw, "\tidle-timeout=%s", listener.IdleTimeout)
+				}
+				fmt.Fprintln(tw)
 			}
 			tw.Flush()
 
@@ -335,6 +530,16 @@ var p2pLsCmd = &cmds.Command{
 	},
 }
 
+// addListenerLimits fills the limits of listener in info
+func addListenerLimits(info *P2PListenerInfoOutput, listener p2p.Listener) {
+	if l, ok := listener.(interface{ Limits() p2p.Limits }); ok {
+		limits := l.Limits()
+		info.MaxStreams = limits.MaxStreams
+		info.Bandwidth = limits.Bandwidth
+		info.IdleTimeout = limits.IdleTimeout
+	}
+}
+
 const (
 	p2pAllOptionName           = "all"
 	p2pProtocolOptionName      = "protocol"
@@ -351,6 +556,7 @@ var p2pCloseCmd = &cmds.Command{
 		cmds.StringOption(p2pProtocolOptionName, "p", "Match protocol name"),
 		cmds.StringOption(p2pListenAddressOptionName, "l", "Match listen address"),
 		cmds.StringOption(p2pTargetAddressOptionName, "t", "Match target address"),
+		cmds.BoolOption(persistOptionName, "Also remove the closed listeners from the config."),
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		n, err := p2pGetNode(env)
@@ -407,8 +613,42 @@ var p2pCloseCmd = &cmds.Command{
 			return true
 		}
 
-		done := n.P2P.ListenersLocal.Close(match)
-		done += n.P2P.ListenersP2P.Close(match)
+		var closed []p2p.Listener
+		collect := func(listener p2p.Listener) bool {
+			if match(listener) {
+				closed = append(closed, listener)
+				return true
+			}
+			return false
+		}
+
+		done := n.P2P.ListenersLocal.Close(collect)
+		localClosed := closed
+		closed = nil
+		done += n.P2P.ListenersP2P.Close(collect)
+
+		if persist, _ := req.Options[persistOptionName].(bool); persist {
+			err := p2pUpdateConfig(n, func(cfg *p2p.Config) {
+				forwards := cfg.Forwards[:0]
+				for _, f := range cfg.Forwards {
+					if !matchesListenAddress(localClosed, f.ListenAddress) {
+						forwards = append(forwards, f)
+					}
+				}
+				cfg.Forwards = forwards
+
+				listeners := cfg.Listeners[:0]
+				for _, l := range cfg.Listeners {
+					if !matchesProtocol(closed, l.Protocol) {
+						listeners = append(listeners, l)
+					}
+				}
+				cfg.Listeners = listeners
+			})
+			if err != nil {
+				return err
+			}
+		}
 
 		return cmds.EmitOnce(res, done)
 	},
@@ -441,9 +681,14 @@ var p2pStreamCmd = &cmds.Command{
 var p2pStreamLsCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
 		Tagline: "List active p2p streams.",
+		ShortDescription: `
+Lists the active p2p streams. With -v, also prints the traffic of each stream:
+the bytes received from (In) and sent to (Out) the remote peer, how long it has
+been open and how long it has been without traffic.
+`,
 	},
 	Options: []cmds.Option{
-		cmds.BoolOption(p2pHeadersOptionName, "v", "Print table headers (ID, Protocol, Local, Remote)."),
+		cmds.BoolOption(p2pHeadersOptionName, "v", "Print table headers (ID, Protocol, Local, Remote) and traffic statistics."),
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		n, err := p2pGetNode(env)
@@ -462,6 +707,11 @@ var p2pStreamLsCmd = &cmds.Command{
 
 				OriginAddress: s.OriginAddr.String(),
 				TargetAddress: s.TargetAddr.String(),
+
+				BytesIn:  s.BytesIn(),
+				BytesOut: s.BytesOut(),
+				Duration: s.Duration(),
+				Idle:     s.Idle(),
 			})
 		}
 		n.P2P.Streams.Unlock()
@@ -475,7 +725,10 @@ var p2pStreamLsCmd = &cmds.Command{
 			tw := tabwriter.NewWriter(w, 1, 2, 1, ' ', 0)
 			for _, stream := range out.Streams {
 				if headers {
-					fmt.Fprintln(tw, "ID\tProtocol\tOrigin\tTarget")
+					fmt.Fprintln(tw, "ID\tProtocol\tOrigin\tTarget\tIn\tOut\tDuration\tIdle")
+					fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", stream.HandlerID, stream.Protocol, stream.OriginAddress, stream.TargetAddress,
+						humanize.Bytes(stream.BytesIn), humanize.Bytes(stream.BytesOut), stream.Duration.Round(time.Second), stream.Idle.Round(time.Second))
+					continue
 				}
 
 				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", stream.HandlerID, stream.Protocol, stream.OriginAddress, stream.TargetAddress)
@@ -538,6 +791,24 @@ var p2pStreamCloseCmd = &cmds.Command{
 	},
 }
 
+func matchesListenAddress(listeners []p2p.Listener, addr string) bool {
+	for _, l := range listeners {
+		if l.ListenAddress().String() == addr {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesProtocol(listeners []p2p.Listener, proto string) bool {
+	for _, l := range listeners {
+		if string(l.Protocol()) == proto {
+			return true
+		}
+	}
+	return false
+}
+
 func p2pGetNode(env cmds.Environment) (*core.IpfsNode, error) {
 	nd, err := cmdenv.GetNode(env)
 	if err != nil {
@@ -0,0 +1,81 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+	loader "github.com/ipfs/go-ipfs/plugin/loader"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+var PluginCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Inspect the plugins of the node.",
+	},
+	Subcommands: map[string]*cmds.Command{
+		"ls": pluginLsCmd,
+	},
+}
+
+type PluginLsOutput struct {
+	Plugins []loader.PluginInfo
+}
+
+var pluginLsCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "List the plugins of the node.",
+		ShortDescription: `
+Lists the preloaded and external plugins, with their version, the plugin
+interfaces they implement and their state, along with the error of the
+plugins that failed.
+`,
+		LongDescription: `
+Lists the preloaded and external plugins, with their version, the plugin
+interfaces they implement and their state, along with the error of the
+plugins that failed.
+
+When the daemon is running, its plugins are listed. The states are:
+
+  disabled      disabled in the Plugins config section.
+  loaded        loaded, but not initialized yet.
+  initialized   initialized, but not hooked into the node yet.
+  injected      hooked into the node.
+  started       a daemon plugin running in the daemon.
+  closed        a daemon plugin stopped.
+  failed        the plugin failed to load, initialize, inject or start.
+
+By default, a plugin failing is fatal. When Plugins.NonFatal is set to true
+in the config, or the IPFS_PLUGINS_NONFATAL environment variable, failing
+plugins are skipped instead.
+`,
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		plugins, err := cmdenv.GetPlugins(env)
+		if err != nil {
+			return err
+		}
+		return cmds.EmitOnce(res, &PluginLsOutput{Plugins: plugins.Plugins()})
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *PluginLsOutput) error {
+			tw := tabwriter.NewWriter(w, 4, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "Name\tVersion\tPreloaded\tState\tInterfaces")
+			for _, p := range out.Plugins {
+				fmt.Fprintf(tw, "%s\t%s\t%t\t%s\t%s\n", p.Name, p.Version, p.Preloaded, p.State, strings.Join(p.Interfaces, ","))
+			}
+			tw.Flush()
+
+			for _, p := range out.Plugins {
+				if p.Error != "" {
+					fmt.Fprintf(w, "%s failed: %s\n", p.Name, p.Error)
+				}
+			}
+			return nil
+		}),
+	},
+	Type: PluginLsOutput{},
+}
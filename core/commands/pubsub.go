@@ -7,11 +7,16 @@ import (
 	"io"
 	"net/http"
 	"sort"
+	"time"
 
 	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+	repo "github.com/ipfs/go-ipfs/repo"
+	topics "github.com/ipfs/go-ipfs/topics"
 
 	cmds "github.com/ipfs/go-ipfs-cmds"
+	coreiface "github.com/ipfs/interface-go-ipfs-core"
 	options "github.com/ipfs/interface-go-ipfs-core/options"
+	peer "github.com/libp2p/go-libp2p-core/peer"
 )
 
 var PubsubCmd = &cmds.Command{
@@ -28,15 +33,19 @@ To use, the daemon must be run with '--enable-pubsub-experiment'.
 `,
 	},
 	Subcommands: map[string]*cmds.Command{
-		"pub":   PubsubPubCmd,
-		"sub":   PubsubSubCmd,
-		"ls":    PubsubLsCmd,
-		"peers": PubsubPeersCmd,
+		"pub":     PubsubPubCmd,
+		"sub":     PubsubSubCmd,
+		"ls":      PubsubLsCmd,
+		"peers":   PubsubPeersCmd,
+		"history": PubsubHistoryCmd,
 	},
 }
 
 const (
 	pubsubDiscoverOptionName = "discover"
+	pubsubFromOptionName     = "from"
+	pubsubVerifyOptionName   = "verify"
+	pubsubSinceOptionName    = "since"
 )
 
 type pubsubMessage struct {
@@ -65,6 +74,23 @@ to be used in a production environment.
 
 To use, the daemon must be run with '--enable-pubsub-experiment'.
 
+Use --from to only receive the messages of some peers, and --verify to drop
+the messages that aren't signed by their author.
+
+Validators may be configured per topic in the 'PubsubTopics' config section,
+keyed by topic name, with:
+  * "MaxMessageSize": the maximum size of the data of a message, in bytes
+  * "Schema": a JSON schema the data of every message must conform to
+They are registered with the pubsub router when subscribing to the topic. The
+messages failing them are neither received nor forwarded to other peers.
+
+The messages of a topic may also be logged in the datastore, by setting "Log"
+in the settings of the topic, with "MaxMessages" and/or "MaxAge" (e.g. "24h")
+limits. The node then records the messages of the topic from the time it
+starts. Use --since to first replay the logged messages, from a position in
+the log (as listed by 'ipfs pubsub history'), a time in RFC 3339 format, or a
+duration before now such as "1h".
+
 This command outputs data in the following encodings:
   * "json"
 (Specified by the "--encoding" or "--enc" flag)
@@ -75,8 +101,16 @@ This command outputs data in the following encodings:
 	},
 	Options: []cmds.Option{
 		cmds.BoolOption(pubsubDiscoverOptionName, "try to discover other peers subscribed to the same topic"),
+		cmds.StringOption(pubsubFromOptionName, "Comma separated list of the only peers to receive messages from."),
+		cmds.BoolOption(pubsubVerifyOptionName, "Drop the messages that aren't signed by their author."),
+		cmds.StringOption(pubsubSinceOptionName, "Replay the logged messages from this log position, time or duration before now."),
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
 		api, err := cmdenv.GetApi(env, req)
 		if err != nil {
 			return err
@@ -84,6 +118,51 @@ This command outputs data in the following encodings:
 
 		topic := req.Arguments[0]
 		discover, _ := req.Options[pubsubDiscoverOptionName].(bool)
+		verify, _ := req.Options[pubsubVerifyOptionName].(bool)
+
+		var from map[peer.ID]bool
+		if list, ok := req.Options[pubsubFromOptionName].(string); ok {
+			from = make(map[peer.ID]bool)
+			for _, s := range splitList(list) {
+				p, err := peer.IDB58Decode(s)
+				if err != nil {
+					return fmt.Errorf("invalid peer ID %q: %s", s, err)
+				}
+				from[p] = true
+			}
+		}
+
+		var since *topics.Since
+		if s, ok := req.Options[pubsubSinceOptionName].(string); ok {
+			parsed, err := topics.ParseSince(s)
+			if err != nil {
+				return err
+			}
+			since = &parsed
+		}
+
+		if n.Topics != nil {
+			var cfg topics.Config
+			if err := repo.ConfigSection(n.Repo, topics.ConfigKey, &cfg); err != nil {
+				return err
+			}
+			tcfg := cfg[topic]
+
+			release, err := n.Topics.Acquire(topic, tcfg)
+			if err != nil {
+				return err
+			}
+			defer release()
+
+			if tcfg.Log != nil {
+				if err := n.Topics.StartLog(topic, tcfg); err != nil {
+					return err
+				}
+			}
+		}
+		if since != nil && (n.Topics == nil || !n.Topics.Logging(topic)) {
+			return fmt.Errorf("messages of topic %s are not logged, see the %s config section", topic, topics.ConfigKey)
+		}
 
 		sub, err := api.PubSub().Subscribe(req.Context, topic, options.PubSub.Discover(discover))
 		if err != nil {
@@ -95,6 +174,45 @@ This command outputs data in the following encodings:
 			f.Flush()
 		}
 
+		emit := func(msg coreiface.PubSubMessage) error {
+			if from != nil && !from[msg.From()] {
+				return nil
+			}
+			if verify {
+				sm, ok := msg.(topics.SignedMessage)
+				if !ok {
+					return nil
+				}
+				if err := topics.Verify(sm); err != nil {
+					log.Debugf("pubsub sub: dropping message from %s: %s", msg.From(), err)
+					return nil
+				}
+			}
+
+			return res.Emit(&pubsubMessage{
+				Data:     msg.Data(),
+				From:     []byte(msg.From()),
+				Seqno:    msg.Seq(),
+				TopicIDs: msg.Topics(),
+			})
+		}
+
+		// Replay the log once subscribed, skipping the live messages
+		// that were replayed already.
+		replayed := make(map[string]bool)
+		if since != nil {
+			entries, err := topics.ReadLog(n.Repo.Datastore(), topic, *since)
+			if err != nil {
+				return err
+			}
+			for i := range entries {
+				replayed[entries[i].ID()] = true
+				if err := emit(entries[i].Message()); err != nil {
+					return err
+				}
+			}
+		}
+
 		for {
 			msg, err := sub.Next(req.Context)
 			if err == io.EOF || err == context.Canceled {
@@ -103,12 +221,10 @@ This command outputs data in the following encodings:
 				return err
 			}
 
-			if err := res.Emit(&pubsubMessage{
-				Data:     msg.Data(),
-				From:     []byte(msg.From()),
-				Seqno:    msg.Seq(),
-				TopicIDs: msg.Topics(),
-			}); err != nil {
+			if len(replayed) > 0 && replayed[string(msg.From())+string(msg.Seq())] {
+				continue
+			}
+			if err := emit(msg); err != nil {
 				return err
 			}
 		}
@@ -261,3 +377,73 @@ To use, the daemon must be run with '--enable-pubsub-experiment'.
 		cmds.Text: cmds.MakeTypedEncoder(stringListEncoder),
 	},
 }
+
+// PubsubHistoryEntry is an entry of the message log of a topic
+type PubsubHistoryEntry struct {
+	Seq      uint64
+	Received time.Time
+	From     string
+	Data     []byte
+	Seqno    []byte
+	Signed   bool
+}
+
+var PubsubHistoryCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "List the logged messages of a topic.",
+		ShortDescription: `
+ipfs pubsub history lists the messages of a topic logged in the datastore,
+oldest first, with their position in the log, the time they were received and
+their author. Messages are only logged for the topics whose settings in the
+'PubsubTopics' config section enable "Log".
+
+This is an experimental feature. It is not intended in its current state
+to be used in a production environment.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("topic", true, false, "Topic to list the logged messages of."),
+	},
+	Options: []cmds.Option{
+		cmds.StringOption(pubsubSinceOptionName, "Only list the messages from this log position, time or duration before now."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		var since topics.Since
+		if s, ok := req.Options[pubsubSinceOptionName].(string); ok {
+			if since, err = topics.ParseSince(s); err != nil {
+				return err
+			}
+		}
+
+		entries, err := topics.ReadLog(n.Repo.Datastore(), req.Arguments[0], since)
+		if err != nil {
+			return err
+		}
+
+		for _, e := range entries {
+			if err := res.Emit(&PubsubHistoryEntry{
+				Seq:      e.Seq,
+				Received: e.Received,
+				From:     peer.ID(e.From).Pretty(),
+				Data:     e.Data,
+				Seqno:    e.Seqno,
+				Signed:   len(e.Signature) > 0,
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Type: PubsubHistoryEntry{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, e *PubsubHistoryEntry) error {
+			_, err := fmt.Fprintf(w, "%d %s %s %s\n", e.Seq, e.Received.Format(time.RFC3339), e.From, e.Data)
+			return err
+		}),
+	},
+}
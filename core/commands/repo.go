@@ -2,23 +2,29 @@ package commands
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"runtime"
 	"strings"
 	"sync"
 	"text/tabwriter"
+	"time"
 
 	humanize "github.com/dustin/go-humanize"
 	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+	e "github.com/ipfs/go-ipfs/core/commands/e"
 	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
 	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
+	mfsr "github.com/ipfs/go-ipfs/repo/fsrepo/migrations"
 
 	cid "github.com/ipfs/go-cid"
 	bstore "github.com/ipfs/go-ipfs-blockstore"
 	cmds "github.com/ipfs/go-ipfs-cmds"
+	config "github.com/ipfs/go-ipfs-config"
 )
 
 type RepoVersion struct {
@@ -39,6 +45,10 @@ var RepoCmd = &cmds.Command{
 		"fsck":    repoFsckCmd,
 		"version": repoVersionCmd,
 		"verify":  repoVerifyCmd,
+		"convert": repoConvertCmd,
+		"backup":  repoBackupCmd,
+		"restore": repoRestoreCmd,
+		"migrate": repoMigrateCmd,
 	},
 }
 
@@ -370,3 +380,334 @@ var repoVersionCmd = &cmds.Command{
 		}),
 	},
 }
+
+// RepoConvertOutput is the progress of "repo convert".
+type RepoConvertOutput struct {
+	Copied  uint64
+	Resumed uint64
+	Total   uint64
+	// Backup is where the previous datastore was moved, it is only set once
+	// the conversion is done.
+	Backup string `json:",omitempty"`
+}
+
+const repoConvertToOptionName = "to"
+
+var repoConvertCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Convert the repo to another datastore.",
+		ShortDescription: `
+'ipfs repo convert' copies the content of the datastore to a new datastore,
+then switches the repo to it.
+`,
+		LongDescription: `
+'ipfs repo convert' copies the content of the datastore to a new datastore,
+then switches the repo to it. The daemon must not be running.
+
+The new datastore is given by --to, either as the name of a config profile
+setting Datastore.Spec, such as 'badgerds' or 'flatfs', or as the path of a
+JSON file holding the spec.
+
+The new datastore is built in the 'datastore-convert' directory of the repo.
+Once all keys are copied and counted, the previous datastore is moved to the
+'datastore-backup' directory, and the config and 'datastore_spec' are updated.
+The backup can be removed once the repo has been checked to work.
+
+An interrupted conversion is resumed by running the command again with the
+same target. If it was interrupted while swapping the datastores, the repo
+can't be used until the conversion is finished this way.
+`,
+	},
+	Options: []cmds.Option{
+		cmds.StringOption(repoConvertToOptionName, "Profile name or spec file of the new datastore."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		cfgRoot, err := cmdenv.GetConfigRoot(env)
+		if err != nil {
+			return err
+		}
+
+		to, _ := req.Options[repoConvertToOptionName].(string)
+		if to == "" {
+			return fmt.Errorf("missing --%s, the new datastore", repoConvertToOptionName)
+		}
+		spec, err := convertTarget(cfgRoot, to)
+		if err != nil {
+			return err
+		}
+
+		var emitErr error
+		backup, err := fsrepo.Convert(cfgRoot, spec, func(p fsrepo.ConvertProgress) {
+			if emitErr == nil {
+				emitErr = res.Emit(&RepoConvertOutput{Copied: p.Copied, Resumed: p.Resumed, Total: p.Total})
+			}
+		})
+		if err != nil {
+			return err
+		}
+		if emitErr != nil {
+			return emitErr
+		}
+		return res.Emit(&RepoConvertOutput{Backup: backup})
+	},
+	Type: RepoConvertOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *RepoConvertOutput) error {
+			if out.Backup != "" {
+				fmt.Fprintf(w, "\nconversion complete, the previous datastore was moved to %s\n", out.Backup)
+				return nil
+			}
+			fmt.Fprintf(w, "%d/%d keys copied (%d copied before)\r", out.Copied+out.Resumed, out.Total, out.Resumed)
+			return nil
+		}),
+	},
+}
+
+// convertTarget returns the datastore spec named by to: the spec a config
+// profile sets, or the content of a JSON file.
+func convertTarget(cfgRoot, to string) (map[string]interface{}, error) {
+	if profile, ok := config.Profiles[to]; ok {
+		cfg, err := fsrepo.ConfigAt(cfgRoot)
+		if err != nil {
+			return nil, err
+		}
+		if err := profile.Transform(cfg); err != nil {
+			return nil, err
+		}
+		return cfg.Datastore.Spec, nil
+	}
+
+	data, err := ioutil.ReadFile(to)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%s is neither a profile nor a spec file", to)
+		}
+		return nil, err
+	}
+	var spec map[string]interface{}
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("invalid spec file %s: %s", to, err)
+	}
+	return spec, nil
+}
+
+const repoMetadataOnlyOptionName = "metadata-only"
+
+var repoBackupCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Write a backup of the repo to an archive.",
+		ShortDescription: `
+'ipfs repo backup' writes the config, keystore and datastore of the repo to
+a tar archive, which 'ipfs repo restore' turns back into a repo.
+`,
+		LongDescription: `
+'ipfs repo backup' writes the config, keystore and datastore of the repo to
+a tar archive, which 'ipfs repo restore' turns back into a repo. It can run
+while the daemon is running: garbage collection, pinning and adding wait for
+the backup to complete, so that pins and blocks are consistent. Other writes
+are not blocked: MFS, IPNS records or keys changed during the backup may be
+archived in an intermediate state.
+
+The archive holds the private keys of the node. Keys of an encrypted keystore
+remain encrypted, keys of a remote keystore are not backed up.
+
+With --metadata-only, blocks are left out: the archive holds the pins, IPNS
+records and MFS root, but not the content they reference. Such backups are
+small enough to be taken frequently.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("dest", true, false, "Path of the archive to write."),
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption(repoMetadataOnlyOptionName, "Leave blocks out of the backup."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		cfgRoot, err := cmdenv.GetConfigRoot(env)
+		if err != nil {
+			return err
+		}
+
+		metadataOnly, _ := req.Options[repoMetadataOnlyOptionName].(bool)
+
+		pr, pw := io.Pipe()
+		go func() {
+			pw.CloseWithError(corerepo.Backup(req.Context, n, cfgRoot, pw, metadataOnly))
+		}()
+		go func() {
+			// Don't keep the GC lock if the archive isn't read anymore.
+			<-req.Context.Done()
+			pr.CloseWithError(req.Context.Err())
+		}()
+
+		return res.Emit(pr)
+	},
+	PostRun: cmds.PostRunMap{
+		cmds.CLI: func(res cmds.Response, re cmds.ResponseEmitter) error {
+			v, err := res.Next()
+			if err != nil {
+				return err
+			}
+			archive, ok := v.(io.Reader)
+			if !ok {
+				return e.New(e.TypeErr(archive, v))
+			}
+
+			dest := res.Request().Arguments[0]
+			f, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(f, archive); err != nil {
+				f.Close()
+				os.Remove(dest)
+				return err
+			}
+			if err := f.Close(); err != nil {
+				return err
+			}
+
+			fmt.Fprintf(os.Stdout, "backup written to %s\n", dest)
+			return nil
+		},
+	},
+}
+
+var repoRestoreCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Restore a repo from a backup archive.",
+		ShortDescription: `
+'ipfs repo restore' initializes the repo from an archive written by
+'ipfs repo backup'. There must be no repo yet. A failed restore leaves no
+repo behind and can be retried.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("archive", true, false, "Path of the backup archive."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		cfgRoot, err := cmdenv.GetConfigRoot(env)
+		if err != nil {
+			return err
+		}
+
+		f, err := os.Open(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		manifest, err := corerepo.Restore(cfgRoot, f)
+		if err != nil {
+			return err
+		}
+
+		msg := fmt.Sprintf("restored repo of peer %s backed up at %s\n", manifest.PeerID, manifest.Created.Format(time.RFC3339))
+		if manifest.MetadataOnly {
+			msg += "the backup holds no blocks, content will be fetched from the network\n"
+		}
+		return cmds.EmitOnce(res, &MessageOutput{msg})
+	},
+	Type: MessageOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *MessageOutput) error {
+			fmt.Fprint(w, out.Message)
+			return nil
+		}),
+	},
+}
+
+const (
+	repoMigrateToOptionName       = "to"
+	repoMigrateRevertOptionName   = "revert"
+	repoMigrateNoBackupOptionName = "no-backup"
+)
+
+var repoMigrateCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Migrate the repo to another version.",
+		ShortDescription: `
+'ipfs repo migrate' runs the migrations built into ipfs to bring the repo to
+the version this program uses, or to the version given by --to.
+`,
+		LongDescription: `
+'ipfs repo migrate' runs the migrations built into ipfs to bring the repo to
+the version this program uses, or to the version given by --to. The daemon
+must not be running.
+
+With --revert, the repo is migrated back to an older version, by default the
+previous one, so that it can be used by an older ipfs.
+
+Only migrations from version 7 on are built in: the older migrations aren't
+ported from fs-repo-migrations. Older repos must be migrated with
+fs-repo-migrations, from https://dist.ipfs.io. 'ipfs daemon --migrate' runs
+it, using the one in the PATH if any, and downloading it otherwise.
+
+The repo is copied next to itself before being migrated, unless --no-backup
+is given.
+`,
+	},
+	Options: []cmds.Option{
+		cmds.IntOption(repoMigrateToOptionName, "Version to migrate the repo to."),
+		cmds.BoolOption(repoMigrateRevertOptionName, "Migrate the repo back to an older version."),
+		cmds.BoolOption(repoMigrateNoBackupOptionName, "Don't back up the repo before migrating it."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		cfgRoot, err := cmdenv.GetConfigRoot(env)
+		if err != nil {
+			return err
+		}
+
+		current, err := mfsr.RepoPath(cfgRoot).Version()
+		if err != nil {
+			return err
+		}
+
+		revert, _ := req.Options[repoMigrateRevertOptionName].(bool)
+		to, toSet := req.Options[repoMigrateToOptionName].(int)
+		switch {
+		case !toSet && revert:
+			to = current - 1
+		case !toSet:
+			to = fsrepo.RepoVersion
+		}
+
+		switch {
+		case to == current:
+			return cmds.EmitOnce(res, &MessageOutput{fmt.Sprintf("the repo is already at version %d\n", current)})
+		case to < current && !revert:
+			return fmt.Errorf("the repo is at version %d, use --%s to migrate it back to version %d", current, repoMigrateRevertOptionName, to)
+		case to > current && revert:
+			return fmt.Errorf("the repo is at version %d, cannot revert it to version %d", current, to)
+		}
+
+		opts := []mfsr.MigrateOption{mfsr.RunMigrations(&emitWriter{res})}
+		if noBackup, _ := req.Options[repoMigrateNoBackupOptionName].(bool); noBackup {
+			opts = append(opts, mfsr.SkipBackup())
+		}
+		return fsrepo.Migrate(cfgRoot, to, opts...)
+	},
+	Type: MessageOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *MessageOutput) error {
+			fmt.Fprint(w, out.Message)
+			return nil
+		}),
+	},
+}
+
+// emitWriter emits what is written to it as messages.
+type emitWriter struct {
+	res cmds.ResponseEmitter
+}
+
+func (w *emitWriter) Write(p []byte) (int, error) {
+	if err := w.res.Emit(&MessageOutput{string(p)}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
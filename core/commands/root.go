@@ -2,12 +2,14 @@ package commands
 
 import (
 	"errors"
+	"fmt"
 
 	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
 	dag "github.com/ipfs/go-ipfs/core/commands/dag"
 	name "github.com/ipfs/go-ipfs/core/commands/name"
 	ocmd "github.com/ipfs/go-ipfs/core/commands/object"
 	unixfs "github.com/ipfs/go-ipfs/core/commands/unixfs"
+	plugin "github.com/ipfs/go-ipfs/plugin"
 
 	cmds "github.com/ipfs/go-ipfs-cmds"
 	logging "github.com/ipfs/go-log"
@@ -72,6 +74,7 @@ TOOL COMMANDS
   commands      List all available commands
   cid           Convert and discover properties of CIDs
   log           Manage and show logs of running daemon
+  plugin        Inspect the plugins of the node
 
 Use 'ipfs <command> --help' to learn more about each command.
 
@@ -138,6 +141,7 @@ var rootSubcommands = map[string]*cmds.Command{
 	"object":    ocmd.ObjectCmd,
 	"pin":       PinCmd,
 	"ping":      PingCmd,
+	"plugin":    PluginCmd,
 	"p2p":       P2PCmd,
 	"refs":      RefsCmd,
 	"resolve":   ResolveCmd,
@@ -219,6 +223,30 @@ func init() {
 	RootRO.Subcommands = rootROSubcommands
 }
 
+// AddPluginCommands adds the commands of plugins to Root, and the read-only
+// ones to RootRO. It fails if a name is taken by a built-in command or by one
+// of reserved, such as the commands only the CLI has, leaving both roots
+// untouched.
+func AddPluginCommands(commands map[string]plugin.Command, reserved map[string]*cmds.Command) error {
+	for name := range commands {
+		_, inRoot := Root.Subcommands[name]
+		_, inRootRO := RootRO.Subcommands[name]
+		_, isReserved := reserved[name]
+		if inRoot || inRootRO || isReserved {
+			return fmt.Errorf("plugin command %q conflicts with a built-in command", name)
+		}
+	}
+
+	for name, c := range commands {
+		c.Command.ProcessHelp()
+		Root.Subcommands[name] = c.Command
+		if c.ReadOnly {
+			RootRO.Subcommands[name] = c.Command
+		}
+	}
+	return nil
+}
+
 type MessageOutput struct {
 	Message string
 }
@@ -2,6 +2,10 @@ package commands
 
 import (
 	"testing"
+
+	plugin "github.com/ipfs/go-ipfs/plugin"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
 )
 
 func TestCommandTree(t *testing.T) {
@@ -20,3 +24,45 @@ func TestCommandTree(t *testing.T) {
 	printErrors(Root.DebugValidate())
 	printErrors(RootRO.DebugValidate())
 }
+
+func TestAddPluginCommands(t *testing.T) {
+	conflicting := map[string]plugin.Command{
+		"plugin-test": {Command: &cmds.Command{}},
+		"add":         {Command: &cmds.Command{}},
+	}
+	if err := AddPluginCommands(conflicting, nil); err == nil {
+		t.Fatal("expected a command conflicting with a built-in one to be rejected")
+	}
+	if _, ok := Root.Subcommands["plugin-test"]; ok {
+		t.Fatal("commands added despite a conflict")
+	}
+
+	reserved := map[string]*cmds.Command{"plugin-test": {}}
+	err := AddPluginCommands(map[string]plugin.Command{"plugin-test": {Command: &cmds.Command{}}}, reserved)
+	if err == nil {
+		t.Fatal("expected a command conflicting with a reserved one to be rejected")
+	}
+
+	err = AddPluginCommands(map[string]plugin.Command{
+		"plugin-test":    {Command: &cmds.Command{}},
+		"plugin-test-ro": {Command: &cmds.Command{}, ReadOnly: true},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		delete(Root.Subcommands, "plugin-test")
+		delete(Root.Subcommands, "plugin-test-ro")
+		delete(RootRO.Subcommands, "plugin-test-ro")
+	}()
+
+	if Root.Subcommands["plugin-test"] == nil || Root.Subcommands["plugin-test-ro"] == nil {
+		t.Error("plugin commands not added to Root")
+	}
+	if RootRO.Subcommands["plugin-test"] != nil {
+		t.Error("plugin command added to RootRO")
+	}
+	if RootRO.Subcommands["plugin-test-ro"] == nil {
+		t.Error("read-only plugin command not added to RootRO")
+	}
+}
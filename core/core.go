@@ -24,6 +24,7 @@ import (
 	"github.com/ipfs/go-ipfs/p2p"
 	"github.com/ipfs/go-ipfs/pin"
 	"github.com/ipfs/go-ipfs/repo"
+	"github.com/ipfs/go-ipfs/topics"
 
 	bserv "github.com/ipfs/go-blockservice"
 	bstore "github.com/ipfs/go-ipfs-blockstore"
@@ -99,6 +100,7 @@ type IpfsNode struct {
 	PSRouter *psrouter.PubsubValueStore `optional:"true"`
 	DHT      *dht.IpfsDHT               `optional:"true"`
 	P2P      *p2p.P2P                   `optional:"true"`
+	Topics   *topics.Manager            `optional:"true"`
 
 	Process goprocess.Process
 	ctx     context.Context
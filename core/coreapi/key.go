@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"sort"
 
+	keystore "github.com/ipfs/go-ipfs/keystore"
+
 	ipfspath "github.com/ipfs/go-path"
 	coreiface "github.com/ipfs/interface-go-ipfs-core"
 	caopts "github.com/ipfs/interface-go-ipfs-core/options"
@@ -108,13 +110,12 @@ func (api *KeyAPI) List(ctx context.Context) ([]coreiface.Key, error) {
 	out[0] = &key{"self", api.identity}
 
 	for n, k := range keys {
-		privKey, err := api.repo.Keystore().Get(k)
+		// Listing doesn't need the private keys, which may be locked.
+		pubKey, err := keystore.PublicKey(api.repo.Keystore(), k)
 		if err != nil {
 			return nil, err
 		}
 
-		pubKey := privKey.GetPublic()
-
 		pid, err := peer.IDFromPublicKey(pubKey)
 		if err != nil {
 			return nil, err
@@ -160,6 +160,17 @@ func (msg *pubSubMessage) Topics() []string {
 	return msg.msg.TopicIDs
 }
 
+// Signature returns the signature of the message by its author, if any
+func (msg *pubSubMessage) Signature() []byte {
+	return msg.msg.Signature
+}
+
+// Key returns the public key of the author, when it can't be extracted from
+// its peer ID
+func (msg *pubSubMessage) Key() []byte {
+	return msg.msg.Key
+}
+
 func (api *PubSubAPI) core() coreiface.CoreAPI {
 	return (*CoreAPI)(api)
 }
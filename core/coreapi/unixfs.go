@@ -30,8 +30,10 @@ type UnixfsAPI CoreAPI
 
 // Add builds a merkledag node from a reader, adds it to the blockstore,
 // and returns the key representing that node.
+//
+// It also takes the coreunix.AddWorkers and coreunix.AddResume options.
 func (api *UnixfsAPI) Add(ctx context.Context, files files.Node, opts ...options.UnixfsAddOption) (path.Resolved, error) {
-	settings, prefix, err := options.UnixfsAddOptions(opts...)
+	settings, prefix, adderOpts, err := coreunix.ParseAddOptions(opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -93,6 +95,13 @@ func (api *UnixfsAPI) Add(ctx context.Context, files files.Node, opts ...options
 	fileAdder.NoCopy = settings.NoCopy
 	fileAdder.CidBuilder = prefix
 
+	if adderOpts.Workers > 1 {
+		fileAdder.Workers = adderOpts.Workers
+	}
+	if adderOpts.Resume && !settings.OnlyHash {
+		fileAdder.Checkpoints = api.repo.Datastore()
+	}
+
 	switch settings.Layout {
 	case options.BalancedLayout:
 		// Default
@@ -117,21 +117,28 @@ func patchCORSVars(c *cmdsHttp.ServerConfig, addr net.Addr) {
 	c.SetAllowedOrigins(newOrigins...)
 }
 
+// apiServerConfig returns the CORS settings and headers of the API listening
+// on addr.
+func apiServerConfig(rcfg *config.Config, addr net.Addr) *cmdsHttp.ServerConfig {
+	cfg := cmdsHttp.NewServerConfig()
+	cfg.SetAllowedMethods("GET", "POST", "PUT")
+	cfg.APIPath = APIPath
+
+	addHeadersFromConfig(cfg, rcfg)
+	addCORSFromEnv(cfg)
+	addCORSDefaults(cfg)
+	patchCORSVars(cfg, addr)
+	return cfg
+}
+
 func commandsOption(cctx oldcmds.Context, command *cmds.Command) ServeOption {
 	return func(n *core.IpfsNode, l net.Listener, mux *http.ServeMux) (*http.ServeMux, error) {
 
-		cfg := cmdsHttp.NewServerConfig()
-		cfg.SetAllowedMethods("GET", "POST", "PUT")
-		cfg.APIPath = APIPath
 		rcfg, err := n.Repo.Config()
 		if err != nil {
 			return nil, err
 		}
-
-		addHeadersFromConfig(cfg, rcfg)
-		addCORSFromEnv(cfg)
-		addCORSDefaults(cfg)
-		patchCORSVars(cfg, l.Addr())
+		cfg := apiServerConfig(rcfg, l.Addr())
 
 		cmdHandler := cmdsHttp.NewHandler(&cctx, command, cfg)
 		mux.Handle(APIPath+"/", cmdHandler)
@@ -15,6 +15,7 @@ import (
 	"github.com/ipfs/go-ipfs/core"
 	"github.com/ipfs/go-ipfs/dagutils"
 	"github.com/ipfs/go-ipfs/namesys/resolve"
+	"github.com/ipfs/go-ipfs/thirdparty/quotabs"
 
 	"github.com/dustin/go-humanize"
 	"github.com/ipfs/go-cid"
@@ -598,6 +599,8 @@ func webError(w http.ResponseWriter, message string, err error, defaultCode int)
 		webErrorWithCode(w, message, err, http.StatusNotFound)
 	} else if err == context.DeadlineExceeded {
 		webErrorWithCode(w, message, err, http.StatusRequestTimeout)
+	} else if quotabs.IsRepoFull(err) {
+		webErrorWithCode(w, message, err, http.StatusInsufficientStorage)
 	} else {
 		webErrorWithCode(w, message, err, defaultCode)
 	}
@@ -612,6 +615,10 @@ func webErrorWithCode(w http.ResponseWriter, message string, err error, code int
 
 // return a 500 error and log
 func internalWebError(w http.ResponseWriter, err error) {
+	if quotabs.IsRepoFull(err) {
+		webErrorWithCode(w, "internalWebError", err, http.StatusInsufficientStorage)
+		return
+	}
 	webErrorWithCode(w, "internalWebError", err, http.StatusInternalServerError)
 }
 
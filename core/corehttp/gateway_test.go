@@ -75,6 +75,10 @@ func (m mockNamesys) PublishWithEOL(ctx context.Context, name ci.PrivKey, value
 	return errors.New("not implemented for mockNamesys")
 }
 
+func (m mockNamesys) Rollback(ctx context.Context, name ci.PrivKey, seq uint64, _ time.Time) (path.Path, error) {
+	return "", errors.New("not implemented for mockNamesys")
+}
+
 func (m mockNamesys) GetResolver(subs string) (namesys.Resolver, bool) {
 	return nil, false
 }
@@ -0,0 +1,173 @@
+package corehttp
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	core "github.com/ipfs/go-ipfs/core"
+	coreapi "github.com/ipfs/go-ipfs/core/coreapi"
+	plugin "github.com/ipfs/go-ipfs/plugin"
+
+	cmdsHttp "github.com/ipfs/go-ipfs-cmds/http"
+	config "github.com/ipfs/go-ipfs-config"
+)
+
+// PluginsOption mounts the HTTP handlers the plugins serve on listener.
+//
+// On the API, requests to the handlers go through the same origin checks as
+// the commands and get the API.HTTPHeaders. On the gateway, they get the
+// Gateway.HTTPHeaders.
+func PluginsOption(plugins []plugin.PluginHTTP, listener plugin.HTTPListener) ServeOption {
+	return func(n *core.IpfsNode, l net.Listener, mux *http.ServeMux) (*http.ServeMux, error) {
+		if len(plugins) == 0 {
+			return mux, nil
+		}
+
+		rcfg, err := n.Repo.Config()
+		if err != nil {
+			return nil, err
+		}
+
+		var wrap func(http.Handler) http.Handler
+		switch listener {
+		case plugin.HTTPAPI:
+			cfg := apiServerConfig(rcfg, l.Addr())
+			credentials := apiAllowCredentials(rcfg)
+			wrap = func(h http.Handler) http.Handler {
+				return apiPluginHandler(cfg, credentials, h)
+			}
+		case plugin.HTTPGateway:
+			headers := gatewayHeaders(rcfg)
+			wrap = func(h http.Handler) http.Handler {
+				return gatewayPluginHandler(headers, h)
+			}
+		default:
+			return nil, fmt.Errorf("unknown HTTP listener %d", listener)
+		}
+
+		api, err := coreapi.NewCoreAPI(n)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, pl := range plugins {
+			handlers, err := pl.HTTPHandlers(api)
+			if err != nil {
+				return nil, fmt.Errorf("plugin %s: %s", pl.Name(), err)
+			}
+			for _, h := range handlers {
+				if h.Listeners&listener == 0 {
+					continue
+				}
+				if err := mountPluginHandler(mux, h.Path, wrap(h.Handler)); err != nil {
+					return nil, fmt.Errorf("plugin %s: %s", pl.Name(), err)
+				}
+			}
+		}
+		return mux, nil
+	}
+}
+
+// mountPluginHandler mounts h at path, unless path is taken or reserved for
+// the API commands.
+func mountPluginHandler(mux *http.ServeMux, path string, h http.Handler) (err error) {
+	switch {
+	case !strings.HasPrefix(path, "/"):
+		return fmt.Errorf("handler path %q must start with a slash", path)
+	case path == "/", path == APIPath, strings.HasPrefix(path, APIPath+"/"):
+		return fmt.Errorf("handler path %q is reserved", path)
+	}
+
+	// ServeMux panics when a path is registered twice.
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("cannot mount handler at %q: %v", path, r)
+		}
+	}()
+	mux.Handle(path, h)
+	return nil
+}
+
+func apiAllowCredentials(rcfg *config.Config) bool {
+	allow := false
+	for _, v := range rcfg.API.HTTPHeaders[cmdsHttp.ACACredentials] {
+		allow = strings.ToLower(v) == "true"
+	}
+	return allow
+}
+
+// apiPluginHandler applies the origin checks and CORS handling of the API
+// commands to the handler of a plugin.
+func apiPluginHandler(cfg *cmdsHttp.ServerConfig, credentials bool, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origins := cfg.AllowedOrigins()
+		origin := r.Header.Get("Origin")
+		if !allowedOrigin(origins, origin) || !allowedReferer(origins, r.Referer()) {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte("403 - Forbidden"))
+			log.Warningf("API blocked request to %s. (possible CSRF)", r.URL)
+			return
+		}
+
+		for k, v := range cfg.Headers {
+			w.Header()[k] = v
+		}
+		if origin != "" {
+			w.Header().Add("Vary", "Origin")
+			w.Header().Set(cmdsHttp.ACAOrigin, origin)
+			if credentials {
+				w.Header().Set(cmdsHttp.ACACredentials, "true")
+			}
+		}
+
+		// Answer preflight requests like the commands do.
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set(cmdsHttp.ACAMethods, strings.Join(cfg.AllowedMethods(), ", "))
+			if headers := r.Header.Get("Access-Control-Request-Headers"); headers != "" {
+				w.Header().Set("Access-Control-Allow-Headers", headers)
+			}
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+func allowedOrigin(origins []string, origin string) bool {
+	if origin == "" {
+		return true
+	}
+	for _, o := range origins {
+		if o == "*" || o == origin {
+			return true
+		}
+	}
+	return false
+}
+
+// allowedReferer checks the origin of the referer, for the browsers not
+// sending an Origin header.
+func allowedReferer(origins []string, referer string) bool {
+	if referer == "" {
+		return true
+	}
+	u, err := url.Parse(referer)
+	if err != nil {
+		return false
+	}
+	return allowedOrigin(origins, u.Scheme+"://"+u.Host)
+}
+
+// gatewayPluginHandler sets the gateway headers on the responses of the
+// handler of a plugin.
+func gatewayPluginHandler(headers map[string][]string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for k, v := range headers {
+			w.Header()[k] = v
+		}
+		h.ServeHTTP(w, r)
+	})
+}
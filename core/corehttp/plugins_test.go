@@ -0,0 +1,86 @@
+package corehttp
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	cmdsHttp "github.com/ipfs/go-ipfs-cmds/http"
+)
+
+func TestAPIPluginHandler(t *testing.T) {
+	cfg := cmdsHttp.NewServerConfig()
+	cfg.SetAllowedOrigins("http://localhost:5001")
+	cfg.SetAllowedMethods("GET", "POST")
+	cfg.Headers = map[string][]string{"X-Test": {"yes"}}
+
+	h := apiPluginHandler(cfg, false, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "plugin")
+	}))
+
+	tcs := []struct {
+		origin, referer string
+		code            int
+	}{
+		{"", "", http.StatusOK},
+		{"http://localhost:5001", "", http.StatusOK},
+		{"", "http://localhost:5001/webui", http.StatusOK},
+		{"http://evil.example", "", http.StatusForbidden},
+		{"", "http://evil.example/page", http.StatusForbidden},
+	}
+	for _, tc := range tcs {
+		r := httptest.NewRequest("POST", "/plugin/test", nil)
+		if tc.origin != "" {
+			r.Header.Set("Origin", tc.origin)
+		}
+		if tc.referer != "" {
+			r.Header.Set("Referer", tc.referer)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != tc.code {
+			t.Errorf("origin %q, referer %q: expected code %d, got %d", tc.origin, tc.referer, tc.code, w.Code)
+			continue
+		}
+		if tc.code != http.StatusOK {
+			continue
+		}
+		if w.Body.String() != "plugin" {
+			t.Errorf("handler not called, got %q", w.Body.String())
+		}
+		if w.Header().Get("X-Test") != "yes" {
+			t.Error("API headers not set")
+		}
+		if tc.origin != "" && w.Header().Get(cmdsHttp.ACAOrigin) != tc.origin {
+			t.Errorf("expected %s %q, got %q", cmdsHttp.ACAOrigin, tc.origin, w.Header().Get(cmdsHttp.ACAOrigin))
+		}
+	}
+
+	r := httptest.NewRequest("OPTIONS", "/plugin/test", nil)
+	r.Header.Set("Origin", "http://localhost:5001")
+	r.Header.Set("Access-Control-Request-Method", "POST")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Body.Len() != 0 {
+		t.Error("preflight request reached the handler")
+	}
+	if w.Header().Get(cmdsHttp.ACAMethods) != "GET, POST" {
+		t.Errorf("unexpected allowed methods %q", w.Header().Get(cmdsHttp.ACAMethods))
+	}
+}
+
+func TestMountPluginHandler(t *testing.T) {
+	mux := http.NewServeMux()
+	h := http.NotFoundHandler()
+
+	if err := mountPluginHandler(mux, "/plugin/", h); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"/plugin/", "plugin", "/", APIPath, APIPath + "/add"} {
+		if err := mountPluginHandler(mux, p, h); err == nil {
+			t.Errorf("expected mounting at %q to fail", p)
+		}
+	}
+}
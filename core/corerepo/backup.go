@@ -0,0 +1,339 @@
+package corerepo
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ipfs/go-ipfs/core"
+	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
+
+	ds "github.com/ipfs/go-datastore"
+	dsquery "github.com/ipfs/go-datastore/query"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	config "github.com/ipfs/go-ipfs-config"
+)
+
+// Names of the entries of a backup archive. The manifest comes first, then
+// the config, the files of the keystore and the datastore entries.
+const (
+	backupManifestName = "backup.json"
+	backupConfigName   = "config"
+	backupSwarmKeyName = "swarm.key"
+	backupKeystoreDir  = "keystore"
+	backupDatastoreDir = "datastore"
+)
+
+const restoreBatchSize = 1024
+
+// BackupManifest describes a backup archive.
+type BackupManifest struct {
+	// RepoVersion is the version of the backed up repo.
+	RepoVersion  int
+	PeerID       string
+	Created      time.Time
+	MetadataOnly bool
+}
+
+// Backup writes a tar archive of the repo of n at repoPath to w: its config,
+// keystore and datastore. With metadataOnly, blocks are left out and only the
+// other datastore entries are kept (pins, IPNS records, MFS root...).
+//
+// The GC lock is held while the archive is written, so that it doesn't hold
+// pins referencing collected blocks, and pinning and adding wait for it to
+// complete. Other writes aren't blocked: MFS, IPNS records or keys changed
+// during the backup may be archived in an intermediate state.
+func Backup(ctx context.Context, n *core.IpfsNode, repoPath string, w io.Writer, metadataOnly bool) error {
+	unlocker := n.GCLocker.GCLock()
+	defer unlocker.Unlock()
+
+	tw := tar.NewWriter(w)
+
+	manifest, err := json.Marshal(&BackupManifest{
+		RepoVersion:  fsrepo.RepoVersion,
+		PeerID:       n.Identity.Pretty(),
+		Created:      time.Now().UTC(),
+		MetadataOnly: metadataOnly,
+	})
+	if err != nil {
+		return err
+	}
+	if err := writeTarFile(tw, backupManifestName, manifest); err != nil {
+		return err
+	}
+
+	cfgFile, err := config.Filename(repoPath)
+	if err != nil {
+		return err
+	}
+	if err := copyTarFile(tw, backupConfigName, cfgFile); err != nil {
+		return err
+	}
+	swarmKey := filepath.Join(repoPath, backupSwarmKeyName)
+	if err := copyTarFile(tw, backupSwarmKeyName, swarmKey); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	// Keystore files are copied as-is, so that encrypted keys stay
+	// encrypted.
+	ksDir := filepath.Join(repoPath, backupKeystoreDir)
+	files, err := ioutil.ReadDir(ksDir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, f := range files {
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		name := backupKeystoreDir + "/" + f.Name()
+		if err := copyTarFile(tw, name, filepath.Join(ksDir, f.Name())); err != nil {
+			return err
+		}
+	}
+
+	results, err := n.Repo.Datastore().Query(dsquery.Query{})
+	if err != nil {
+		return err
+	}
+	defer results.Close()
+
+	for result := range results.Next() {
+		if result.Error != nil {
+			return result.Error
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		key := ds.NewKey(result.Key)
+		if metadataOnly && (key.Equal(bstore.BlockPrefix) || bstore.BlockPrefix.IsAncestorOf(key)) {
+			continue
+		}
+		if err := writeTarFile(tw, backupDatastoreDir+key.String(), result.Value); err != nil {
+			return err
+		}
+	}
+
+	return tw.Close()
+}
+
+func writeTarFile(tw *tar.Writer, name string, data []byte) error {
+	err := tw.WriteHeader(&tar.Header{
+		Name:     name,
+		Mode:     0600,
+		Size:     int64(len(data)),
+		Typeflag: tar.TypeReg,
+		ModTime:  time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = tw.Write(data)
+	return err
+}
+
+func copyTarFile(tw *tar.Writer, name, path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return writeTarFile(tw, name, data)
+}
+
+// Restore initializes the repo at repoPath from a backup archive written by
+// Backup. The repo must not exist yet.
+//
+// The repo is restored into a temporary directory next to repoPath, which is
+// renamed into place once complete: a failed restore leaves nothing behind
+// and can be retried.
+func Restore(repoPath string, r io.Reader) (*BackupManifest, error) {
+	if fsrepo.IsInitialized(repoPath) {
+		return nil, fmt.Errorf("a repo already exists at %s", repoPath)
+	}
+	empty, err := isEmptyDir(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	if !empty {
+		return nil, fmt.Errorf("%s is not empty", repoPath)
+	}
+
+	parent := filepath.Dir(repoPath)
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return nil, err
+	}
+	tmpPath, err := ioutil.TempDir(parent, filepath.Base(repoPath)+".restore-")
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := restore(tmpPath, r)
+	if err != nil {
+		os.RemoveAll(tmpPath)
+		return nil, err
+	}
+
+	// An empty directory can't be renamed over, remove it first.
+	if err := os.Remove(repoPath); err != nil && !os.IsNotExist(err) {
+		os.RemoveAll(tmpPath)
+		return nil, err
+	}
+	if err := os.Rename(tmpPath, repoPath); err != nil {
+		os.RemoveAll(tmpPath)
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// isEmptyDir reports whether path doesn't exist or is an empty directory.
+func isEmptyDir(path string) (bool, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	_, err = f.Readdirnames(1)
+	if err == io.EOF {
+		return true, nil
+	}
+	return false, err
+}
+
+func restore(repoPath string, r io.Reader) (*BackupManifest, error) {
+	tr := tar.NewReader(r)
+
+	var manifest BackupManifest
+	if err := readTarJSON(tr, backupManifestName, &manifest); err != nil {
+		return nil, err
+	}
+	if manifest.RepoVersion != fsrepo.RepoVersion {
+		return nil, fmt.Errorf("the backup is of repo version %d, expected version %d", manifest.RepoVersion, fsrepo.RepoVersion)
+	}
+
+	var mapconf map[string]interface{}
+	if err := readTarJSON(tr, backupConfigName, &mapconf); err != nil {
+		return nil, err
+	}
+	cfg, err := config.FromMap(mapconf)
+	if err != nil {
+		return nil, err
+	}
+	if err := fsrepo.Init(repoPath, cfg); err != nil {
+		return nil, err
+	}
+
+	repo, err := fsrepo.Open(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	defer repo.Close()
+
+	// Init only knows about the config struct, restore the sections it
+	// doesn't hold as well.
+	for k, v := range mapconf {
+		if err := repo.SetConfigKey(k, v); err != nil {
+			return nil, err
+		}
+	}
+
+	batch, err := repo.Datastore().Batch()
+	if err != nil {
+		return nil, err
+	}
+	pending := 0
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch name := hdr.Name; {
+		case name == backupSwarmKeyName:
+			if err := restoreFile(tr, filepath.Join(repoPath, backupSwarmKeyName)); err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(name, backupKeystoreDir+"/"):
+			base := filepath.Base(name)
+			if base != name[len(backupKeystoreDir)+1:] {
+				return nil, fmt.Errorf("invalid keystore entry %s", name)
+			}
+			if err := restoreFile(tr, filepath.Join(repoPath, backupKeystoreDir, base)); err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(name, backupDatastoreDir+"/"):
+			value, err := ioutil.ReadAll(tr)
+			if err != nil {
+				return nil, err
+			}
+			key := ds.NewKey(strings.TrimPrefix(name, backupDatastoreDir))
+			if err := batch.Put(key, value); err != nil {
+				return nil, err
+			}
+			pending++
+			if pending >= restoreBatchSize {
+				if err := batch.Commit(); err != nil {
+					return nil, err
+				}
+				if batch, err = repo.Datastore().Batch(); err != nil {
+					return nil, err
+				}
+				pending = 0
+			}
+		default:
+			return nil, fmt.Errorf("unexpected backup entry %s", name)
+		}
+	}
+
+	if err := batch.Commit(); err != nil {
+		return nil, err
+	}
+	return &manifest, nil
+}
+
+func readTarJSON(tr *tar.Reader, name string, v interface{}) error {
+	hdr, err := tr.Next()
+	if err == io.EOF {
+		return errors.New("the backup archive is empty")
+	}
+	if err != nil {
+		return err
+	}
+	if hdr.Name != name {
+		return fmt.Errorf("invalid backup archive: expected %s, got %s", name, hdr.Name)
+	}
+	if err := json.NewDecoder(tr).Decode(v); err != nil {
+		return fmt.Errorf("invalid backup %s: %s", name, err)
+	}
+	return nil
+}
+
+func restoreFile(r io.Reader, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
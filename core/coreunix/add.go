@@ -11,6 +11,7 @@ import (
 	"github.com/ipfs/go-ipfs/pin"
 
 	"github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
 	bstore "github.com/ipfs/go-ipfs-blockstore"
 	chunker "github.com/ipfs/go-ipfs-chunker"
 	"github.com/ipfs/go-ipfs-files"
@@ -41,28 +42,34 @@ type Link struct {
 
 // NewAdder Returns a new Adder used for a file add operation.
 func NewAdder(ctx context.Context, p pin.Pinner, bs bstore.GCLocker, ds ipld.DAGService) (*Adder, error) {
-	bufferedDS := ipld.NewBufferedDAG(ctx, ds)
-
 	return &Adder{
 		ctx:        ctx,
 		pinning:    p,
 		gcLocker:   bs,
 		dagService: ds,
-		bufferedDS: bufferedDS,
 		Progress:   false,
 		Pin:        true,
 		Trickle:    false,
 		Chunker:    "",
+		Workers:    1,
 	}, nil
 }
 
+// AddOptions are the settings of the Adder that the core API has no options
+// for, set with the AddWorkers and AddResume options.
+type AddOptions struct {
+	// Workers is the number of files added in parallel.
+	Workers int
+	// Resume enables the checkpoints of the added files.
+	Resume bool
+}
+
 // Adder holds the switches passed to the `add` command.
 type Adder struct {
 	ctx        context.Context
 	pinning    pin.Pinner
 	gcLocker   bstore.GCLocker
 	dagService ipld.DAGService
-	bufferedDS *ipld.BufferedDAG
 	Out        chan<- interface{}
 	Progress   bool
 	Pin        bool
@@ -76,6 +83,32 @@ type Adder struct {
 	tempRoot   cid.Cid
 	CidBuilder cid.Builder
 	liveNodes  uint64
+
+	// Workers is the number of files chunked and hashed at the same time.
+	// The files of a stream are still read in order, each into the buffer
+	// of its worker. The results are patched into the root in order, so the
+	// CIDs don't depend on the worker count.
+	Workers int
+	workers chan struct{}
+	pending []*addJob
+
+	// Checkpoints, when set, records the CIDs of the added files and of the
+	// subtrees of large files, so that adding the same files again after an
+	// interrupted add skips them. Only the files read from the local
+	// filesystem are checkpointed. The checkpoints are removed once the add
+	// completes.
+	Checkpoints ds.Datastore
+	checkpoints []ds.Key
+}
+
+// addJob is a file added by a worker.
+type addJob struct {
+	path string
+	done chan struct{}
+
+	nd         ipld.Node
+	checkpoint *ds.Key
+	err        error
 }
 
 func (adder *Adder) mfsRoot() (*mfs.Root, error) {
@@ -97,15 +130,18 @@ func (adder *Adder) SetMfsRoot(r *mfs.Root) {
 	adder.mroot = r
 }
 
-// Constructs a node from reader's data, and adds it. Doesn't pin.
-func (adder *Adder) add(reader io.Reader) (ipld.Node, error) {
+// Constructs a node from reader's data, and adds it. Doesn't pin. With fc
+// set, the subtrees of the DAG are checkpointed as they complete.
+func (adder *Adder) add(reader io.Reader, fc *fileCheckpoint) (ipld.Node, error) {
 	chnk, err := chunker.FromString(reader, adder.Chunker)
 	if err != nil {
 		return nil, err
 	}
 
+	// Each file gets its own buffer, as files may be added in parallel.
+	bufferedDS := ipld.NewBufferedDAG(adder.ctx, adder.dagService)
 	params := ihelper.DagBuilderParams{
-		Dagserv:    adder.bufferedDS,
+		Dagserv:    bufferedDS,
 		RawLeaves:  adder.RawLeaves,
 		Maxlinks:   ihelper.DefaultLinksPerBlock,
 		NoCopy:     adder.NoCopy,
@@ -117,16 +153,19 @@ func (adder *Adder) add(reader io.Reader) (ipld.Node, error) {
 		return nil, err
 	}
 	var nd ipld.Node
-	if adder.Trickle {
+	switch {
+	case fc != nil:
+		nd, err = fc.layout(db, bufferedDS)
+	case adder.Trickle:
 		nd, err = trickle.Layout(db)
-	} else {
+	default:
 		nd, err = balanced.Layout(db)
 	}
 	if err != nil {
 		return nil, err
 	}
 
-	return nd, adder.bufferedDS.Commit()
+	return nd, bufferedDS.Commit()
 }
 
 // RootNode returns the mfs root node
@@ -260,10 +299,15 @@ func (adder *Adder) AddAllAndPin(file files.Node) (ipld.Node, error) {
 			adder.unlocker.Unlock()
 		}
 	}()
+	// Don't leave workers behind on failure.
+	defer adder.waitPending()
 
 	if err := adder.addFileNode("", file, true); err != nil {
 		return nil, err
 	}
+	if err := adder.commit(true); err != nil {
+		return nil, err
+	}
 
 	// get root
 	mr, err := adder.mfsRoot()
@@ -317,14 +361,22 @@ func (adder *Adder) AddAllAndPin(file files.Node) (ipld.Node, error) {
 		return nil, err
 	}
 
-	if !adder.Pin {
-		return nd, nil
+	if adder.Pin {
+		if err := adder.PinRoot(nd); err != nil {
+			return nil, err
+		}
 	}
-	return nd, adder.PinRoot(nd)
+	return nd, adder.clearCheckpoints()
 }
 
 func (adder *Adder) addFileNode(path string, file files.Node, toplevel bool) error {
-	defer file.Close()
+	// Files added in parallel are closed by their worker.
+	closeFile := true
+	defer func() {
+		if closeFile {
+			file.Close()
+		}
+	}()
 
 	err := adder.maybePauseForGC()
 	if err != nil {
@@ -351,6 +403,10 @@ func (adder *Adder) addFileNode(path string, file files.Node, toplevel bool) err
 	case *files.Symlink:
 		return adder.addSymlink(path, f)
 	case files.File:
+		if adder.Workers > 1 {
+			closeFile = false
+			return adder.addFileAsync(path, f)
+		}
 		return adder.addFile(path, f)
 	default:
 		return errors.New("unknown file type")
@@ -358,6 +414,11 @@ func (adder *Adder) addFileNode(path string, file files.Node, toplevel bool) err
 }
 
 func (adder *Adder) addSymlink(path string, l *files.Symlink) error {
+	// Keep the entries in order.
+	if err := adder.commit(true); err != nil {
+		return err
+	}
+
 	sdata, err := unixfs.SymlinkData(l.Target)
 	if err != nil {
 		return err
@@ -374,25 +435,146 @@ func (adder *Adder) addSymlink(path string, l *files.Symlink) error {
 }
 
 func (adder *Adder) addFile(path string, file files.File) error {
+	// Keep the entries in order.
+	if err := adder.commit(true); err != nil {
+		return err
+	}
+
+	dagnode, checkpoint, err := adder.addFileData(path, file)
+	if err != nil {
+		return err
+	}
+	if checkpoint != nil {
+		adder.checkpoints = append(adder.checkpoints, *checkpoint)
+	}
+
+	// patch it into the root
+	return adder.addNode(dagnode, path)
+}
+
+// addFileData builds the DAG of a file, unless a checkpoint has it already.
+// It returns the key of the checkpoint of the file, if any.
+func (adder *Adder) addFileData(path string, file files.File) (ipld.Node, *ds.Key, error) {
+	checkpoint, ok := adder.checkpointKey(file)
+	if !ok {
+		nd, err := adder.addReader(path, file, nil)
+		return nd, nil, err
+	}
+
+	if nd, ok := adder.loadCheckpoint(checkpoint); ok {
+		log.Infof("resuming add: skipping %s, added already", path)
+		adder.skipFile(path, file)
+		return nd, &checkpoint, nil
+	}
+
+	nd, err := adder.addReader(path, file, &checkpoint)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := adder.Checkpoints.Put(checkpoint, nd.Cid().Bytes()); err != nil {
+		return nil, nil, err
+	}
+	return nd, &checkpoint, nil
+}
+
+func (adder *Adder) addReader(path string, file files.File, checkpoint *ds.Key) (ipld.Node, error) {
 	// if the progress flag was specified, wrap the file so that we can send
 	// progress updates to the client (over the output channel)
 	var reader io.Reader = file
+	var progress *progressReader
 	if adder.Progress {
-		rdr := &progressReader{file: reader, path: path, out: adder.Out}
+		progress = &progressReader{file: reader, path: path, out: adder.Out}
 		if fi, ok := file.(files.FileInfo); ok {
-			reader = &progressReader2{rdr, fi}
+			reader = &progressReader2{progress, fi}
 		} else {
-			reader = rdr
+			reader = progress
 		}
 	}
 
-	dagnode, err := adder.add(reader)
-	if err != nil {
-		return err
+	var fc *fileCheckpoint
+	if checkpoint != nil {
+		fc = adder.newFileCheckpoint(*checkpoint, file, progress)
 	}
+	return adder.add(reader, fc)
+}
 
-	// patch it into the root
-	return adder.addNode(dagnode, path)
+// addFileAsync adds a file with a worker, once one is free, then patches the
+// files whose workers are done into the root.
+func (adder *Adder) addFileAsync(path string, file files.File) error {
+	if adder.workers == nil {
+		adder.workers = make(chan struct{}, adder.Workers)
+	}
+
+	job := &addJob{path: path, done: make(chan struct{})}
+	adder.pending = append(adder.pending, job)
+
+	// The next file of a stream can only be read once this one is: read it
+	// here, into the buffer of the worker.
+	var stream *streamedFile
+	if !localFile(file) {
+		stream = newStreamedFile(file)
+	}
+
+	adder.workers <- struct{}{}
+	go func() {
+		defer func() {
+			if stream != nil {
+				stream.Close()
+			} else {
+				file.Close()
+			}
+			<-adder.workers
+			close(job.done)
+		}()
+		if stream != nil {
+			job.nd, job.checkpoint, job.err = adder.addFileData(path, stream.File())
+		} else {
+			job.nd, job.checkpoint, job.err = adder.addFileData(path, file)
+		}
+	}()
+
+	if stream != nil {
+		stream.feed()
+		file.Close()
+	}
+	return adder.commit(false)
+}
+
+// commit patches the files added by workers into the root, in the order they
+// were queued. Unless wait is set, it stops at the first file still being
+// added, as long as no more files than workers are queued.
+func (adder *Adder) commit(wait bool) error {
+	for len(adder.pending) > 0 {
+		job := adder.pending[0]
+		if !wait && len(adder.pending) <= adder.Workers {
+			select {
+			case <-job.done:
+			default:
+				return nil
+			}
+		}
+		<-job.done
+		adder.pending = adder.pending[1:]
+
+		if job.err != nil {
+			return job.err
+		}
+		if job.checkpoint != nil {
+			adder.checkpoints = append(adder.checkpoints, *job.checkpoint)
+		}
+		if err := adder.addNode(job.nd, job.path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// waitPending waits for the workers still adding files.
+func (adder *Adder) waitPending() {
+	for _, job := range adder.pending {
+		<-job.done
+	}
+	adder.pending = nil
 }
 
 func (adder *Adder) addDir(path string, dir files.Directory, toplevel bool) error {
@@ -427,6 +609,12 @@ func (adder *Adder) addDir(path string, dir files.Directory, toplevel bool) erro
 
 func (adder *Adder) maybePauseForGC() error {
 	if adder.unlocker != nil && adder.gcLocker.GCRequested() {
+		// The blocks of the files being added are only safe from the GC
+		// once the files are in the root.
+		if err := adder.commit(true); err != nil {
+			return err
+		}
+
 		rn, err := adder.curRootNode()
 		if err != nil {
 			return err
@@ -487,6 +675,16 @@ type progressReader struct {
 	lastProgress int64
 }
 
+// advance reports n bytes of the file as added without reading them.
+func (i *progressReader) advance(n int64) {
+	i.bytes += n
+	i.lastProgress = i.bytes
+	i.out <- &coreiface.AddEvent{
+		Name:  i.path,
+		Bytes: i.bytes,
+	}
+}
+
 func (i *progressReader) Read(p []byte) (int, error) {
 	n, err := i.file.Read(p)
 
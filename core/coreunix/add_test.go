@@ -3,6 +3,8 @@ package coreunix
 import (
 	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"math/rand"
@@ -19,6 +21,7 @@ import (
 	"github.com/ipfs/go-blockservice"
 	cid "github.com/ipfs/go-cid"
 	datastore "github.com/ipfs/go-datastore"
+	query "github.com/ipfs/go-datastore/query"
 	syncds "github.com/ipfs/go-datastore/sync"
 	blockstore "github.com/ipfs/go-ipfs-blockstore"
 	config "github.com/ipfs/go-ipfs-config"
@@ -366,3 +369,253 @@ func (fi *dummyFileInfo) Mode() os.FileMode  { return 0 }
 func (fi *dummyFileInfo) ModTime() time.Time { return fi.modTime }
 func (fi *dummyFileInfo) IsDir() bool        { return false }
 func (fi *dummyFileInfo) Sys() interface{}   { return nil }
+
+func newTestNode(t *testing.T) *core.IpfsNode {
+	r := &repo.Mock{
+		C: config.Config{
+			Identity: config.Identity{
+				PeerID: testPeerID, // required by offline node
+			},
+		},
+		D: syncds.MutexWrap(datastore.NewMapDatastore()),
+	}
+	node, err := core.NewNode(context.Background(), &core.BuildCfg{Repo: r})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return node
+}
+
+// writeTestTree writes a few directories of random files.
+func writeTestTree(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "coreunix-add")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 16; i++ {
+		sub := filepath.Join(dir, fmt.Sprintf("d%d", i%3))
+		if err := os.MkdirAll(sub, 0755); err != nil {
+			t.Fatal(err)
+		}
+		data := make([]byte, 300*1024+i)
+		rand.New(rand.NewSource(int64(i))).Read(data)
+		if err := ioutil.WriteFile(filepath.Join(sub, fmt.Sprintf("f%d", i)), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func addTestTree(t *testing.T, node *core.IpfsNode, dir string, workers int, checkpoints datastore.Datastore) cid.Cid {
+	adder, err := NewAdder(context.Background(), node.Pinning, node.Blockstore, node.DAG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adder.Workers = workers
+	adder.Checkpoints = checkpoints
+
+	st, err := os.Stat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := files.NewSerialFile(dir, false, st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nd, err := adder.AddAllAndPin(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return nd.Cid()
+}
+
+func TestAddParallel(t *testing.T) {
+	dir := writeTestTree(t)
+	defer os.RemoveAll(dir)
+	node := newTestNode(t)
+
+	expected := addTestTree(t, node, dir, 1, nil)
+	for _, workers := range []int{2, 4, 32} {
+		if c := addTestTree(t, node, dir, workers, nil); !c.Equals(expected) {
+			t.Errorf("adding with %d workers gave %s, expected %s", workers, c, expected)
+		}
+	}
+}
+
+func TestAddResume(t *testing.T) {
+	dir := writeTestTree(t)
+	defer os.RemoveAll(dir)
+	node := newTestNode(t)
+	checkpoints := syncds.MutexWrap(datastore.NewMapDatastore())
+
+	expected := addTestTree(t, node, dir, 1, nil)
+
+	// Checkpoint a file with the DAG of other data, to tell whether the
+	// checkpoint is used.
+	adder, err := NewAdder(context.Background(), node.Pinning, node.Blockstore, node.DAG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adder.Checkpoints = checkpoints
+	fpath := filepath.Join(dir, "d0", "f0")
+	st, err := os.Stat(fpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := files.NewSerialFile(fpath, false, st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	key, ok := adder.checkpointKey(f.(files.File))
+	if !ok {
+		t.Fatal("no checkpoint key for a local file")
+	}
+	other, err := adder.add(bytes.NewReader([]byte("other data")), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkpoints.Put(key, other.Cid().Bytes()); err != nil {
+		t.Fatal(err)
+	}
+
+	if c := addTestTree(t, node, dir, 4, checkpoints); c.Equals(expected) {
+		t.Fatal("checkpoint not used")
+	}
+
+	// The checkpoints are removed once the add completes.
+	if n := countCheckpoints(t, checkpoints); n != 0 {
+		t.Fatalf("expected no checkpoints left, got %d", n)
+	}
+
+	if c := addTestTree(t, node, dir, 4, checkpoints); !c.Equals(expected) {
+		t.Fatalf("got %s, expected %s", c, expected)
+	}
+}
+
+func countCheckpoints(t *testing.T, checkpoints datastore.Datastore) int {
+	res, err := checkpoints.Query(query.Query{KeysOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(entries)
+}
+
+var errInterrupted = errors.New("interrupted")
+
+// interruptedFile is a local file whose reads are counted, and fail past
+// limit, if set.
+type interruptedFile struct {
+	files.File
+	fi    files.FileInfo
+	read  int64
+	limit int64
+}
+
+func newInterruptedFile(t *testing.T, path string, limit int64) *interruptedFile {
+	st, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nd, err := files.NewSerialFile(path, false, st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &interruptedFile{File: nd.(files.File), fi: nd.(files.FileInfo), limit: limit}
+}
+
+func (f *interruptedFile) Read(p []byte) (int, error) {
+	if f.limit > 0 && f.read+int64(len(p)) > f.limit {
+		return 0, errInterrupted
+	}
+	n, err := f.File.Read(p)
+	f.read += int64(n)
+	return n, err
+}
+
+func (f *interruptedFile) AbsPath() string   { return f.fi.AbsPath() }
+func (f *interruptedFile) Stat() os.FileInfo { return f.fi.Stat() }
+
+func TestAddResumeInterrupted(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		chunker string
+		size    int
+		// subtree is the size of the checkpointed subtrees, of 174 chunks.
+		subtree int64
+		// subtrees is the number of subtrees added before the interruption.
+		subtrees int64
+	}{
+		// A DAG of depth 2, with the leaves under the root checkpointed.
+		{"depth2", "size-1024", 1000000, 174 * 1024, 3},
+		// A DAG of depth 3, interrupted before its first subtree of depth 2
+		// completed: the subtrees of depth 1 are skipped while the subtree
+		// of depth 2 is built again.
+		{"depth3", "size-64", 2500000, 174 * 64, 40},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			testAddResumeInterrupted(t, tc.chunker, tc.size, tc.subtree, tc.subtrees)
+		})
+	}
+}
+
+func testAddResumeInterrupted(t *testing.T, chnk string, size int, subtree, subtrees int64) {
+	dir, err := ioutil.TempDir("", "coreunix-add")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	data := make([]byte, size)
+	rand.New(rand.NewSource(1)).Read(data)
+	fpath := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(fpath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	node := newTestNode(t)
+	checkpoints := syncds.MutexWrap(datastore.NewMapDatastore())
+	add := func(file files.File, checkpoints datastore.Datastore) (cid.Cid, error) {
+		adder, err := NewAdder(context.Background(), node.Pinning, node.Blockstore, node.DAG)
+		if err != nil {
+			t.Fatal(err)
+		}
+		adder.Chunker = chnk
+		adder.Checkpoints = checkpoints
+		nd, err := adder.AddAllAndPin(file)
+		if err != nil {
+			return cid.Cid{}, err
+		}
+		return nd.Cid(), nil
+	}
+
+	expected, err := add(newInterruptedFile(t, fpath, 0), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := add(newInterruptedFile(t, fpath, subtrees*subtree+subtree/2), checkpoints); err != errInterrupted {
+		t.Fatalf("expected the add to be interrupted, got %v", err)
+	}
+	if countCheckpoints(t, checkpoints) < int(subtrees) {
+		t.Fatal("expected the complete subtrees of the file to be checkpointed")
+	}
+
+	file := newInterruptedFile(t, fpath, 0)
+	c, err := add(file, checkpoints)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.Equals(expected) {
+		t.Fatalf("got %s, expected %s", c, expected)
+	}
+	if file.read > int64(size)-subtrees*subtree {
+		t.Fatalf("read %d bytes, expected the first %d bytes to be skipped", file.read, subtrees*subtree)
+	}
+	if n := countCheckpoints(t, checkpoints); n != 0 {
+		t.Fatalf("expected no checkpoints left, got %d", n)
+	}
+}
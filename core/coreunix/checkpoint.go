@@ -0,0 +1,319 @@
+package coreunix
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dsq "github.com/ipfs/go-datastore/query"
+	chunker "github.com/ipfs/go-ipfs-chunker"
+	files "github.com/ipfs/go-ipfs-files"
+	ipld "github.com/ipfs/go-ipld-format"
+	dag "github.com/ipfs/go-merkledag"
+	ft "github.com/ipfs/go-unixfs"
+	ihelper "github.com/ipfs/go-unixfs/importer/helpers"
+	coreiface "github.com/ipfs/interface-go-ipfs-core"
+)
+
+// checkpointPrefix is the datastore prefix of the checkpoints of the files
+// added with Adder.Checkpoints set.
+var checkpointPrefix = ds.NewKey("/local/addcheckpoints")
+
+// localFile returns whether file is read from the local filesystem, rather
+// than from a stream.
+func localFile(file files.File) bool {
+	fi, ok := file.(files.FileInfo)
+	return ok && fi.AbsPath() != "" && fi.Stat() != nil
+}
+
+// checkpointKey returns the key of the checkpoint of file, derived from its
+// path, size and modification time and from the settings of the adder.
+//
+// Only the files the adder reads from its own filesystem have a checkpoint:
+// the path of a streamed file says nothing about the data streamed, so its
+// data is always read and chunked again.
+func (adder *Adder) checkpointKey(file files.File) (ds.Key, bool) {
+	if adder.Checkpoints == nil || !localFile(file) {
+		return ds.Key{}, false
+	}
+	fi := file.(files.FileInfo)
+	stat := fi.Stat()
+	if !stat.Mode().IsRegular() {
+		return ds.Key{}, false
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%d\x00%d\x00%s\x00%t\x00%t\x00%t\x00%v",
+		fi.AbsPath(), stat.Size(), stat.ModTime().UnixNano(),
+		adder.Chunker, adder.RawLeaves, adder.Trickle, adder.NoCopy, adder.CidBuilder)
+	return checkpointPrefix.ChildString(hex.EncodeToString(h.Sum(nil))), true
+}
+
+// loadCheckpoint returns the DAG of the checkpoint at key, if all its blocks
+// are still there.
+func (adder *Adder) loadCheckpoint(key ds.Key) (ipld.Node, bool) {
+	val, err := adder.Checkpoints.Get(key)
+	if err != nil {
+		if err != ds.ErrNotFound {
+			log.Warningf("reading add checkpoint: %s", err)
+		}
+		return nil, false
+	}
+	c, err := cid.Cast(val)
+	if err != nil {
+		log.Warningf("invalid add checkpoint %s: %s", key, err)
+		return nil, false
+	}
+	return adder.checkpointedDAG(key, c)
+}
+
+// checkpointedDAG returns the DAG of c, recorded in the checkpoint at key, if
+// all its blocks are still there.
+func (adder *Adder) checkpointedDAG(key ds.Key, c cid.Cid) (ipld.Node, bool) {
+	nd, err := adder.dagService.Get(adder.ctx, c)
+	if err != nil {
+		return nil, false
+	}
+	// The blocks aren't pinned: the GC may have removed some of them.
+	visit := cid.NewSet().Visit
+	if err := dag.WalkParallel(adder.ctx, dag.GetLinksDirect(adder.dagService), c, visit); err != nil {
+		log.Infof("add checkpoint %s is incomplete: %s", key, err)
+		return nil, false
+	}
+	return nd, true
+}
+
+// skipFile reports the progress of a local file added already, without
+// reading it.
+func (adder *Adder) skipFile(path string, file files.File) {
+	if adder.Progress {
+		adder.Out <- &coreiface.AddEvent{
+			Name:  path,
+			Bytes: file.(files.FileInfo).Stat().Size(),
+		}
+	}
+}
+
+// clearCheckpoints removes the checkpoints of the add once it completed,
+// along with the checkpoints of the subtrees of the files.
+func (adder *Adder) clearCheckpoints() error {
+	for _, key := range adder.checkpoints {
+		res, err := adder.Checkpoints.Query(dsq.Query{
+			Prefix:   key.String(),
+			KeysOnly: true,
+		})
+		if err != nil {
+			return err
+		}
+		entries, err := res.Rest()
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if err := adder.Checkpoints.Delete(ds.RawKey(e.Key)); err != nil {
+				return err
+			}
+		}
+		if err := adder.Checkpoints.Delete(key); err != nil && err != ds.ErrNotFound {
+			return err
+		}
+	}
+	adder.checkpoints = nil
+	return nil
+}
+
+// fixedChunkSize returns the size of the chunks of the chunker, if they all
+// have the same size.
+func fixedChunkSize(chnk string) (int64, bool) {
+	switch {
+	case chnk == "" || chnk == "default":
+		return chunker.DefaultBlockSize, true
+	case strings.HasPrefix(chnk, "size-"):
+		size, err := strconv.ParseInt(chnk[len("size-"):], 10, 64)
+		return size, err == nil && size > 0
+	default:
+		return 0, false
+	}
+}
+
+// subtreeCheckpoint is the checkpoint of a subtree of the DAG of a file.
+type subtreeCheckpoint struct {
+	Cid  cid.Cid
+	Size uint64
+}
+
+// fileCheckpoint records the subtrees of the DAG of a large file as they
+// complete, so that an interrupted add of the file resumes from the last
+// one rather than from the start of the file.
+//
+// It builds the DAG with its own copy of the balanced layout, which skips
+// the data of the recorded subtrees. That requires the position of each
+// subtree in the file to be known ahead: the chunks must all have the same
+// size, and the file must be read from the local filesystem, so that its
+// size is known and it can seek.
+type fileCheckpoint struct {
+	adder *Adder
+	key   ds.Key
+	file  files.File
+	size  uint64
+	chunk uint64
+
+	progress *progressReader
+	db       *ihelper.DagBuilderHelper
+	batch    *ipld.BufferedDAG
+	depth    int
+	offset   uint64
+}
+
+// newFileCheckpoint returns the checkpoint of the subtrees of a file, or nil
+// when the DAG of the file has no subtree to record, or the adder can't skip
+// them.
+func (adder *Adder) newFileCheckpoint(key ds.Key, file files.File, progress *progressReader) *fileCheckpoint {
+	if adder.Trickle || adder.NoCopy || !localFile(file) {
+		return nil
+	}
+	chunk, ok := fixedChunkSize(adder.Chunker)
+	if !ok {
+		return nil
+	}
+	size := file.(files.FileInfo).Stat().Size()
+	if size <= chunk*int64(ihelper.DefaultLinksPerBlock) {
+		// The only subtree is the file.
+		return nil
+	}
+	return &fileCheckpoint{
+		adder:    adder,
+		key:      key,
+		file:     file,
+		size:     uint64(size),
+		chunk:    uint64(chunk),
+		progress: progress,
+	}
+}
+
+func (fc *fileCheckpoint) subtreeKey(depth int, offset uint64) ds.Key {
+	return fc.key.ChildString(fmt.Sprintf("%d-%d", depth, offset))
+}
+
+// layout builds the DAG of the file like balanced.Layout, top down, as the
+// depth of the DAG follows from the size of the file.
+func (fc *fileCheckpoint) layout(db *ihelper.DagBuilderHelper, batch *ipld.BufferedDAG) (ipld.Node, error) {
+	fc.db = db
+	fc.batch = batch
+
+	chunks := (fc.size + fc.chunk - 1) / fc.chunk
+	depth := 1
+	for leaves := uint64(db.Maxlinks()); leaves < chunks; leaves *= uint64(db.Maxlinks()) {
+		depth++
+	}
+
+	fc.depth = depth
+	root, _, err := fc.fill(depth)
+	if err != nil {
+		return nil, err
+	}
+	if !db.Done() {
+		return nil, fmt.Errorf("%s grew while being added", fc.file.(files.FileInfo).AbsPath())
+	}
+	return root, db.Add(root)
+}
+
+// fill builds the subtree of the given depth at the current offset, like
+// fillNodeRec in balanced.Layout.
+//
+// Skipping a subtree seeks the file, so db must not have read any of it
+// ahead: db.Done reads the next chunk. The end of the file is known from its
+// size instead, and db is only asked for a chunk to build a leaf with it.
+func (fc *fileCheckpoint) fill(depth int) (ipld.Node, uint64, error) {
+	start := fc.offset
+	node := fc.db.NewFSNodeOverDag(ft.TFile)
+	for node.NumChildren() < fc.db.Maxlinks() && fc.offset < fc.size {
+		child, childSize, ok := fc.skip(depth - 1)
+		if !ok {
+			var err error
+			if depth == 1 {
+				if fc.db.Done() {
+					return nil, 0, fmt.Errorf("%s shrank while being added", fc.file.(files.FileInfo).AbsPath())
+				}
+				child, childSize, err = fc.db.NewLeafDataNode(ft.TFile)
+				fc.offset += childSize
+			} else {
+				child, childSize, err = fc.fill(depth - 1)
+			}
+			if err != nil {
+				return nil, 0, err
+			}
+		}
+		if err := node.AddChild(child, childSize, fc.db); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	nd, err := node.Commit()
+	if err != nil {
+		return nil, 0, err
+	}
+	size := node.FileSize()
+	if depth < fc.depth {
+		if err := fc.record(depth, start, nd, size); err != nil {
+			return nil, 0, err
+		}
+	}
+	return nd, size, nil
+}
+
+// skip seeks past the subtree of the given depth at the current offset, if
+// an interrupted add recorded it.
+func (fc *fileCheckpoint) skip(depth int) (ipld.Node, uint64, bool) {
+	if depth < 1 {
+		return nil, 0, false
+	}
+	key := fc.subtreeKey(depth, fc.offset)
+	val, err := fc.adder.Checkpoints.Get(key)
+	if err != nil {
+		if err != ds.ErrNotFound {
+			log.Warningf("reading add checkpoint: %s", err)
+		}
+		return nil, 0, false
+	}
+	var cp subtreeCheckpoint
+	if err := json.Unmarshal(val, &cp); err != nil {
+		log.Warningf("invalid add checkpoint %s: %s", key, err)
+		return nil, 0, false
+	}
+	nd, ok := fc.adder.checkpointedDAG(key, cp.Cid)
+	if !ok {
+		return nil, 0, false
+	}
+	if _, err := fc.file.Seek(int64(cp.Size), io.SeekCurrent); err != nil {
+		log.Warningf("skipping the data of add checkpoint %s: %s", key, err)
+		return nil, 0, false
+	}
+	fc.offset += cp.Size
+	if fc.progress != nil {
+		fc.progress.advance(int64(cp.Size))
+	}
+	return nd, cp.Size, true
+}
+
+// record records a subtree, once its blocks are stored.
+func (fc *fileCheckpoint) record(depth int, offset uint64, nd ipld.Node, size uint64) error {
+	// The parent adds the node along with its link to it.
+	if err := fc.db.Add(nd); err != nil {
+		return err
+	}
+	if err := fc.batch.Commit(); err != nil {
+		return err
+	}
+	val, err := json.Marshal(subtreeCheckpoint{Cid: nd.Cid(), Size: size})
+	if err != nil {
+		return err
+	}
+	return fc.adder.Checkpoints.Put(fc.subtreeKey(depth, offset), val)
+}
@@ -0,0 +1,71 @@
+package coreunix
+
+import (
+	"sync"
+
+	cid "github.com/ipfs/go-cid"
+	options "github.com/ipfs/interface-go-ipfs-core/options"
+)
+
+// addOptions holds the AddOptions of the adds being parsed by ParseAddOptions,
+// by the core API settings their options are applied to. The core API
+// settings have no room for them.
+var addOptions = struct {
+	sync.Mutex
+	m map[*options.UnixfsAddSettings]*AddOptions
+}{m: make(map[*options.UnixfsAddSettings]*AddOptions)}
+
+func setAddOption(settings *options.UnixfsAddSettings, set func(*AddOptions)) error {
+	addOptions.Lock()
+	defer addOptions.Unlock()
+	if o, ok := addOptions.m[settings]; ok {
+		set(o)
+	}
+	return nil
+}
+
+// AddWorkers is an Unixfs.Add option setting the number of files added in
+// parallel. Only the core API of the node reads it; the other implementations
+// ignore it.
+func AddWorkers(workers int) options.UnixfsAddOption {
+	return func(settings *options.UnixfsAddSettings) error {
+		return setAddOption(settings, func(o *AddOptions) {
+			o.Workers = workers
+		})
+	}
+}
+
+// AddResume is an Unixfs.Add option enabling the checkpoints of the added
+// files, to resume an interrupted add of the same files. Only the core API of
+// the node reads it; the other implementations ignore it.
+func AddResume(resume bool) options.UnixfsAddOption {
+	return func(settings *options.UnixfsAddSettings) error {
+		return setAddOption(settings, func(o *AddOptions) {
+			o.Resume = resume
+		})
+	}
+}
+
+// ParseAddOptions is options.UnixfsAddOptions, which also returns the
+// AddOptions set by AddWorkers and AddResume.
+func ParseAddOptions(opts ...options.UnixfsAddOption) (*options.UnixfsAddSettings, cid.Prefix, AddOptions, error) {
+	var adderOpts AddOptions
+	var parsed *options.UnixfsAddSettings
+	register := func(settings *options.UnixfsAddSettings) error {
+		addOptions.Lock()
+		defer addOptions.Unlock()
+		parsed = settings
+		addOptions.m[settings] = &adderOpts
+		return nil
+	}
+	defer func() {
+		if parsed != nil {
+			addOptions.Lock()
+			delete(addOptions.m, parsed)
+			addOptions.Unlock()
+		}
+	}()
+
+	settings, prefix, err := options.UnixfsAddOptions(append([]options.UnixfsAddOption{register}, opts...)...)
+	return settings, prefix, adderOpts, err
+}
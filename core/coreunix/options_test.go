@@ -0,0 +1,37 @@
+package coreunix
+
+import (
+	"testing"
+
+	options "github.com/ipfs/interface-go-ipfs-core/options"
+)
+
+func TestParseAddOptions(t *testing.T) {
+	settings, _, adderOpts, err := ParseAddOptions(
+		options.Unixfs.Chunker("size-1024"),
+		AddWorkers(4),
+		AddResume(true),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.Chunker != "size-1024" {
+		t.Fatalf("got chunker %q, expected size-1024", settings.Chunker)
+	}
+	if adderOpts.Workers != 4 || !adderOpts.Resume {
+		t.Fatalf("got %+v, expected 4 workers and resume", adderOpts)
+	}
+	if len(addOptions.m) != 0 {
+		t.Fatal("expected the options of the parsed add to be dropped")
+	}
+
+	// Applied to settings ParseAddOptions didn't make, as by another
+	// implementation of the core API, they do nothing.
+	var other options.UnixfsAddSettings
+	if err := AddWorkers(4)(&other); err != nil {
+		t.Fatal(err)
+	}
+	if len(addOptions.m) != 0 {
+		t.Fatal("expected the options of other settings to be ignored")
+	}
+}
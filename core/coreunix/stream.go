@@ -0,0 +1,118 @@
+package coreunix
+
+import (
+	"errors"
+	"io"
+
+	files "github.com/ipfs/go-ipfs-files"
+)
+
+// streamBufferSize is how much of a streamed file is read ahead of the
+// worker adding it.
+const streamBufferSize = 4 << 20
+
+const streamReadSize = 256 << 10
+
+var errStreamSeek = errors.New("cannot seek a streamed file")
+
+// streamedFile passes the data of a file read from a stream to the worker
+// adding it, so that the stream can go on to the next file while the worker
+// chunks and hashes the end of this one.
+type streamedFile struct {
+	file files.File
+	data chan []byte
+	// err is the error reading the file, set before data is closed.
+	err  error
+	cur  []byte
+	done chan struct{}
+}
+
+func newStreamedFile(file files.File) *streamedFile {
+	return &streamedFile{
+		file: file,
+		data: make(chan []byte, streamBufferSize/streamReadSize),
+		done: make(chan struct{}),
+	}
+}
+
+// File returns the file the worker reads, which keeps the path of the
+// streamed file for the filestore.
+func (f *streamedFile) File() files.File {
+	if fi, ok := f.file.(files.FileInfo); ok {
+		return &streamedFile2{f, fi}
+	}
+	return f
+}
+
+// feed reads the whole file into the buffer of the worker, unless the worker
+// stops reading it.
+func (f *streamedFile) feed() {
+	defer close(f.data)
+	for {
+		buf := make([]byte, streamReadSize)
+		n, err := io.ReadFull(f.file, buf)
+		if n > 0 {
+			select {
+			case f.data <- buf[:n]:
+			case <-f.done:
+				return
+			}
+		}
+		switch err {
+		case nil:
+		case io.EOF, io.ErrUnexpectedEOF:
+			return
+		default:
+			f.err = err
+			return
+		}
+	}
+}
+
+func (f *streamedFile) Read(p []byte) (int, error) {
+	for len(f.cur) == 0 {
+		buf, ok := <-f.data
+		if !ok {
+			if f.err != nil {
+				return 0, f.err
+			}
+			return 0, io.EOF
+		}
+		f.cur = buf
+	}
+	n := copy(p, f.cur)
+	f.cur = f.cur[n:]
+	return n, nil
+}
+
+func (f *streamedFile) Seek(offset int64, whence int) (int64, error) {
+	return 0, errStreamSeek
+}
+
+func (f *streamedFile) Size() (int64, error) {
+	return f.file.Size()
+}
+
+// Close tells feed the worker is done with the file. The streamed file
+// itself is closed by the reader of the stream.
+func (f *streamedFile) Close() error {
+	close(f.done)
+	return nil
+}
+
+type streamedFile2 struct {
+	*streamedFile
+	files.FileInfo
+}
+
+func (f *streamedFile2) Read(p []byte) (int, error) {
+	return f.streamedFile.Read(p)
+}
+
+func (f *streamedFile2) Size() (int64, error) {
+	return f.streamedFile.Size()
+}
+
+func (f *streamedFile2) Close() error {
+	return f.streamedFile.Close()
+}
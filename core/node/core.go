@@ -58,18 +58,32 @@ func Dag(bs blockservice.BlockService) format.DAGService {
 	return merkledag.NewDAGService(bs)
 }
 
-// OnlineExchange creates new LibP2P backed block exchange (BitSwap)
+// OnlineExchange creates new LibP2P backed block exchange (BitSwap), passed
+// through the registered exchange wrappers
 func OnlineExchange(provide bool) interface{} {
-	return func(mctx helpers.MetricsCtx, lc fx.Lifecycle, host host.Host, rt routing.Routing, bs blockstore.GCBlockstore) exchange.Interface {
+	return func(mctx helpers.MetricsCtx, lc fx.Lifecycle, host host.Host, rt routing.Routing, bs blockstore.GCBlockstore) (exchange.Interface, error) {
 		bitswapNetwork := network.NewFromIpfsHost(host, rt)
-		exch := bitswap.New(helpers.LifecycleCtx(mctx, lc), bitswapNetwork, bs, bitswap.ProvideEnabled(provide))
+		bswap := bitswap.New(helpers.LifecycleCtx(mctx, lc), bitswapNetwork, bs, bitswap.ProvideEnabled(provide))
 		lc.Append(fx.Hook{
 			OnStop: func(ctx context.Context) error {
-				return exch.Close()
+				return bswap.Close()
 			},
 		})
-		return exch
 
+		exch, wrappers, err := wrapExchange(bswap, host, rt)
+		if err != nil {
+			return nil, err
+		}
+		if len(wrappers) > 0 {
+			// Hooks are stopped in reverse order: the wrappers are closed
+			// before bitswap.
+			lc.Append(fx.Hook{
+				OnStop: func(ctx context.Context) error {
+					return closeExchanges(wrappers)
+				},
+			})
+		}
+		return exch, nil
 	}
 }
 
@@ -0,0 +1,67 @@
+package node
+
+import (
+	"sync"
+
+	multierror "github.com/hashicorp/go-multierror"
+	exchange "github.com/ipfs/go-ipfs-exchange-interface"
+	host "github.com/libp2p/go-libp2p-core/host"
+	routing "github.com/libp2p/go-libp2p-core/routing"
+)
+
+// ExchangeWrapper wraps or replaces the block exchange of an online node,
+// Bitswap by default.
+type ExchangeWrapper func(exch exchange.Interface, h host.Host, rt routing.Routing) (exchange.Interface, error)
+
+var (
+	exchangeWrappersLk sync.Mutex
+	exchangeWrappers   []ExchangeWrapper
+)
+
+// RegisterExchangeWrapper registers w to be applied to the exchange of every
+// online node constructed afterwards. Wrappers are applied in the order they
+// were registered, each one getting the exchange returned by the previous one.
+// The node closes every exchange of the chain, outermost first: wrappers must
+// not close the exchange they wrap.
+func RegisterExchangeWrapper(w ExchangeWrapper) {
+	exchangeWrappersLk.Lock()
+	defer exchangeWrappersLk.Unlock()
+	exchangeWrappers = append(exchangeWrappers, w)
+}
+
+// wrapExchange applies the registered wrappers to exch. It returns the
+// resulting exchange and the exchanges built by the wrappers, to close with
+// closeExchanges. When a wrapper fails, the exchanges built before it are
+// closed.
+func wrapExchange(exch exchange.Interface, h host.Host, rt routing.Routing) (exchange.Interface, []exchange.Interface, error) {
+	exchangeWrappersLk.Lock()
+	wrappers := append([]ExchangeWrapper(nil), exchangeWrappers...)
+	exchangeWrappersLk.Unlock()
+
+	var built []exchange.Interface
+	for _, w := range wrappers {
+		wrapped, err := w(exch, h, rt)
+		if err != nil {
+			if cerr := closeExchanges(built); cerr != nil {
+				err = multierror.Append(err, cerr)
+			}
+			return nil, nil, err
+		}
+		if wrapped != exch {
+			built = append(built, wrapped)
+		}
+		exch = wrapped
+	}
+	return exch, built, nil
+}
+
+// closeExchanges closes exchs, last one first.
+func closeExchanges(exchs []exchange.Interface) error {
+	var errs error
+	for i := len(exchs) - 1; i >= 0; i-- {
+		if err := exchs[i].Close(); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+	}
+	return errs
+}
@@ -0,0 +1,103 @@
+package node
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	exchange "github.com/ipfs/go-ipfs-exchange-interface"
+	host "github.com/libp2p/go-libp2p-core/host"
+	routing "github.com/libp2p/go-libp2p-core/routing"
+)
+
+type testExchange struct {
+	exchange.Interface
+
+	name    string
+	wrapped exchange.Interface
+	closed  *[]string
+}
+
+func (e *testExchange) Close() error {
+	*e.closed = append(*e.closed, e.name)
+	return nil
+}
+
+func testWrapper(name string, closed *[]string) ExchangeWrapper {
+	return func(exch exchange.Interface, h host.Host, rt routing.Routing) (exchange.Interface, error) {
+		return &testExchange{name: name, wrapped: exch, closed: closed}, nil
+	}
+}
+
+// setExchangeWrappers replaces the registered wrappers until restore is
+// called.
+func setExchangeWrappers(wrappers ...ExchangeWrapper) (restore func()) {
+	exchangeWrappersLk.Lock()
+	old := exchangeWrappers
+	exchangeWrappers = nil
+	exchangeWrappersLk.Unlock()
+
+	for _, w := range wrappers {
+		RegisterExchangeWrapper(w)
+	}
+	return func() {
+		exchangeWrappersLk.Lock()
+		exchangeWrappers = old
+		exchangeWrappersLk.Unlock()
+	}
+}
+
+func TestWrapExchange(t *testing.T) {
+	var closed []string
+	passThrough := func(exch exchange.Interface, h host.Host, rt routing.Routing) (exchange.Interface, error) {
+		return exch, nil
+	}
+	defer setExchangeWrappers(testWrapper("first", &closed), passThrough, testWrapper("second", &closed))()
+
+	base := &testExchange{name: "base", closed: &closed}
+	exch, built, err := wrapExchange(base, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Wrappers apply in the order they were registered.
+	second, ok := exch.(*testExchange)
+	if !ok || second.name != "second" {
+		t.Fatalf("expected the second wrapper outermost, got %v", exch)
+	}
+	first, ok := second.wrapped.(*testExchange)
+	if !ok || first.name != "first" || first.wrapped != base {
+		t.Fatalf("expected the first wrapper to wrap the base exchange, got %v", second.wrapped)
+	}
+
+	// The exchange returned as-is isn't closed twice, nor is the base one.
+	if len(built) != 2 {
+		t.Fatalf("expected 2 built exchanges, got %d", len(built))
+	}
+	if err := closeExchanges(built); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(closed, []string{"second", "first"}) {
+		t.Fatalf("expected the outermost exchange closed first, got %v", closed)
+	}
+}
+
+func TestWrapExchangeError(t *testing.T) {
+	var closed []string
+	errWrap := errors.New("wrapper failed")
+	failing := func(exch exchange.Interface, h host.Host, rt routing.Routing) (exchange.Interface, error) {
+		return nil, errWrap
+	}
+	defer setExchangeWrappers(testWrapper("first", &closed), failing, testWrapper("second", &closed))()
+
+	base := &testExchange{name: "base", closed: &closed}
+	if _, _, err := wrapExchange(base, nil, nil); err != errWrap {
+		t.Fatalf("expected the wrapper error, got %v", err)
+	}
+
+	// The exchanges built before the failure are closed, the base one is
+	// left to its owner.
+	if !reflect.DeepEqual(closed, []string{"first"}) {
+		t.Fatalf("expected the first exchange closed, got %v", closed)
+	}
+}
@@ -86,6 +86,7 @@ func LibP2P(bcfg *BuildCfg, cfg *config.Config) fx.Option {
 		default:
 			return fx.Error(fmt.Errorf("unknown pubsub router %s", cfg.Pubsub.Router))
 		}
+		ps = fx.Options(ps, fx.Provide(PubsubTopics))
 	}
 
 	// Gather all the options
@@ -233,6 +234,7 @@ func Online(bcfg *BuildCfg, cfg *config.Config) fx.Option {
 		fx.Invoke(IpnsRepublisher(repubPeriod, recordLifetime)),
 
 		fx.Provide(p2p.New),
+		fx.Invoke(P2PForwards(cfg.Experimental.Libp2pStreamMounting)),
 
 		LibP2P(bcfg, cfg),
 		OnlineProviders(cfg.Experimental.StrategicProviding, cfg.Reprovider.Strategy, cfg.Reprovider.Interval),
@@ -6,6 +6,7 @@ import (
 
 	"github.com/ipfs/go-ipfs-util"
 	"github.com/ipfs/go-ipns"
+	"github.com/ipfs/go-path"
 	"github.com/libp2p/go-libp2p-core/crypto"
 	"github.com/libp2p/go-libp2p-core/peerstore"
 	"github.com/libp2p/go-libp2p-core/routing"
@@ -26,17 +27,65 @@ func RecordValidator(ps peerstore.Peerstore) record.Validator {
 	}
 }
 
+// NamesysConfig is the "Namesys" section of the config file.
+type NamesysConfig struct {
+	// StaticNames maps names to the paths they resolve to.
+	StaticNames map[string]string
+
+	// WellKnownNames lists patterns of domain names resolved by fetching
+	// https://<domain>/.well-known/dnslink.
+	WellKnownNames []string
+
+	// PersistCache persists the cache of resolved names in the datastore
+	// so that it survives restarts.
+	PersistCache bool
+}
+
 // Namesys creates new name system
 func Namesys(cacheSize int) func(rt routing.Routing, repo repo.Repo) (namesys.NameSystem, error) {
-	return func(rt routing.Routing, repo repo.Repo) (namesys.NameSystem, error) {
-		return namesys.NewNameSystem(rt, repo.Datastore(), cacheSize), nil
+	return func(rt routing.Routing, r repo.Repo) (namesys.NameSystem, error) {
+		var cfg NamesysConfig
+		if err := repo.ConfigSection(r, "Namesys", &cfg); err != nil {
+			return nil, fmt.Errorf("failure to parse config section Namesys: %s", err)
+		}
+
+		var opts []namesys.Option
+		if cfg.PersistCache {
+			opts = append(opts, namesys.WithPersistentCache(r.Datastore()))
+		}
+		if len(cfg.StaticNames) > 0 {
+			names := make(map[string]path.Path, len(cfg.StaticNames))
+			for name, value := range cfg.StaticNames {
+				p, err := path.ParsePath(value)
+				if err != nil {
+					return nil, fmt.Errorf("config setting Namesys.StaticNames.%s: %s", name, err)
+				}
+				names[name] = p
+			}
+
+			static := namesys.NewStaticResolver(names)
+			for name := range names {
+				opts = append(opts, namesys.WithResolver(name, static))
+			}
+		}
+		if len(cfg.WellKnownNames) > 0 {
+			wellKnown := namesys.NewWellKnownResolver(nil)
+			for _, pattern := range cfg.WellKnownNames {
+				if err := namesys.ValidatePattern(pattern); err != nil {
+					return nil, fmt.Errorf("config setting Namesys.WellKnownNames: %s", err)
+				}
+				opts = append(opts, namesys.WithResolver(pattern, wellKnown))
+			}
+		}
+
+		return namesys.NewNameSystem(rt, r.Datastore(), cacheSize, opts...), nil
 	}
 }
 
 // IpnsRepublisher runs new IPNS republisher service
-func IpnsRepublisher(repubPeriod time.Duration, recordLifetime time.Duration) func(lcProcess, namesys.NameSystem, repo.Repo, crypto.PrivKey) error {
-	return func(lc lcProcess, namesys namesys.NameSystem, repo repo.Repo, privKey crypto.PrivKey) error {
-		repub := republisher.NewRepublisher(namesys, repo.Datastore(), privKey, repo.Keystore())
+func IpnsRepublisher(repubPeriod time.Duration, recordLifetime time.Duration) func(lcProcess, namesys.NameSystem, routing.Routing, repo.Repo, crypto.PrivKey) error {
+	return func(lc lcProcess, namesys namesys.NameSystem, rt routing.Routing, repo repo.Repo, privKey crypto.PrivKey) error {
+		repub := republisher.NewRepublisher(namesys, rt, repo.Datastore(), privKey, repo.Keystore())
 
 		if repubPeriod != 0 {
 			if !util.Debug && (repubPeriod < time.Minute || repubPeriod > (time.Hour*24)) {
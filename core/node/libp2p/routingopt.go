@@ -2,6 +2,8 @@ package libp2p
 
 import (
 	"context"
+	"fmt"
+	"sync"
 
 	"github.com/ipfs/go-datastore"
 	nilrouting "github.com/ipfs/go-ipfs-routing/none"
@@ -34,3 +36,34 @@ func constructClientDHTRouting(ctx context.Context, host host.Host, dstore datas
 var DHTOption RoutingOption = constructDHTRouting
 var DHTClientOption RoutingOption = constructClientDHTRouting
 var NilRouterOption RoutingOption = nilrouting.ConstructNilRouting
+
+var routingOptionsLk sync.Mutex
+
+// routingOptions are the routing options selectable by name, with the
+// Routing.Type config or the --routing flag of the daemon.
+var routingOptions = map[string]RoutingOption{
+	"dht":       DHTOption,
+	"dhtclient": DHTClientOption,
+	"none":      NilRouterOption,
+}
+
+// RegisterRoutingOption makes opt selectable by name.
+func RegisterRoutingOption(name string, opt RoutingOption) error {
+	routingOptionsLk.Lock()
+	defer routingOptionsLk.Unlock()
+
+	if _, ok := routingOptions[name]; ok {
+		return fmt.Errorf("already have a routing option named %q", name)
+	}
+	routingOptions[name] = opt
+	return nil
+}
+
+// NamedRoutingOption returns the routing option registered under name.
+func NamedRoutingOption(name string) (RoutingOption, bool) {
+	routingOptionsLk.Lock()
+	defer routingOptionsLk.Unlock()
+
+	opt, ok := routingOptions[name]
+	return opt, ok
+}
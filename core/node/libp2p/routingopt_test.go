@@ -0,0 +1,52 @@
+package libp2p
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	host "github.com/libp2p/go-libp2p-core/host"
+	routing "github.com/libp2p/go-libp2p-core/routing"
+	record "github.com/libp2p/go-libp2p-record"
+)
+
+func TestRegisterRoutingOption(t *testing.T) {
+	errTestRouting := errors.New("test routing")
+	opt := func(context.Context, host.Host, datastore.Batching, record.Validator) (routing.Routing, error) {
+		return nil, errTestRouting
+	}
+
+	if err := RegisterRoutingOption("dht", opt); err == nil {
+		t.Fatal("expected registering a built-in routing option name to fail")
+	}
+
+	if err := RegisterRoutingOption("test-routing", opt); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		routingOptionsLk.Lock()
+		delete(routingOptions, "test-routing")
+		routingOptionsLk.Unlock()
+	}()
+	if err := RegisterRoutingOption("test-routing", opt); err == nil {
+		t.Fatal("expected registering a routing option twice to fail")
+	}
+
+	named, ok := NamedRoutingOption("test-routing")
+	if !ok {
+		t.Fatal("registered routing option not found")
+	}
+	if _, err := named(context.Background(), nil, nil, nil); err != errTestRouting {
+		t.Fatalf("got another routing option than the registered one: %v", err)
+	}
+
+	for _, name := range []string{"dht", "dhtclient", "none"} {
+		if _, ok := NamedRoutingOption(name); !ok {
+			t.Errorf("built-in routing option %s not found", name)
+		}
+	}
+	if _, ok := NamedRoutingOption("unknown"); ok {
+		t.Error("found an unregistered routing option")
+	}
+}
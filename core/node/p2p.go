@@ -0,0 +1,44 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.uber.org/fx"
+
+	"github.com/ipfs/go-ipfs/core/node/helpers"
+	"github.com/ipfs/go-ipfs/p2p"
+	"github.com/ipfs/go-ipfs/repo"
+)
+
+// P2PForwards creates the p2p forwards and listeners declared in the "P2P"
+// config section when the node starts
+func P2PForwards(streamMounting bool) func(helpers.MetricsCtx, fx.Lifecycle, repo.Repo, *p2p.P2P) error {
+	return func(mctx helpers.MetricsCtx, lc fx.Lifecycle, r repo.Repo, p *p2p.P2P) error {
+		var cfg p2p.Config
+		if err := repo.ConfigSection(r, "P2P", &cfg); err != nil {
+			return fmt.Errorf("invalid P2P config: %s", err)
+		}
+		if len(cfg.Forwards) == 0 && len(cfg.Listeners) == 0 {
+			return nil
+		}
+		if !streamMounting {
+			return errors.New("P2P forwards and listeners require Experimental.Libp2pStreamMounting")
+		}
+
+		if err := p.Restore(helpers.LifecycleCtx(mctx, lc), cfg); err != nil {
+			return err
+		}
+
+		lc.Append(fx.Hook{
+			OnStop: func(_ context.Context) error {
+				all := func(p2p.Listener) bool { return true }
+				p.ListenersLocal.Close(all)
+				p.ListenersP2P.Close(all)
+				return nil
+			},
+		})
+		return nil
+	}
+}
@@ -0,0 +1,32 @@
+package node
+
+import (
+	"fmt"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"go.uber.org/fx"
+
+	"github.com/ipfs/go-ipfs/core/node/helpers"
+	"github.com/ipfs/go-ipfs/repo"
+	"github.com/ipfs/go-ipfs/topics"
+)
+
+// PubsubTopics creates the manager of the pubsub topic settings, and starts
+// the message logs declared in the "PubsubTopics" config section
+func PubsubTopics(mctx helpers.MetricsCtx, lc fx.Lifecycle, ps *pubsub.PubSub, r repo.Repo) (*topics.Manager, error) {
+	var cfg topics.Config
+	if err := repo.ConfigSection(r, topics.ConfigKey, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid %s config: %s", topics.ConfigKey, err)
+	}
+
+	m := topics.NewManager(helpers.LifecycleCtx(mctx, lc), ps, r.Datastore())
+	for topic, tcfg := range cfg {
+		if tcfg.Log == nil {
+			continue
+		}
+		if err := m.StartLog(topic, tcfg); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
@@ -1,10 +1,13 @@
 package node
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"syscall"
 	"time"
 
+	humanize "github.com/dustin/go-humanize"
 	"github.com/ipfs/go-datastore"
 	"github.com/ipfs/go-datastore/retrystore"
 	blockstore "github.com/ipfs/go-ipfs-blockstore"
@@ -15,6 +18,7 @@ import (
 	"github.com/ipfs/go-ipfs/core/node/helpers"
 	"github.com/ipfs/go-ipfs/repo"
 	"github.com/ipfs/go-ipfs/thirdparty/cidv0v1"
+	"github.com/ipfs/go-ipfs/thirdparty/quotabs"
 	"github.com/ipfs/go-ipfs/thirdparty/verifbs"
 )
 
@@ -37,6 +41,43 @@ func Datastore(repo repo.Repo) datastore.Datastore {
 	return repo.Datastore()
 }
 
+// QuotaConfig is the "Quota" section of the config file.
+type QuotaConfig struct {
+	// Enforce rejects new blocks once the repo would grow past
+	// Datastore.StorageMax, instead of only using it to trigger GC.
+	Enforce bool
+}
+
+// quotaBlockstore wraps bs to enforce the storage limit of the repo, if the
+// config asks for it.
+func quotaBlockstore(r repo.Repo, bs blockstore.Blockstore) (blockstore.Blockstore, error) {
+	var qcfg QuotaConfig
+	if err := repo.ConfigSection(r, "Quota", &qcfg); err != nil {
+		return nil, fmt.Errorf("failure to parse config section Quota: %s", err)
+	}
+	if !qcfg.Enforce {
+		return bs, nil
+	}
+
+	cfg, err := r.Config()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Datastore.StorageMax == "" {
+		return nil, errors.New("Quota.Enforce requires Datastore.StorageMax to be set")
+	}
+	max, err := humanize.ParseBytes(cfg.Datastore.StorageMax)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Datastore.StorageMax: %s", err)
+	}
+
+	usage, err := r.GetStorageUsage()
+	if err != nil {
+		return nil, err
+	}
+	return quotabs.New(bs, max, usage), nil
+}
+
 // BaseBlocks is the lower level blockstore without GC or Filestore layers
 type BaseBlocks blockstore.Blockstore
 
@@ -49,8 +90,13 @@ func BaseBlockstoreCtor(cacheOpts blockstore.CacheOpts, nilRepo bool, hashOnRead
 			Retries:     6,
 			TempErrFunc: isTooManyFDError,
 		}
-		// hash security
 		bs = blockstore.NewBlockstore(rds)
+		if !nilRepo {
+			if bs, err = quotaBlockstore(repo, bs); err != nil {
+				return nil, err
+			}
+		}
+		// hash security
 		bs = &verifbs.VerifBS{Blockstore: bs}
 
 		if !nilRepo {
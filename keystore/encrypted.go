@@ -0,0 +1,489 @@
+package keystore
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	dir "github.com/ipfs/go-ipfs/thirdparty/dir"
+
+	ci "github.com/libp2p/go-libp2p-core/crypto"
+	scrypt "golang.org/x/crypto/scrypt"
+)
+
+// ErrKeystoreLocked is returned when using the keys of a locked keystore.
+var ErrKeystoreLocked = errors.New("keystore is locked, unlock it with 'ipfs key unlock'")
+
+// ErrBadPassphrase is returned when unlocking a keystore with the wrong
+// passphrase.
+var ErrBadPassphrase = errors.New("incorrect keystore passphrase")
+
+// Locker is implemented by keystores that keep their keys encrypted at rest
+// and must be unlocked with a passphrase before the keys can be used.
+type Locker interface {
+	// Unlock derives the key encryption key from the passphrase. The first
+	// call sets the passphrase of the keystore.
+	Unlock(passphrase []byte) error
+	// Lock forgets the key encryption key.
+	Lock()
+	// Locked returns whether the keystore is locked.
+	Locked() bool
+	// HasPassphrase returns whether the passphrase of the keystore is set.
+	HasPassphrase() (bool, error)
+}
+
+// paramsFile holds the key derivation parameters of an EncryptedKeystore. It
+// starts with a period so that it is never mistaken for a key.
+const paramsFile = ".params"
+
+// encryptedMagic prefixes encrypted key files. Plaintext key files are
+// marshalled protobufs, which never start with a zero byte.
+//
+// The magic is followed by the length of the public key as a uvarint, the
+// public key in the clear, so that keys can be listed while the keystore is
+// locked, and the nonce and ciphertext of the private key.
+var encryptedMagic = []byte("\x00ipfs-key-v1")
+
+// passphraseCheck is encrypted with the key encryption key and stored with
+// the parameters, to tell wrong passphrases apart.
+var passphraseCheck = []byte("ipfs keystore passphrase check")
+
+const (
+	scryptN      = 1 << 15
+	scryptR      = 8
+	scryptP      = 1
+	scryptKeyLen = 32
+	saltLen      = 32
+)
+
+type encryptionParams struct {
+	Salt  []byte
+	N     int
+	R     int
+	P     int
+	Check []byte
+}
+
+// EncryptedKeystore is a keystore backed by files in a given directory, like
+// FSKeystore, in which every key is encrypted with AES-GCM under a key
+// derived from a passphrase with scrypt.
+//
+// Keys written in plaintext by FSKeystore are encrypted in place the first
+// time the keystore is unlocked.
+type EncryptedKeystore struct {
+	dir string
+
+	mu  sync.RWMutex
+	kek []byte // nil while locked
+}
+
+var _ Keystore = (*EncryptedKeystore)(nil)
+var _ Locker = (*EncryptedKeystore)(nil)
+var _ PublicKeyGetter = (*EncryptedKeystore)(nil)
+
+// NewEncryptedKeystore returns a locked EncryptedKeystore for the given
+// directory.
+func NewEncryptedKeystore(dir string) (*EncryptedKeystore, error) {
+	_, err := os.Stat(dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+		if err := os.Mkdir(dir, 0700); err != nil {
+			return nil, err
+		}
+	}
+
+	return &EncryptedKeystore{dir: dir}, nil
+}
+
+// Has returns whether or not a key exist in the Keystore
+func (ks *EncryptedKeystore) Has(name string) (bool, error) {
+	if err := validateName(name); err != nil {
+		return false, err
+	}
+
+	_, err := os.Stat(filepath.Join(ks.dir, name))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Put stores a key in the Keystore, if a key with the same name already exists, returns ErrKeyExists
+func (ks *EncryptedKeystore) Put(name string, k ci.PrivKey) error {
+	if err := validateName(name); err != nil {
+		return err
+	}
+
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	if ks.kek == nil {
+		return ErrKeystoreLocked
+	}
+
+	kp := filepath.Join(ks.dir, name)
+	_, err := os.Stat(kp)
+	if err == nil {
+		return ErrKeyExists
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	data, err := sealKey(ks.kek, name, k)
+	if err != nil {
+		return err
+	}
+
+	fi, err := os.OpenFile(kp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	defer fi.Close()
+
+	_, err = fi.Write(data)
+	return err
+}
+
+// Get retrieves a key from the Keystore if it exists, and returns ErrNoSuchKey
+// otherwise.
+func (ks *EncryptedKeystore) Get(name string) (ci.PrivKey, error) {
+	if err := validateName(name); err != nil {
+		return nil, err
+	}
+
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	if ks.kek == nil {
+		return nil, ErrKeystoreLocked
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(ks.dir, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNoSuchKey
+		}
+		return nil, err
+	}
+
+	if bytes.HasPrefix(data, encryptedMagic) {
+		data, err = openKey(ks.kek, name, data)
+		if err != nil {
+			return nil, fmt.Errorf("decrypting key %s: %s", name, err)
+		}
+	}
+
+	return ci.UnmarshalPrivateKey(data)
+}
+
+// PublicKey implements PublicKeyGetter. It doesn't need the keystore to be
+// unlocked.
+func (ks *EncryptedKeystore) PublicKey(name string) (ci.PubKey, error) {
+	if err := validateName(name); err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(ks.dir, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNoSuchKey
+		}
+		return nil, err
+	}
+
+	// Keys not encrypted yet are stored in plaintext.
+	if !bytes.HasPrefix(data, encryptedMagic) {
+		k, err := ci.UnmarshalPrivateKey(data)
+		if err != nil {
+			return nil, err
+		}
+		return k.GetPublic(), nil
+	}
+
+	pub, _, err := splitKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("reading key %s: %s", name, err)
+	}
+	return ci.UnmarshalPublicKey(pub)
+}
+
+// Delete removes a key from the Keystore
+func (ks *EncryptedKeystore) Delete(name string) error {
+	if err := validateName(name); err != nil {
+		return err
+	}
+
+	return os.Remove(filepath.Join(ks.dir, name))
+}
+
+// List return a list of key identifier
+func (ks *EncryptedKeystore) List() ([]string, error) {
+	dir, err := os.Open(ks.dir)
+	if err != nil {
+		return nil, err
+	}
+	defer dir.Close()
+
+	dirs, err := dir.Readdirnames(0)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]string, 0, len(dirs))
+	for _, name := range dirs {
+		if name == paramsFile {
+			continue
+		}
+		if err := validateName(name); err == nil {
+			list = append(list, name)
+		} else {
+			log.Warningf("Ignoring the invalid keyfile: %s", name)
+		}
+	}
+
+	return list, nil
+}
+
+// Unlock implements Locker.
+func (ks *EncryptedKeystore) Unlock(passphrase []byte) error {
+	if len(passphrase) == 0 {
+		return errors.New("keystore passphrase must not be empty")
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	params, err := ks.readParams()
+	switch {
+	case os.IsNotExist(err):
+		params, err = ks.initParams(passphrase)
+		if err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	}
+
+	kek, err := scrypt.Key(passphrase, params.Salt, params.N, params.R, params.P, scryptKeyLen)
+	if err != nil {
+		return err
+	}
+	check, err := open(kek, params.Check, nil)
+	if err != nil || !bytes.Equal(check, passphraseCheck) {
+		return ErrBadPassphrase
+	}
+
+	ks.kek = kek
+	return ks.migrate()
+}
+
+// Lock implements Locker.
+func (ks *EncryptedKeystore) Lock() {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	for i := range ks.kek {
+		ks.kek[i] = 0
+	}
+	ks.kek = nil
+}
+
+// Locked implements Locker.
+func (ks *EncryptedKeystore) Locked() bool {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	return ks.kek == nil
+}
+
+// HasPassphrase implements Locker.
+func (ks *EncryptedKeystore) HasPassphrase() (bool, error) {
+	_, err := os.Stat(filepath.Join(ks.dir, paramsFile))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (ks *EncryptedKeystore) readParams() (*encryptionParams, error) {
+	data, err := ioutil.ReadFile(filepath.Join(ks.dir, paramsFile))
+	if err != nil {
+		return nil, err
+	}
+	params := new(encryptionParams)
+	if err := json.Unmarshal(data, params); err != nil {
+		return nil, fmt.Errorf("invalid keystore parameters: %s", err)
+	}
+	kdf := scryptParams{
+		CostParameter:            params.N,
+		BlockSize:                params.R,
+		ParallelizationParameter: params.P,
+	}
+	if err := checkScryptParams(kdf); err != nil {
+		return nil, fmt.Errorf("invalid keystore parameters: %s", err)
+	}
+	return params, nil
+}
+
+// initParams sets the passphrase of a keystore that doesn't have one yet.
+func (ks *EncryptedKeystore) initParams(passphrase []byte) (*encryptionParams, error) {
+	params := &encryptionParams{
+		Salt: make([]byte, saltLen),
+		N:    scryptN,
+		R:    scryptR,
+		P:    scryptP,
+	}
+	if _, err := rand.Read(params.Salt); err != nil {
+		return nil, err
+	}
+
+	kek, err := scrypt.Key(passphrase, params.Salt, params.N, params.R, params.P, scryptKeyLen)
+	if err != nil {
+		return nil, err
+	}
+	params.Check, err = seal(kek, passphraseCheck, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	if err := dir.WriteFileAtomic(filepath.Join(ks.dir, paramsFile), data, 0600); err != nil {
+		return nil, err
+	}
+	return params, nil
+}
+
+// migrate encrypts the keys still stored in plaintext. Must be called with
+// the keystore unlocked and the write lock held.
+func (ks *EncryptedKeystore) migrate() error {
+	names, err := ks.List()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		kp := filepath.Join(ks.dir, name)
+		data, err := ioutil.ReadFile(kp)
+		if err != nil {
+			return err
+		}
+		if bytes.HasPrefix(data, encryptedMagic) {
+			continue
+		}
+
+		// Make sure this is a key before encrypting it.
+		k, err := ci.UnmarshalPrivateKey(data)
+		if err != nil {
+			log.Warningf("not encrypting invalid keyfile %s: %s", name, err)
+			continue
+		}
+
+		sealed, err := sealKey(ks.kek, name, k)
+		if err != nil {
+			return err
+		}
+		if err := dir.WriteFileAtomic(kp, sealed, 0600); err != nil {
+			return err
+		}
+		log.Infof("encrypted plaintext key %s", name)
+	}
+	return nil
+}
+
+// sealKey encrypts k, bound to its name and public key.
+func sealKey(kek []byte, name string, k ci.PrivKey) ([]byte, error) {
+	priv, err := k.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	pub, err := k.GetPublic().Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	header := append([]byte(nil), encryptedMagic...)
+	var n [binary.MaxVarintLen64]byte
+	header = append(header, n[:binary.PutUvarint(n[:], uint64(len(pub)))]...)
+	header = append(header, pub...)
+
+	sealed, err := seal(kek, priv, append([]byte(name), header...))
+	if err != nil {
+		return nil, err
+	}
+	return append(header, sealed...), nil
+}
+
+// openKey decrypts a key sealed with sealKey, returning the marshalled
+// private key.
+func openKey(kek []byte, name string, data []byte) ([]byte, error) {
+	_, sealed, err := splitKey(data)
+	if err != nil {
+		return nil, err
+	}
+	header := data[:len(data)-len(sealed)]
+	return open(kek, sealed, append([]byte(name), header...))
+}
+
+// splitKey splits a key sealed with sealKey into its public key and the
+// encrypted private key.
+func splitKey(data []byte) (pub, sealed []byte, err error) {
+	if !bytes.HasPrefix(data, encryptedMagic) {
+		return nil, nil, errors.New("not an encrypted key")
+	}
+	data = data[len(encryptedMagic):]
+	l, n := binary.Uvarint(data)
+	if n <= 0 || l > uint64(len(data)-n) {
+		return nil, nil, errors.New("encrypted key is truncated")
+	}
+	data = data[n:]
+	return data[:l], data[l:], nil
+}
+
+// seal encrypts plaintext, returning the nonce followed by the ciphertext.
+func seal(kek, plaintext, additional []byte) ([]byte, error) {
+	aead, err := newAEAD(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(append([]byte(nil), nonce...), nonce, plaintext, additional), nil
+}
+
+func open(kek, data, additional []byte) ([]byte, error) {
+	aead, err := newAEAD(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < aead.NonceSize() {
+		return nil, errors.New("encrypted data is truncated")
+	}
+	return aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], additional)
+}
+
+func newAEAD(kek []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
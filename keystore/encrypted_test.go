@@ -0,0 +1,195 @@
+package keystore
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	ci "github.com/libp2p/go-libp2p-core/crypto"
+)
+
+func TestEncryptedKeystore(t *testing.T) {
+	tdir, err := ioutil.TempDir("", "keystore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tdir)
+
+	// Start with a key stored in plaintext.
+	fsks, err := NewFSKeystore(tdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := privKeyOrFatal(t)
+	if err := fsks.Put("plain", plain); err != nil {
+		t.Fatal(err)
+	}
+
+	ks, err := NewEncryptedKeystore(tdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ks.Locked() {
+		t.Fatal("keystore should start locked")
+	}
+	if set, err := ks.HasPassphrase(); err != nil || set {
+		t.Fatalf("expected no passphrase, got %t, %v", set, err)
+	}
+	if _, err := ks.Get("plain"); err != ErrKeystoreLocked {
+		t.Fatalf("expected ErrKeystoreLocked, got %v", err)
+	}
+	if err := ks.Put("foo", privKeyOrFatal(t)); err != ErrKeystoreLocked {
+		t.Fatalf("expected ErrKeystoreLocked, got %v", err)
+	}
+
+	// The first unlock sets the passphrase and encrypts plaintext keys.
+	if err := ks.Unlock([]byte("hunter2")); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := ioutil.ReadFile(filepath.Join(tdir, "plain"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plainBytes, _ := plain.Bytes()
+	if !bytes.HasPrefix(raw, encryptedMagic) || bytes.Contains(raw, plainBytes) {
+		t.Fatal("plaintext key wasn't encrypted")
+	}
+
+	foo := privKeyOrFatal(t)
+	if err := ks.Put("foo", foo); err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.Put("foo", foo); err != ErrKeyExists {
+		t.Fatalf("expected ErrKeyExists, got %v", err)
+	}
+	list, err := ks.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("expected 2 keys, got %v", list)
+	}
+
+	// Public keys are readable while locked.
+	ks.Lock()
+	if set, err := ks.HasPassphrase(); err != nil || !set {
+		t.Fatalf("expected a passphrase, got %t, %v", set, err)
+	}
+	for name, k := range map[string]ci.PrivKey{"plain": plain, "foo": foo} {
+		pub, err := PublicKey(ks, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !pub.Equals(k.GetPublic()) {
+			t.Fatalf("public key of %s doesn't match", name)
+		}
+	}
+	if _, err := PublicKey(ks, "nope"); err != ErrNoSuchKey {
+		t.Fatalf("expected ErrNoSuchKey, got %v", err)
+	}
+
+	if err := ks.Unlock([]byte("hunter3")); err != ErrBadPassphrase {
+		t.Fatalf("expected ErrBadPassphrase, got %v", err)
+	}
+
+	// A new instance unlocks with the same passphrase.
+	ks, err = NewEncryptedKeystore(tdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.Unlock([]byte("hunter2")); err != nil {
+		t.Fatal(err)
+	}
+	k, err := ks.Get("plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !k.Equals(plain) {
+		t.Fatal("migrated key doesn't match")
+	}
+	k, err = ks.Get("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !k.Equals(foo) {
+		t.Fatal("key doesn't match")
+	}
+
+	// Encrypted files are bound to their name.
+	if err := os.Rename(filepath.Join(tdir, "foo"), filepath.Join(tdir, "bar")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ks.Get("bar"); err == nil {
+		t.Fatal("expected renamed key file not to decrypt")
+	}
+
+	// So is the public key stored in the clear.
+	raw, err = ioutil.ReadFile(filepath.Join(tdir, "bar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, sealed, err := splitKey(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := privKeyOrFatal(t).GetPublic().Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged := append([]byte(nil), encryptedMagic...)
+	forged = append(forged, byte(len(other)))
+	forged = append(forged, other...)
+	forged = append(forged, sealed...)
+	if err := ioutil.WriteFile(filepath.Join(tdir, "bar"), forged, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(tdir, "bar"), filepath.Join(tdir, "foo")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ks.Get("foo"); err == nil {
+		t.Fatal("expected key file with a swapped public key not to decrypt")
+	}
+}
+
+func TestEncryptedKeystoreBadParams(t *testing.T) {
+	tdir, err := ioutil.TempDir("", "keystore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tdir)
+
+	ks, err := NewEncryptedKeystore(tdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.Unlock([]byte("hunter2")); err != nil {
+		t.Fatal(err)
+	}
+	ks.Lock()
+
+	params, err := ks.readParams()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []struct{ n, r, p int }{
+		{1 << 40, 8, 1},
+		{1 << 15, 1 << 20, 1},
+		{1 << 15, 8, 1 << 30},
+		{0, 8, 1},
+	} {
+		params.N, params.R, params.P = p.n, p.r, p.p
+		data, err := json.Marshal(params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(tdir, paramsFile), data, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := ks.Unlock([]byte("hunter2")); err == nil || err == ErrBadPassphrase {
+			t.Fatalf("expected N=%d, r=%d, p=%d to be refused, got %v", p.n, p.r, p.p, err)
+		}
+	}
+}
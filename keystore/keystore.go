@@ -28,6 +28,25 @@ type Keystore interface {
 	List() ([]string, error)
 }
 
+// PublicKeyGetter is implemented by keystores that can return the public key
+// of a key without reading the private key, e.g. while they are locked.
+type PublicKeyGetter interface {
+	PublicKey(name string) (ci.PubKey, error)
+}
+
+// PublicKey returns the public key of the key with the given name, without
+// using the private key when the keystore allows it.
+func PublicKey(ks Keystore, name string) (ci.PubKey, error) {
+	if pkg, ok := ks.(PublicKeyGetter); ok {
+		return pkg.PublicKey(name)
+	}
+	k, err := ks.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	return k.GetPublic(), nil
+}
+
 var ErrNoSuchKey = fmt.Errorf("no key by the given name was found")
 var ErrKeyExists = fmt.Errorf("key by that name already exists, refusing to overwrite")
 
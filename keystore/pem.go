@@ -0,0 +1,347 @@
+package keystore
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	ci "github.com/libp2p/go-libp2p-core/crypto"
+	"golang.org/x/crypto/ed25519"
+	scrypt "golang.org/x/crypto/scrypt"
+)
+
+// ErrPassphraseRequired is returned when decoding an encrypted key without a
+// passphrase.
+var ErrPassphraseRequired = errors.New("key is encrypted, a passphrase is required")
+
+const (
+	pemPrivateKey          = "PRIVATE KEY"
+	pemEncryptedPrivateKey = "ENCRYPTED PRIVATE KEY"
+	pemRSAPrivateKey       = "RSA PRIVATE KEY"
+	pemECPrivateKey        = "EC PRIVATE KEY"
+)
+
+var (
+	oidEd25519   = asn1.ObjectIdentifier{1, 3, 101, 112}
+	oidPBES2     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidScrypt    = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11591, 4, 11}
+	oidAES256CBC = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+const (
+	// maxScryptMemory bounds the memory used to decrypt an imported key or
+	// to unlock the keystore, which is 128·r·N bytes.
+	maxScryptMemory = 256 << 20
+	// maxScryptWork bounds the time spent decrypting an imported key or
+	// unlocking the keystore, which grows with 128·r·N·p.
+	maxScryptWork = 1 << 30
+)
+
+// pkcs8 is the PKCS #8 PrivateKeyInfo structure (RFC 5208), minus the
+// optional attributes.
+type pkcs8 struct {
+	Version    int
+	Algo       pkix.AlgorithmIdentifier
+	PrivateKey []byte
+}
+
+// encryptedPrivateKeyInfo is the PKCS #8 EncryptedPrivateKeyInfo structure.
+type encryptedPrivateKeyInfo struct {
+	Algo          pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+// pbes2Params are the parameters of the PBES2 encryption scheme (RFC 8018).
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+// scryptParams are the parameters of the scrypt key derivation function
+// (RFC 7914).
+type scryptParams struct {
+	Salt                     []byte
+	CostParameter            int
+	BlockSize                int
+	ParallelizationParameter int
+	KeyLength                int `asn1:"optional"`
+}
+
+// MarshalPEM encodes a private key as a PKCS #8 PEM block. If a passphrase
+// is given, the key is encrypted with PBES2, using scrypt and AES-256-CBC,
+// which OpenSSL understands.
+//
+// RSA, ECDSA and Ed25519 keys are supported.
+func MarshalPEM(k ci.PrivKey, passphrase []byte) ([]byte, error) {
+	der, err := marshalPKCS8(k)
+	if err != nil {
+		return nil, err
+	}
+
+	block := &pem.Block{Type: pemPrivateKey, Bytes: der}
+	if len(passphrase) > 0 {
+		block.Type = pemEncryptedPrivateKey
+		block.Bytes, err = encryptPKCS8(der, passphrase)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return pem.EncodeToMemory(block), nil
+}
+
+// UnmarshalPEM decodes a private key encoded by MarshalPEM. Unencrypted
+// PKCS #1 RSA keys and SEC 1 EC keys are accepted too.
+func UnmarshalPEM(data, passphrase []byte) (ci.PrivKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	switch block.Type {
+	case pemPrivateKey:
+		return unmarshalPKCS8(block.Bytes)
+	case pemEncryptedPrivateKey:
+		if len(passphrase) == 0 {
+			return nil, ErrPassphraseRequired
+		}
+		der, err := decryptPKCS8(block.Bytes, passphrase)
+		if err != nil {
+			return nil, err
+		}
+		k, err := unmarshalPKCS8(der)
+		if err != nil {
+			// Decrypting with the wrong key may leave valid padding.
+			return nil, ErrBadPassphrase
+		}
+		return k, nil
+	case pemRSAPrivateKey:
+		return ci.UnmarshalRsaPrivateKey(block.Bytes)
+	case pemECPrivateKey:
+		return ci.UnmarshalECDSAPrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+}
+
+func marshalPKCS8(k ci.PrivKey) ([]byte, error) {
+	raw, err := k.Raw()
+	if err != nil {
+		return nil, err
+	}
+
+	switch k.(type) {
+	case *ci.RsaPrivateKey:
+		sk, err := x509.ParsePKCS1PrivateKey(raw)
+		if err != nil {
+			return nil, err
+		}
+		return x509.MarshalPKCS8PrivateKey(sk)
+	case *ci.ECDSAPrivateKey:
+		sk, err := x509.ParseECPrivateKey(raw)
+		if err != nil {
+			return nil, err
+		}
+		return x509.MarshalPKCS8PrivateKey(sk)
+	case *ci.Ed25519PrivateKey:
+		// RFC 8410: the private key is the seed, wrapped in an octet
+		// string.
+		seed, err := asn1.Marshal(raw[:ed25519.SeedSize])
+		if err != nil {
+			return nil, err
+		}
+		return asn1.Marshal(pkcs8{
+			Algo:       pkix.AlgorithmIdentifier{Algorithm: oidEd25519},
+			PrivateKey: seed,
+		})
+	default:
+		return nil, fmt.Errorf("key type %T can't be encoded as PKCS #8", k)
+	}
+}
+
+func unmarshalPKCS8(der []byte) (ci.PrivKey, error) {
+	var info pkcs8
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		return nil, err
+	}
+
+	// Ed25519 is handled by hand, x509 doesn't support it yet.
+	if info.Algo.Algorithm.Equal(oidEd25519) {
+		var seed []byte
+		if _, err := asn1.Unmarshal(info.PrivateKey, &seed); err != nil {
+			return nil, err
+		}
+		if len(seed) != ed25519.SeedSize {
+			return nil, fmt.Errorf("invalid ed25519 key size %d", len(seed))
+		}
+		return ci.UnmarshalEd25519PrivateKey(ed25519.NewKeyFromSeed(seed))
+	}
+
+	sk, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+	switch sk := sk.(type) {
+	case *rsa.PrivateKey:
+		return ci.UnmarshalRsaPrivateKey(x509.MarshalPKCS1PrivateKey(sk))
+	case *ecdsa.PrivateKey:
+		raw, err := x509.MarshalECPrivateKey(sk)
+		if err != nil {
+			return nil, err
+		}
+		return ci.UnmarshalECDSAPrivateKey(raw)
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", sk)
+	}
+}
+
+func encryptPKCS8(der, passphrase []byte) ([]byte, error) {
+	kdf := scryptParams{
+		Salt:                     make([]byte, saltLen),
+		CostParameter:            scryptN,
+		BlockSize:                scryptR,
+		ParallelizationParameter: scryptP,
+		KeyLength:                scryptKeyLen,
+	}
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(kdf.Salt); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+
+	key, err := scrypt.Key(passphrase, kdf.Salt, kdf.CostParameter, kdf.BlockSize, kdf.ParallelizationParameter, kdf.KeyLength)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	padding := aes.BlockSize - len(der)%aes.BlockSize
+	data := append(append([]byte(nil), der...), bytes.Repeat([]byte{byte(padding)}, padding)...)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(data, data)
+
+	kdfParams, err := asn1.Marshal(kdf)
+	if err != nil {
+		return nil, err
+	}
+	ivParam, err := asn1.Marshal(iv)
+	if err != nil {
+		return nil, err
+	}
+	params, err := asn1.Marshal(pbes2Params{
+		KeyDerivationFunc: pkix.AlgorithmIdentifier{
+			Algorithm:  oidScrypt,
+			Parameters: asn1.RawValue{FullBytes: kdfParams},
+		},
+		EncryptionScheme: pkix.AlgorithmIdentifier{
+			Algorithm:  oidAES256CBC,
+			Parameters: asn1.RawValue{FullBytes: ivParam},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(encryptedPrivateKeyInfo{
+		Algo: pkix.AlgorithmIdentifier{
+			Algorithm:  oidPBES2,
+			Parameters: asn1.RawValue{FullBytes: params},
+		},
+		EncryptedData: data,
+	})
+}
+
+// checkScryptParams refuses the parameters that would make decrypting a key
+// use too much memory or time: they come from the key file, or from the
+// parameters file of the keystore.
+func checkScryptParams(kdf scryptParams) error {
+	n, r, p := kdf.CostParameter, kdf.BlockSize, kdf.ParallelizationParameter
+	if n <= 1 || r <= 0 || p <= 0 {
+		return fmt.Errorf("invalid scrypt parameters N=%d, r=%d, p=%d", n, r, p)
+	}
+	if n > maxScryptMemory/128/r {
+		return fmt.Errorf("scrypt parameters N=%d, r=%d need too much memory", n, r)
+	}
+	if p > maxScryptWork/(128*r*n) {
+		return fmt.Errorf("scrypt parameters N=%d, r=%d, p=%d are too costly", n, r, p)
+	}
+	return nil
+}
+
+func decryptPKCS8(der, passphrase []byte) ([]byte, error) {
+	var info encryptedPrivateKeyInfo
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		return nil, err
+	}
+	if !info.Algo.Algorithm.Equal(oidPBES2) {
+		return nil, fmt.Errorf("unsupported key encryption scheme %s", info.Algo.Algorithm)
+	}
+
+	var params pbes2Params
+	if _, err := asn1.Unmarshal(info.Algo.Parameters.FullBytes, &params); err != nil {
+		return nil, err
+	}
+	if !params.KeyDerivationFunc.Algorithm.Equal(oidScrypt) {
+		return nil, fmt.Errorf("unsupported key derivation function %s, only scrypt is supported", params.KeyDerivationFunc.Algorithm)
+	}
+	if !params.EncryptionScheme.Algorithm.Equal(oidAES256CBC) {
+		return nil, fmt.Errorf("unsupported cipher %s, only AES-256-CBC is supported", params.EncryptionScheme.Algorithm)
+	}
+
+	var kdf scryptParams
+	if _, err := asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdf); err != nil {
+		return nil, err
+	}
+	if err := checkScryptParams(kdf); err != nil {
+		return nil, err
+	}
+	if kdf.KeyLength != 0 && kdf.KeyLength != 32 {
+		return nil, fmt.Errorf("invalid key length %d for AES-256", kdf.KeyLength)
+	}
+	var iv []byte
+	if _, err := asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil {
+		return nil, err
+	}
+	if len(iv) != aes.BlockSize {
+		return nil, errors.New("invalid AES-256-CBC IV")
+	}
+
+	key, err := scrypt.Key(passphrase, kdf.Salt, kdf.CostParameter, kdf.BlockSize, kdf.ParallelizationParameter, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	data := info.EncryptedData
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, errors.New("invalid encrypted key length")
+	}
+	data = append([]byte(nil), data...)
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(data, data)
+
+	padding := int(data[len(data)-1])
+	if padding == 0 || padding > aes.BlockSize {
+		return nil, ErrBadPassphrase
+	}
+	for _, b := range data[len(data)-padding:] {
+		if int(b) != padding {
+			return nil, ErrBadPassphrase
+		}
+	}
+	return data[:len(data)-padding], nil
+}
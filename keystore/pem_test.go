@@ -0,0 +1,126 @@
+package keystore
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/asn1"
+	"encoding/pem"
+	"testing"
+
+	ci "github.com/libp2p/go-libp2p-core/crypto"
+)
+
+func TestPEMRoundTrip(t *testing.T) {
+	rsaKey, _, err := ci.GenerateKeyPairWithReader(ci.RSA, 1024, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecdsaKey, _, err := ci.GenerateKeyPairWithReader(ci.ECDSA, 256, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, k := range []ci.PrivKey{privKeyOrFatal(t), rsaKey, ecdsaKey} {
+		for _, passphrase := range []string{"", "secret"} {
+			data, err := MarshalPEM(k, []byte(passphrase))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			block, _ := pem.Decode(data)
+			if passphrase == "" && block.Type != pemPrivateKey {
+				t.Fatalf("unexpected block type %s", block.Type)
+			}
+			if passphrase != "" {
+				if block.Type != pemEncryptedPrivateKey {
+					t.Fatalf("unexpected block type %s", block.Type)
+				}
+				raw, _ := k.Raw()
+				if bytes.Contains(block.Bytes, raw[:16]) {
+					t.Fatal("encrypted key contains key material")
+				}
+				if _, err := UnmarshalPEM(data, nil); err != ErrPassphraseRequired {
+					t.Fatalf("expected ErrPassphraseRequired, got %v", err)
+				}
+				if _, err := UnmarshalPEM(data, []byte("wrong")); err != ErrBadPassphrase {
+					t.Fatalf("expected ErrBadPassphrase, got %v", err)
+				}
+			}
+
+			k2, err := UnmarshalPEM(data, []byte(passphrase))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !k.Equals(k2) {
+				t.Fatalf("%T doesn't round trip", k)
+			}
+		}
+	}
+}
+
+func TestPEMUnsupported(t *testing.T) {
+	k, _, err := ci.GenerateSecp256k1Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := MarshalPEM(k, nil); err == nil {
+		t.Fatal("expected secp256k1 keys not to be encodable")
+	}
+	if _, err := UnmarshalPEM([]byte("not a key"), nil); err == nil {
+		t.Fatal("expected an error decoding garbage")
+	}
+}
+
+func TestPEMScryptBounds(t *testing.T) {
+	for _, tc := range []struct {
+		n, r, p int
+		ok      bool
+	}{
+		{scryptN, scryptR, scryptP, true},
+		{1 << 20, 1, 1, true},
+		{1 << 20, 8, 1, false},
+		{scryptN, 1 << 20, 1, false},
+		{scryptN, scryptR, 1 << 20, false},
+		{scryptN, 0, 1, false},
+		{scryptN, scryptR, -1, false},
+	} {
+		err := checkScryptParams(scryptParams{CostParameter: tc.n, BlockSize: tc.r, ParallelizationParameter: tc.p})
+		if (err == nil) != tc.ok {
+			t.Errorf("N=%d, r=%d, p=%d: unexpected result %v", tc.n, tc.r, tc.p, err)
+		}
+	}
+
+	// A key encrypted with costly parameters is refused before running
+	// scrypt.
+	k := privKeyOrFatal(t)
+	data, err := MarshalPEM(k, []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(data)
+	var info encryptedPrivateKeyInfo
+	if _, err := asn1.Unmarshal(block.Bytes, &info); err != nil {
+		t.Fatal(err)
+	}
+	var params pbes2Params
+	if _, err := asn1.Unmarshal(info.Algo.Parameters.FullBytes, &params); err != nil {
+		t.Fatal(err)
+	}
+	var kdf scryptParams
+	if _, err := asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdf); err != nil {
+		t.Fatal(err)
+	}
+	kdf.BlockSize = 1 << 24
+	if params.KeyDerivationFunc.Parameters.FullBytes, err = asn1.Marshal(kdf); err != nil {
+		t.Fatal(err)
+	}
+	if info.Algo.Parameters.FullBytes, err = asn1.Marshal(params); err != nil {
+		t.Fatal(err)
+	}
+	if block.Bytes, err = asn1.Marshal(info); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := UnmarshalPEM(pem.EncodeToMemory(block), []byte("secret")); err == nil {
+		t.Fatal("expected a key with a huge scrypt block size to be refused")
+	}
+}
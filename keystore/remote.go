@@ -0,0 +1,281 @@
+package keystore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	ci "github.com/libp2p/go-libp2p-core/crypto"
+	pb "github.com/libp2p/go-libp2p-core/crypto/pb"
+)
+
+// ErrReadOnly is returned when adding or removing keys of a keystore whose
+// keys are managed elsewhere.
+var ErrReadOnly = errors.New("keystore is read-only, its keys are managed by the signer")
+
+// ErrNoKeyMaterial is returned when trying to read the private key material
+// of a key held by a remote signer.
+var ErrNoKeyMaterial = errors.New("private key is held by a remote signer")
+
+// SignerTimeout bounds every request made to a remote signer.
+var SignerTimeout = 10 * time.Second
+
+// Methods of the signer protocol.
+const (
+	SignerList   = "list"
+	SignerPubKey = "pubkey"
+	SignerSign   = "sign"
+)
+
+// SignerRequest is a request of the signer protocol. A client connects to the
+// signer, writes a single JSON encoded SignerRequest and reads a single JSON
+// encoded SignerResponse back.
+type SignerRequest struct {
+	Method string
+	Name   string `json:",omitempty"`
+	Data   []byte `json:",omitempty"`
+}
+
+// SignerResponse is a response of the signer protocol.
+type SignerResponse struct {
+	// Keys answers "list" requests.
+	Keys []string `json:",omitempty"`
+	// PublicKey is the marshalled public key answering "pubkey" requests.
+	PublicKey []byte `json:",omitempty"`
+	// Signature answers "sign" requests.
+	Signature []byte `json:",omitempty"`
+
+	// NotFound is set when the requested key doesn't exist.
+	NotFound bool   `json:",omitempty"`
+	Error    string `json:",omitempty"`
+}
+
+// RemoteKeystore is a read-only keystore whose keys are held by an external
+// signer, reached over a unix socket. The private keys it returns are proxies
+// that ask the signer for signatures, so the key material never reaches the
+// node.
+type RemoteKeystore struct {
+	socket string
+}
+
+var _ Keystore = (*RemoteKeystore)(nil)
+
+// NewRemoteKeystore returns a keystore backed by the signer listening on the
+// given unix socket.
+func NewRemoteKeystore(socket string) *RemoteKeystore {
+	return &RemoteKeystore{socket: socket}
+}
+
+func (ks *RemoteKeystore) call(req *SignerRequest) (*SignerResponse, error) {
+	conn, err := net.DialTimeout("unix", ks.socket, SignerTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to signer: %s", err)
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(SignerTimeout)); err != nil {
+		return nil, err
+	}
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, fmt.Errorf("sending request to signer: %s", err)
+	}
+
+	var resp SignerResponse
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("reading response from signer: %s", err)
+	}
+	if resp.NotFound {
+		return nil, ErrNoSuchKey
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf("signer: %s", resp.Error)
+	}
+	return &resp, nil
+}
+
+// Has returns whether or not a key exist in the Keystore
+func (ks *RemoteKeystore) Has(name string) (bool, error) {
+	if err := validateName(name); err != nil {
+		return false, err
+	}
+
+	_, err := ks.call(&SignerRequest{Method: SignerPubKey, Name: name})
+	switch err {
+	case nil:
+		return true, nil
+	case ErrNoSuchKey:
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+// Put always fails, keys are added to the signer directly.
+func (ks *RemoteKeystore) Put(name string, k ci.PrivKey) error {
+	return ErrReadOnly
+}
+
+// Get returns a proxy for a key of the signer, and ErrNoSuchKey if it doesn't
+// exist.
+func (ks *RemoteKeystore) Get(name string) (ci.PrivKey, error) {
+	if err := validateName(name); err != nil {
+		return nil, err
+	}
+
+	resp, err := ks.call(&SignerRequest{Method: SignerPubKey, Name: name})
+	if err != nil {
+		return nil, err
+	}
+	pub, err := ci.UnmarshalPublicKey(resp.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key from signer: %s", err)
+	}
+
+	return &remoteKey{ks: ks, name: name, pub: pub}, nil
+}
+
+// Delete always fails, keys are removed from the signer directly.
+func (ks *RemoteKeystore) Delete(name string) error {
+	return ErrReadOnly
+}
+
+// List return a list of key identifier
+func (ks *RemoteKeystore) List() ([]string, error) {
+	resp, err := ks.call(&SignerRequest{Method: SignerList})
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]string, 0, len(resp.Keys))
+	for _, name := range resp.Keys {
+		if err := validateName(name); err == nil {
+			list = append(list, name)
+		} else {
+			log.Warningf("Ignoring the invalid key name from signer: %s", name)
+		}
+	}
+	return list, nil
+}
+
+// remoteKey is a private key held by a signer.
+type remoteKey struct {
+	ks   *RemoteKeystore
+	name string
+	pub  ci.PubKey
+}
+
+var _ ci.PrivKey = (*remoteKey)(nil)
+
+func (k *remoteKey) Sign(data []byte) ([]byte, error) {
+	resp, err := k.ks.call(&SignerRequest{Method: SignerSign, Name: k.name, Data: data})
+	if err != nil {
+		return nil, err
+	}
+
+	// Don't hand out signatures that won't verify, e.g. because the key
+	// was replaced on the signer.
+	ok, err := k.pub.Verify(data, resp.Signature)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("signer returned an invalid signature for key %s", k.name)
+	}
+	return resp.Signature, nil
+}
+
+func (k *remoteKey) GetPublic() ci.PubKey {
+	return k.pub
+}
+
+func (k *remoteKey) Bytes() ([]byte, error) {
+	return nil, ErrNoKeyMaterial
+}
+
+func (k *remoteKey) Raw() ([]byte, error) {
+	return nil, ErrNoKeyMaterial
+}
+
+func (k *remoteKey) Type() pb.KeyType {
+	return k.pub.Type()
+}
+
+func (k *remoteKey) Equals(o ci.Key) bool {
+	other, ok := o.(ci.PrivKey)
+	if !ok {
+		return false
+	}
+	return k.pub.Equals(other.GetPublic())
+}
+
+// ServeSigner answers signer protocol requests on l with the keys of ks,
+// until l is closed.
+func ServeSigner(l net.Listener, ks Keystore) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go serveSignerConn(conn, ks)
+	}
+}
+
+func serveSignerConn(conn net.Conn, ks Keystore) {
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(SignerTimeout)); err != nil {
+		return
+	}
+
+	var req SignerRequest
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		log.Debugf("signer: invalid request: %s", err)
+		return
+	}
+
+	resp, err := handleSignerRequest(&req, ks)
+	switch err {
+	case nil:
+	case ErrNoSuchKey:
+		resp = &SignerResponse{NotFound: true}
+	default:
+		resp = &SignerResponse{Error: err.Error()}
+	}
+
+	if err := json.NewEncoder(conn).Encode(resp); err != nil {
+		log.Debugf("signer: writing response: %s", err)
+	}
+}
+
+func handleSignerRequest(req *SignerRequest, ks Keystore) (*SignerResponse, error) {
+	if req.Method == SignerList {
+		keys, err := ks.List()
+		if err != nil {
+			return nil, err
+		}
+		return &SignerResponse{Keys: keys}, nil
+	}
+
+	sk, err := ks.Get(req.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	switch req.Method {
+	case SignerPubKey:
+		pub, err := ci.MarshalPublicKey(sk.GetPublic())
+		if err != nil {
+			return nil, err
+		}
+		return &SignerResponse{PublicKey: pub}, nil
+	case SignerSign:
+		sig, err := sk.Sign(req.Data)
+		if err != nil {
+			return nil, err
+		}
+		return &SignerResponse{Signature: sig}, nil
+	default:
+		return nil, fmt.Errorf("unknown method %q", req.Method)
+	}
+}
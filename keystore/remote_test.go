@@ -0,0 +1,77 @@
+package keystore
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRemoteKeystore(t *testing.T) {
+	tdir, err := ioutil.TempDir("", "keystore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tdir)
+
+	socket := filepath.Join(tdir, "signer.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	signerKs := NewMemKeystore()
+	sk := privKeyOrFatal(t)
+	if err := signerKs.Put("foo", sk); err != nil {
+		t.Fatal(err)
+	}
+	go ServeSigner(l, signerKs)
+
+	ks := NewRemoteKeystore(socket)
+
+	list, err := ks.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0] != "foo" {
+		t.Fatalf("unexpected key list %v", list)
+	}
+	if has, err := ks.Has("foo"); err != nil || !has {
+		t.Fatalf("expected foo to exist: %v", err)
+	}
+	if has, err := ks.Has("bar"); err != nil || has {
+		t.Fatalf("expected bar not to exist: %v", err)
+	}
+	if _, err := ks.Get("bar"); err != ErrNoSuchKey {
+		t.Fatalf("expected ErrNoSuchKey, got %v", err)
+	}
+
+	k, err := ks.Get("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !k.Equals(sk) || !k.GetPublic().Equals(sk.GetPublic()) {
+		t.Fatal("remote key doesn't match")
+	}
+	if _, err := k.Bytes(); err != ErrNoKeyMaterial {
+		t.Fatalf("expected ErrNoKeyMaterial, got %v", err)
+	}
+
+	data := []byte("hello world")
+	sig, err := k.Sign(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := sk.GetPublic().Verify(data, sig); err != nil || !ok {
+		t.Fatal("invalid signature")
+	}
+
+	if err := ks.Put("bar", sk); err != ErrReadOnly {
+		t.Fatalf("expected ErrReadOnly, got %v", err)
+	}
+	if err := ks.Delete("foo"); err != ErrReadOnly {
+		t.Fatalf("expected ErrReadOnly, got %v", err)
+	}
+}
@@ -1,11 +1,53 @@
 package namesys
 
 import (
+	"encoding/json"
+	"strings"
 	"time"
 
+	ds "github.com/ipfs/go-datastore"
+	dsquery "github.com/ipfs/go-datastore/query"
 	path "github.com/ipfs/go-path"
+	base32 "github.com/whyrusleeping/base32"
 )
 
+const cachePrefix = "/namesys-cache/"
+
+// CacheEntry is a resolved name held in the cache of a NameSystem.
+type CacheEntry struct {
+	Name  string
+	Value path.Path
+	EOL   time.Time
+}
+
+// Cache is implemented by name systems that cache resolved names, and allows
+// inspecting and evicting the cached entries.
+type Cache interface {
+	// CacheEntries returns the entries that haven't expired yet.
+	CacheEntries() []CacheEntry
+
+	// CacheRemove evicts the entry for the given name and reports whether
+	// there was one.
+	CacheRemove(name string) bool
+
+	// CachePurge evicts all entries.
+	CachePurge()
+}
+
+var _ Cache = (*mpns)(nil)
+
+// WithPersistentCache makes the NameSystem persist its cache entries in the
+// given datastore, and warm its cache from there when created.
+func WithPersistentCache(dstore ds.Datastore) Option {
+	return func(ns *mpns) {
+		ns.cacheDs = dstore
+	}
+}
+
+func cacheDsKey(name string) ds.Key {
+	return ds.NewKey(cachePrefix + base32.RawStdEncoding.EncodeToString([]byte(name)))
+}
+
 func (ns *mpns) cacheGet(name string) (path.Path, bool) {
 	if ns.cache == nil {
 		return "", false
@@ -35,13 +77,132 @@ func (ns *mpns) cacheSet(name string, val path.Path, ttl time.Duration) {
 	if ns.cache == nil || ttl <= 0 {
 		return
 	}
-	ns.cache.Add(name, cacheEntry{
+	entry := cacheEntry{
 		val: val,
 		eol: time.Now().Add(ttl),
-	})
+	}
+	ns.cache.Add(name, entry)
+
+	if ns.cacheDs != nil {
+		data, err := json.Marshal(persistedCacheEntry{Value: entry.val, EOL: entry.eol})
+		if err != nil {
+			log.Errorf("failed to encode cache entry for %s: %s", name, err)
+			return
+		}
+		if err := ns.cacheDs.Put(cacheDsKey(name), data); err != nil {
+			log.Errorf("failed to persist cache entry for %s: %s", name, err)
+		}
+	}
+}
+
+// cacheEvicted is called by the cache whenever an entry is removed from it,
+// whether explicitly, because it expired or because the cache is full.
+func (ns *mpns) cacheEvicted(key interface{}, _ interface{}) {
+	if ns.cacheDs == nil {
+		return
+	}
+	name, ok := key.(string)
+	if !ok {
+		return
+	}
+	if err := ns.cacheDs.Delete(cacheDsKey(name)); err != nil && err != ds.ErrNotFound {
+		log.Errorf("failed to remove persisted cache entry for %s: %s", name, err)
+	}
+}
+
+// loadCache warms the cache with the persisted entries, dropping the ones
+// that expired in the meantime.
+func (ns *mpns) loadCache() error {
+	if ns.cacheDs == nil {
+		return nil
+	}
+
+	results, err := ns.cacheDs.Query(dsquery.Query{Prefix: cachePrefix})
+	if err != nil {
+		return err
+	}
+	defer results.Close()
+
+	now := time.Now()
+	var expired []string
+	for result := range results.Next() {
+		if result.Error != nil {
+			return result.Error
+		}
+		if !strings.HasPrefix(result.Key, cachePrefix) {
+			continue
+		}
+		name, err := base32.RawStdEncoding.DecodeString(result.Key[len(cachePrefix):])
+		if err != nil {
+			log.Errorf("namesys cache ds key invalid: %s", result.Key)
+			continue
+		}
+
+		var pe persistedCacheEntry
+		if err := json.Unmarshal(result.Value, &pe); err != nil || !now.Before(pe.EOL) {
+			expired = append(expired, result.Key)
+			continue
+		}
+		ns.cache.Add(string(name), cacheEntry{val: pe.Value, eol: pe.EOL})
+	}
+
+	for _, k := range expired {
+		if err := ns.cacheDs.Delete(ds.NewKey(k)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CacheEntries implements Cache.
+func (ns *mpns) CacheEntries() []CacheEntry {
+	if ns.cache == nil {
+		return nil
+	}
+
+	now := time.Now()
+	var entries []CacheEntry
+	for _, key := range ns.cache.Keys() {
+		ientry, ok := ns.cache.Peek(key)
+		if !ok {
+			continue
+		}
+		entry := ientry.(cacheEntry)
+		if !now.Before(entry.eol) {
+			continue
+		}
+		entries = append(entries, CacheEntry{
+			Name:  key.(string),
+			Value: entry.val,
+			EOL:   entry.eol,
+		})
+	}
+	return entries
+}
+
+// CacheRemove implements Cache.
+func (ns *mpns) CacheRemove(name string) bool {
+	if ns.cache == nil || !ns.cache.Contains(name) {
+		return false
+	}
+	ns.cache.Remove(name)
+	return true
+}
+
+// CachePurge implements Cache.
+func (ns *mpns) CachePurge() {
+	if ns.cache == nil {
+		return
+	}
+	ns.cache.Purge()
 }
 
 type cacheEntry struct {
 	val path.Path
 	eol time.Time
 }
+
+type persistedCacheEntry struct {
+	Value path.Path
+	EOL   time.Time
+}
@@ -0,0 +1,56 @@
+package namesys
+
+import (
+	"testing"
+	"time"
+
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	offroute "github.com/ipfs/go-ipfs-routing/offline"
+	path "github.com/ipfs/go-path"
+	record "github.com/libp2p/go-libp2p-record"
+)
+
+func TestPersistentCache(t *testing.T) {
+	dst := dssync.MutexWrap(ds.NewMapDatastore())
+	routing := offroute.NewOfflineRouter(dst, record.NamespacedValidator{})
+	p := path.FromString("/ipfs/QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn")
+
+	ns := NewNameSystem(routing, dst, 16, WithPersistentCache(dst)).(*mpns)
+	ns.cacheSet("a.example.org", p, time.Hour)
+	ns.cacheSet("b.example.org", p, time.Hour)
+	ns.cacheSet("c.example.org", p, time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+
+	// A new name system warms up from the datastore, minus expired entries.
+	ns = NewNameSystem(routing, dst, 16, WithPersistentCache(dst)).(*mpns)
+	entries := ns.CacheEntries()
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 cached entries, got %d", len(entries))
+	}
+	if v, ok := ns.cacheGet("a.example.org"); !ok || v != p {
+		t.Fatal("expected a.example.org to be cached")
+	}
+	if has, _ := dst.Has(cacheDsKey("c.example.org")); has {
+		t.Fatal("expired entry still persisted")
+	}
+
+	if !ns.CacheRemove("a.example.org") || ns.CacheRemove("a.example.org") {
+		t.Fatal("unexpected result removing a cached entry")
+	}
+	if has, _ := dst.Has(cacheDsKey("a.example.org")); has {
+		t.Fatal("removed entry still persisted")
+	}
+
+	ns.CachePurge()
+	if has, _ := dst.Has(cacheDsKey("b.example.org")); has {
+		t.Fatal("purged entry still persisted")
+	}
+
+	// Without persistence, nothing is written to the datastore.
+	ns = NewNameSystem(routing, dst, 16).(*mpns)
+	ns.cacheSet("a.example.org", p, time.Hour)
+	if has, _ := dst.Has(cacheDsKey("a.example.org")); has {
+		t.Fatal("entry persisted without persistence enabled")
+	}
+}
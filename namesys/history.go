@@ -0,0 +1,105 @@
+package namesys
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	ds "github.com/ipfs/go-datastore"
+	pb "github.com/ipfs/go-ipns/pb"
+	path "github.com/ipfs/go-path"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	base32 "github.com/whyrusleeping/base32"
+)
+
+// ErrNoHistoryEntry is returned when a sequence number can't be found in
+// the publish history of a key.
+var ErrNoHistoryEntry = errors.New("no such entry in the publish history")
+
+// PublishHistoryLength is the number of published values remembered for
+// each key.
+var PublishHistoryLength = 32
+
+// historyLk serializes the updates of the publish histories, which are read,
+// modified and written back.
+var historyLk sync.Mutex
+
+// HistoryEntry is a value that was published under a key.
+type HistoryEntry struct {
+	Sequence uint64
+	Value    path.Path
+	Time     time.Time
+}
+
+// IpnsHistoryDsKey returns the datastore key under which the publish history
+// of the given ID is kept.
+func IpnsHistoryDsKey(id peer.ID) ds.Key {
+	return ds.NewKey("/ipns-history/" + base32.RawStdEncoding.EncodeToString([]byte(id)))
+}
+
+// PublishHistory returns the values this node published for the given ID,
+// oldest first.
+func PublishHistory(dstore ds.Datastore, id peer.ID) ([]HistoryEntry, error) {
+	data, err := dstore.Get(IpnsHistoryDsKey(id))
+	switch err {
+	case nil:
+	case ds.ErrNotFound:
+		return nil, nil
+	default:
+		return nil, err
+	}
+
+	var history []HistoryEntry
+	if err := json.Unmarshal(data, &history); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// FindHistoryEntry returns the entry with the given sequence number from the
+// publish history of the given ID.
+func FindHistoryEntry(dstore ds.Datastore, id peer.ID, seq uint64) (HistoryEntry, error) {
+	history, err := PublishHistory(dstore, id)
+	if err != nil {
+		return HistoryEntry{}, err
+	}
+	for _, h := range history {
+		if h.Sequence == seq {
+			return h, nil
+		}
+	}
+	return HistoryEntry{}, ErrNoHistoryEntry
+}
+
+// appendHistory records a newly published entry, dropping the oldest ones
+// once the history is full. Republishing an entry with an already recorded
+// sequence number is a no-op.
+func appendHistory(dstore ds.Datastore, id peer.ID, entry *pb.IpnsEntry) error {
+	historyLk.Lock()
+	defer historyLk.Unlock()
+
+	history, err := PublishHistory(dstore, id)
+	if err != nil {
+		return err
+	}
+
+	if n := len(history); n > 0 && history[n-1].Sequence == entry.GetSequence() {
+		return nil
+	}
+
+	history = append(history, HistoryEntry{
+		Sequence: entry.GetSequence(),
+		Value:    path.Path(entry.GetValue()),
+		Time:     time.Now(),
+	})
+	if len(history) > PublishHistoryLength {
+		history = history[len(history)-PublishHistoryLength:]
+	}
+
+	data, err := json.Marshal(history)
+	if err != nil {
+		return err
+	}
+	return dstore.Put(IpnsHistoryDsKey(id), data)
+}
@@ -0,0 +1,140 @@
+package namesys
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	offroute "github.com/ipfs/go-ipfs-routing/offline"
+	ipns "github.com/ipfs/go-ipns"
+	path "github.com/ipfs/go-path"
+	ci "github.com/libp2p/go-libp2p-core/crypto"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	pstoremem "github.com/libp2p/go-libp2p-peerstore/pstoremem"
+	record "github.com/libp2p/go-libp2p-record"
+)
+
+func TestPublishHistory(t *testing.T) {
+	ctx := context.Background()
+	dst := dssync.MutexWrap(ds.NewMapDatastore())
+	priv, _, err := ci.GenerateKeyPair(ci.Ed25519, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid, err := peer.IDFromPrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ps := pstoremem.NewPeerstore()
+	if err := ps.AddPrivKey(pid, priv); err != nil {
+		t.Fatal(err)
+	}
+
+	routing := offroute.NewOfflineRouter(dst, record.NamespacedValidator{
+		"ipns": ipns.Validator{KeyBook: ps},
+		"pk":   record.PublicKeyValidator{},
+	})
+	pub := NewIpnsPublisher(routing, dst)
+
+	oldLen := PublishHistoryLength
+	PublishHistoryLength = 3
+	defer func() { PublishHistoryLength = oldLen }()
+
+	values := make([]path.Path, 5)
+	for i := range values {
+		values[i] = path.FromString(fmt.Sprintf("/ipns/value-%d", i))
+		if err := pub.Publish(ctx, priv, values[i]); err != nil {
+			t.Fatal(err)
+		}
+		// Republishing the same value must not grow the history.
+		if err := pub.Publish(ctx, priv, values[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	history, err := PublishHistory(dst, pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 3 {
+		t.Fatalf("expected 3 history entries, got %d", len(history))
+	}
+	for i, h := range history {
+		if h.Sequence != uint64(i+2) || h.Value != values[i+2] {
+			t.Errorf("unexpected history entry %d: %d %s", i, h.Sequence, h.Value)
+		}
+	}
+
+	if _, err := FindHistoryEntry(dst, pid, 0); err != ErrNoHistoryEntry {
+		t.Fatalf("expected ErrNoHistoryEntry, got %v", err)
+	}
+
+	// Rolling back publishes the old value with a new sequence number.
+	eol := time.Now().Add(time.Hour)
+	v, err := pub.Rollback(ctx, priv, 2, eol)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != values[2] {
+		t.Fatalf("rolled back to %s, expected %s", v, values[2])
+	}
+	rec, err := pub.GetPublished(ctx, pid, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.GetSequence() != 5 || path.Path(rec.GetValue()) != values[2] {
+		t.Fatalf("unexpected record after rollback: %d %s", rec.GetSequence(), rec.GetValue())
+	}
+
+	// Rolling back to the current value still gets a new sequence number.
+	if _, err := pub.Rollback(ctx, priv, 5, eol); err != nil {
+		t.Fatal(err)
+	}
+	rec, err = pub.GetPublished(ctx, pid, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.GetSequence() != 6 || path.Path(rec.GetValue()) != values[2] {
+		t.Fatalf("unexpected record after rollback to the current value: %d %s", rec.GetSequence(), rec.GetValue())
+	}
+}
+
+func TestPublishHistoryConcurrent(t *testing.T) {
+	dst := dssync.MutexWrap(ds.NewMapDatastore())
+	priv, _, err := ci.GenerateKeyPair(ci.Ed25519, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid, err := peer.IDFromPrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 20
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			entry, err := ipns.Create(priv, []byte(fmt.Sprintf("/ipns/value-%d", i)), uint64(i), time.Now().Add(time.Hour))
+			if err == nil {
+				err = appendHistory(dst, pid, entry)
+			}
+			errs <- err
+		}(i)
+	}
+	for i := 0; i < n; i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	history, err := PublishHistory(dst, pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != n {
+		t.Fatalf("expected %d history entries, got %d", n, len(history))
+	}
+}
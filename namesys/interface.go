@@ -103,4 +103,9 @@ type Publisher interface {
 	// TODO: to be replaced by a more generic 'PublishWithValidity' type
 	// call once the records spec is implemented
 	PublishWithEOL(ctx context.Context, name ci.PrivKey, value path.Path, eol time.Time) error
+
+	// Rollback publishes the value of the record of name with sequence
+	// number seq again, from the publish history, with a new sequence
+	// number. It returns the value published.
+	Rollback(ctx context.Context, name ci.PrivKey, seq uint64, eol time.Time) (path.Path, error)
 }
@@ -22,6 +22,7 @@ import (
 // (a) IPFS routing naming: SFS-like PKI names.
 // (b) dns domains: resolves using links in DNS TXT records
 // (c) proquints: interprets string as the raw byte data.
+// (d) custom resolvers claiming names by pattern, see RegisterResolver.
 //
 // It can only publish to: (a) IPFS routing naming.
 //
@@ -29,23 +30,36 @@ type mpns struct {
 	dnsResolver, proquintResolver, ipnsResolver resolver
 	ipnsPublisher                               Publisher
 
+	// resolvers claiming names by pattern, checked in order before the
+	// built-in ones.
+	resolvers []patternResolver
+
 	cache *lru.Cache
+	// cacheDs persists the cache entries when set.
+	cacheDs ds.Datastore
 }
 
 // NewNameSystem will construct the IPFS naming system based on Routing
-func NewNameSystem(r routing.ValueStore, ds ds.Datastore, cachesize int) NameSystem {
-	var cache *lru.Cache
-	if cachesize > 0 {
-		cache, _ = lru.New(cachesize)
-	}
-
-	return &mpns{
+func NewNameSystem(r routing.ValueStore, ds ds.Datastore, cachesize int, options ...Option) NameSystem {
+	ns := &mpns{
 		dnsResolver:      NewDNSResolver(),
 		proquintResolver: new(ProquintResolver),
 		ipnsResolver:     NewIpnsResolver(r),
 		ipnsPublisher:    NewIpnsPublisher(r, ds),
-		cache:            cache,
 	}
+	for _, o := range options {
+		o(ns)
+	}
+	ns.resolvers = append(ns.resolvers, registeredResolvers()...)
+
+	if cachesize > 0 {
+		ns.cache, _ = lru.NewWithEvict(cachesize, ns.cacheEvicted)
+		if err := ns.loadCache(); err != nil {
+			log.Errorf("failed to load the persisted name cache: %s", err)
+		}
+	}
+
+	return ns
 }
 
 const DefaultResolverCacheTTL = time.Minute
@@ -112,12 +126,15 @@ func (ns *mpns) resolveOnceAsync(ctx context.Context, name string, options opts.
 	}
 
 	// Resolver selection:
-	// 1. if it is a multihash resolve through "ipns".
-	// 2. if it is a domain name, resolve through "dns"
-	// 3. otherwise resolve through the "proquint" resolver
+	// 1. if a custom resolver claims the name, resolve through it.
+	// 2. if it is a multihash resolve through "ipns".
+	// 3. if it is a domain name, resolve through "dns"
+	// 4. otherwise resolve through the "proquint" resolver
 
 	var res resolver
-	if _, err := mh.FromB58String(key); err == nil {
+	if r, ok := ns.customResolver(key); ok {
+		res = r
+	} else if _, err := mh.FromB58String(key); err == nil {
 		res = ns.ipnsResolver
 	} else if isd.IsDomain(key) {
 		res = ns.dnsResolver
@@ -189,3 +206,20 @@ func (ns *mpns) PublishWithEOL(ctx context.Context, name ci.PrivKey, value path.
 	ns.cacheSet(peer.IDB58Encode(id), value, ttl)
 	return nil
 }
+
+func (ns *mpns) Rollback(ctx context.Context, name ci.PrivKey, seq uint64, eol time.Time) (path.Path, error) {
+	id, err := peer.IDFromPrivateKey(name)
+	if err != nil {
+		return "", err
+	}
+	value, err := ns.ipnsPublisher.Rollback(ctx, name, seq, eol)
+	if err != nil {
+		return "", err
+	}
+	ttl := DefaultResolverCacheTTL
+	if ttEol := time.Until(eol); ttEol < ttl {
+		ttl = ttEol
+	}
+	ns.cacheSet(peer.IDB58Encode(id), value, ttl)
+	return value, nil
+}
@@ -139,7 +139,7 @@ func (p *IpnsPublisher) GetPublished(ctx context.Context, id peer.ID, checkRouti
 	return e, nil
 }
 
-func (p *IpnsPublisher) updateRecord(ctx context.Context, k ci.PrivKey, value path.Path, eol time.Time) (*pb.IpnsEntry, error) {
+func (p *IpnsPublisher) updateRecord(ctx context.Context, k ci.PrivKey, value path.Path, eol time.Time, newSeq bool) (*pb.IpnsEntry, error) {
 	id, err := peer.IDFromPrivateKey(k)
 	if err != nil {
 		return nil, err
@@ -155,9 +155,9 @@ func (p *IpnsPublisher) updateRecord(ctx context.Context, k ci.PrivKey, value pa
 	}
 
 	seqno := rec.GetSequence() // returns 0 if rec is nil
-	if rec != nil && value != path.Path(rec.GetValue()) {
+	if rec != nil && (value != path.Path(rec.GetValue()) || newSeq) {
 		// Don't bother incrementing the sequence number unless the
-		// value changes.
+		// value changes, or a new sequence number was asked for.
 		seqno++
 	}
 
@@ -183,13 +183,18 @@ func (p *IpnsPublisher) updateRecord(ctx context.Context, k ci.PrivKey, value pa
 	if err := p.ds.Put(IpnsDsKey(id), data); err != nil {
 		return nil, err
 	}
+
+	// Remember the value so that it can be inspected and rolled back to.
+	if err := appendHistory(p.ds, id, entry); err != nil {
+		log.Errorf("failed to record publish history for %s: %s", id, err)
+	}
 	return entry, nil
 }
 
 // PublishWithEOL is a temporary stand in for the ipns records implementation
 // see here for more details: https://github.com/ipfs/specs/tree/master/records
 func (p *IpnsPublisher) PublishWithEOL(ctx context.Context, k ci.PrivKey, value path.Path, eol time.Time) error {
-	record, err := p.updateRecord(ctx, k, value, eol)
+	record, err := p.updateRecord(ctx, k, value, eol, false)
 	if err != nil {
 		return err
 	}
@@ -197,6 +202,26 @@ func (p *IpnsPublisher) PublishWithEOL(ctx context.Context, k ci.PrivKey, value
 	return PutRecordToRouting(ctx, p.routing, k.GetPublic(), record)
 }
 
+// Rollback publishes the value of the record of k with sequence number seq
+// again. The record gets a new sequence number even if the value is the
+// current one, so that it replaces any other record.
+func (p *IpnsPublisher) Rollback(ctx context.Context, k ci.PrivKey, seq uint64, eol time.Time) (path.Path, error) {
+	id, err := peer.IDFromPrivateKey(k)
+	if err != nil {
+		return "", err
+	}
+	h, err := FindHistoryEntry(p.ds, id, seq)
+	if err != nil {
+		return "", err
+	}
+
+	record, err := p.updateRecord(ctx, k, h.Value, eol, true)
+	if err != nil {
+		return "", err
+	}
+	return h.Value, PutRecordToRouting(ctx, p.routing, k.GetPublic(), record)
+}
+
 // setting the TTL on published records is an experimental feature.
 // as such, i'm using the context to wire it through to avoid changing too
 // much code along the way.
@@ -0,0 +1,76 @@
+package namesys
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	proto "github.com/gogo/protobuf/proto"
+	ipns "github.com/ipfs/go-ipns"
+	pb "github.com/ipfs/go-ipns/pb"
+	ci "github.com/libp2p/go-libp2p-core/crypto"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	routing "github.com/libp2p/go-libp2p-core/routing"
+)
+
+// ErrNoPublicKey is returned when the public key needed to verify a record
+// is neither embedded in the record, inlined in the name, nor available from
+// the routing system.
+var ErrNoPublicKey = errors.New("public key for IPNS record not found")
+
+// ParseIpnsName parses an IPNS name of the form `/ipns/<peer-id>` or
+// `<peer-id>` into the peer ID of the key that signs its records.
+func ParseIpnsName(name string) (peer.ID, error) {
+	return peer.IDB58Decode(strings.TrimPrefix(name, ipnsPrefix))
+}
+
+// UnmarshalRecord decodes a serialized IPNS record.
+func UnmarshalRecord(data []byte) (*pb.IpnsEntry, error) {
+	entry := new(pb.IpnsEntry)
+	if err := proto.Unmarshal(data, entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// RecordPublicKey returns the public key that should have signed the given
+// record for the given ID. The key is taken from the record or the ID when
+// possible and otherwise looked up through the routing system, if r is
+// non-nil.
+func RecordPublicKey(ctx context.Context, r routing.ValueStore, id peer.ID, entry *pb.IpnsEntry) (ci.PubKey, error) {
+	pk, err := ipns.ExtractPublicKey(id, entry)
+	switch err {
+	case nil:
+		if pk != nil {
+			return pk, nil
+		}
+	case peer.ErrNoPublicKey:
+	default:
+		return nil, err
+	}
+
+	if r == nil {
+		return nil, ErrNoPublicKey
+	}
+
+	pk, err = routing.GetPublicKey(r, ctx, id)
+	if err != nil {
+		log.Debugf("could not retrieve public key for %s: %s", id, err)
+		return nil, ErrNoPublicKey
+	}
+	return pk, nil
+}
+
+// ValidateRecord checks that the given record was signed by the key behind
+// id and has not expired. It returns the public key the record was verified
+// with.
+func ValidateRecord(ctx context.Context, r routing.ValueStore, id peer.ID, entry *pb.IpnsEntry) (ci.PubKey, error) {
+	pk, err := RecordPublicKey(ctx, r, id, entry)
+	if err != nil {
+		return nil, err
+	}
+	if err := ipns.Validate(pk, entry); err != nil {
+		return pk, err
+	}
+	return pk, nil
+}
@@ -0,0 +1,123 @@
+package namesys
+
+import (
+	"context"
+	"fmt"
+	gopath "path"
+	"sync"
+	"time"
+
+	path "github.com/ipfs/go-path"
+	opts "github.com/ipfs/interface-go-ipfs-core/options/namesys"
+)
+
+// NameResolver resolves the names matching the pattern it was registered
+// with, in addition to the built-in IPNS, DNS and proquint resolvers.
+type NameResolver interface {
+	// ResolveOnce resolves a name, without the /ipns/ prefix, one step. It
+	// returns the resulting path and how long the result may be cached for.
+	// The path may itself be an /ipns/ path, which will be resolved further.
+	ResolveOnce(ctx context.Context, name string) (value path.Path, ttl time.Duration, err error)
+}
+
+// patternResolver is a NameResolver along with the names it claims.
+type patternResolver struct {
+	pattern string
+	r       NameResolver
+}
+
+func (pr patternResolver) matches(name string) bool {
+	ok, _ := gopath.Match(pr.pattern, name)
+	return ok
+}
+
+// resolveOnceAsync implements resolver.
+func (pr patternResolver) resolveOnceAsync(ctx context.Context, name string, options opts.ResolveOpts) <-chan onceResult {
+	out := make(chan onceResult, 1)
+	go func() {
+		defer close(out)
+		p, ttl, err := pr.r.ResolveOnce(ctx, name)
+		emitOnceResult(ctx, out, onceResult{value: p, ttl: ttl, err: err})
+	}()
+	return out
+}
+
+var (
+	registryLk sync.Mutex
+	registry   []patternResolver
+)
+
+// ValidatePattern checks that the given name pattern is well formed. Name
+// patterns use the syntax of path.Match, e.g. "*.eth".
+func ValidatePattern(pattern string) error {
+	if _, err := gopath.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid name pattern %q: %s", pattern, err)
+	}
+	return nil
+}
+
+// RegisterResolver registers a resolver for all names matching the given
+// pattern. The resolver is used by every NameSystem created afterwards.
+func RegisterResolver(pattern string, r NameResolver) error {
+	if err := ValidatePattern(pattern); err != nil {
+		return err
+	}
+
+	registryLk.Lock()
+	defer registryLk.Unlock()
+
+	if err := checkRegistered(pattern); err != nil {
+		return err
+	}
+	registry = append(registry, patternResolver{pattern: pattern, r: r})
+	return nil
+}
+
+// CheckResolver returns the error RegisterResolver would fail with for
+// pattern, without registering anything.
+func CheckResolver(pattern string) error {
+	if err := ValidatePattern(pattern); err != nil {
+		return err
+	}
+
+	registryLk.Lock()
+	defer registryLk.Unlock()
+	return checkRegistered(pattern)
+}
+
+func checkRegistered(pattern string) error {
+	for _, pr := range registry {
+		if pr.pattern == pattern {
+			return fmt.Errorf("already have a resolver for names matching %q", pattern)
+		}
+	}
+	return nil
+}
+
+func registeredResolvers() []patternResolver {
+	registryLk.Lock()
+	defer registryLk.Unlock()
+	return append([]patternResolver(nil), registry...)
+}
+
+// Option configures a NameSystem.
+type Option func(*mpns)
+
+// WithResolver makes the NameSystem resolve names matching the given pattern
+// with r. Resolvers added this way take precedence over the ones registered
+// with RegisterResolver. Malformed patterns never match; see ValidatePattern.
+func WithResolver(pattern string, r NameResolver) Option {
+	return func(ns *mpns) {
+		ns.resolvers = append(ns.resolvers, patternResolver{pattern: pattern, r: r})
+	}
+}
+
+// customResolver returns the first resolver claiming the given name, if any.
+func (ns *mpns) customResolver(name string) (resolver, bool) {
+	for _, pr := range ns.resolvers {
+		if pr.matches(name) {
+			return pr, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,65 @@
+package namesys
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	offroute "github.com/ipfs/go-ipfs-routing/offline"
+	path "github.com/ipfs/go-path"
+	opts "github.com/ipfs/interface-go-ipfs-core/options/namesys"
+	record "github.com/libp2p/go-libp2p-record"
+)
+
+func TestCustomResolvers(t *testing.T) {
+	dst := dssync.MutexWrap(ds.NewMapDatastore())
+	routing := offroute.NewOfflineRouter(dst, record.NamespacedValidator{})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != WellKnownPath || r.Host != "docs.example.org" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Cache-Control", "max-age=10")
+		fmt.Fprintln(w, "dnslink=/ipns/intranet.static")
+	}))
+	defer srv.Close()
+
+	// Send every request to the test server.
+	wellKnown := NewWellKnownResolver(&http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, network, srv.Listener.Addr().String())
+			},
+		},
+	})
+	wellKnown.scheme = "http"
+
+	static := NewStaticResolver(map[string]path.Path{
+		"intranet.static": path.FromString("/ipfs/QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn"),
+	})
+
+	ns := NewNameSystem(routing, dst, 16,
+		WithResolver("intranet.static", static),
+		WithResolver("*.example.org", wellKnown),
+	)
+
+	testResolution(t, ns, "/ipns/intranet.static", opts.DefaultDepthLimit, "/ipfs/QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn", nil)
+	testResolution(t, ns, "/ipns/docs.example.org/a", opts.DefaultDepthLimit, "/ipfs/QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn/a", nil)
+	testResolution(t, ns, "/ipns/other.example.org", opts.DefaultDepthLimit, "", ErrResolveFailed)
+
+	// The result must have been cached, honoring the Cache-Control header.
+	p, ok := ns.(*mpns).cacheGet("docs.example.org")
+	if !ok || p != "/ipns/intranet.static" {
+		t.Fatalf("expected cached entry, got %q", p)
+	}
+	if ttl := cacheControlTTL("public, max-age=10"); ttl != 10*time.Second {
+		t.Fatalf("unexpected ttl %s", ttl)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	path "github.com/ipfs/go-path"
 
 	proto "github.com/gogo/protobuf/proto"
+	multierror "github.com/hashicorp/go-multierror"
 	ds "github.com/ipfs/go-datastore"
 	pb "github.com/ipfs/go-ipns/pb"
 	logging "github.com/ipfs/go-log"
@@ -17,6 +18,7 @@ import (
 	gpctx "github.com/jbenet/goprocess/context"
 	ic "github.com/libp2p/go-libp2p-core/crypto"
 	peer "github.com/libp2p/go-libp2p-core/peer"
+	routing "github.com/libp2p/go-libp2p-core/routing"
 )
 
 var errNoEntry = errors.New("no previous entry")
@@ -37,6 +39,7 @@ const DefaultRecordLifetime = time.Hour * 24
 
 type Republisher struct {
 	ns   namesys.Publisher
+	rt   routing.ValueStore
 	ds   ds.Datastore
 	self ic.PrivKey
 	ks   keystore.Keystore
@@ -47,10 +50,13 @@ type Republisher struct {
 	RecordLifetime time.Duration
 }
 
-// NewRepublisher creates a new Republisher
-func NewRepublisher(ns namesys.Publisher, ds ds.Datastore, self ic.PrivKey, ks keystore.Keystore) *Republisher {
+// NewRepublisher creates a new Republisher. The routing system is used to
+// keep watched third-party names alive; it may be nil, in which case only
+// the node's own names are republished.
+func NewRepublisher(ns namesys.Publisher, rt routing.ValueStore, ds ds.Datastore, self ic.PrivKey, ks keystore.Keystore) *Republisher {
 	return &Republisher{
 		ns:             ns,
+		rt:             rt,
 		ds:             ds,
 		self:           self,
 		ks:             ks,
@@ -87,6 +93,21 @@ func (rp *Republisher) republishEntries(p goprocess.Process) error {
 	ctx, cancel := context.WithCancel(gpctx.OnClosingContext(p))
 	defer cancel()
 
+	// The watched names don't depend on our own keys: keep them alive even
+	// when republishing our records fails.
+	var errs error
+	if err := rp.republishOwnEntries(ctx); err != nil {
+		errs = multierror.Append(errs, err)
+	}
+	if rp.rt != nil {
+		if err := rp.republishWatched(ctx); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+	}
+	return errs
+}
+
+func (rp *Republisher) republishOwnEntries(ctx context.Context) error {
 	// TODO: Use rp.ipns.ListPublished(). We can't currently *do* that
 	// because:
 	// 1. There's no way to get keys from the keystore by ID.
@@ -103,6 +124,10 @@ func (rp *Republisher) republishEntries(p goprocess.Process) error {
 		}
 		for _, name := range keyNames {
 			priv, err := rp.ks.Get(name)
+			if err == keystore.ErrKeystoreLocked {
+				log.Warning("keystore is locked, not republishing the records of its keys")
+				break
+			}
 			if err != nil {
 				return err
 			}
@@ -0,0 +1,177 @@
+package republisher
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	namesys "github.com/ipfs/go-ipfs/namesys"
+
+	proto "github.com/gogo/protobuf/proto"
+	ds "github.com/ipfs/go-datastore"
+	dsquery "github.com/ipfs/go-datastore/query"
+	ipns "github.com/ipfs/go-ipns"
+	pb "github.com/ipfs/go-ipns/pb"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	routing "github.com/libp2p/go-libp2p-core/routing"
+	base32 "github.com/whyrusleeping/base32"
+)
+
+// ErrNotWatched is returned when removing a name that is not being watched.
+var ErrNotWatched = errors.New("name is not being watched")
+
+var errNoValidEntry = errors.New("no valid record known")
+
+const watchPrefix = "/ipns-watch/"
+
+// WatchLookupTimeout bounds the time spent looking for the current record of
+// a watched name in the routing system.
+const WatchLookupTimeout = time.Second * 30
+
+func watchDsKey(id peer.ID) ds.Key {
+	return ds.NewKey(watchPrefix + base32.RawStdEncoding.EncodeToString([]byte(id)))
+}
+
+// Watch adds the given name to the list of third-party names kept alive by
+// the republisher. If entry is non-nil, it is remembered as the latest known
+// record for the name; it must already have been validated by the caller.
+func Watch(dstore ds.Datastore, id peer.ID, entry *pb.IpnsEntry) error {
+	var data []byte
+	if entry != nil {
+		var err error
+		data, err = proto.Marshal(entry)
+		if err != nil {
+			return err
+		}
+	}
+	return dstore.Put(watchDsKey(id), data)
+}
+
+// Unwatch removes the given name from the list of watched names.
+func Unwatch(dstore ds.Datastore, id peer.ID) error {
+	key := watchDsKey(id)
+	has, err := dstore.Has(key)
+	if err != nil {
+		return err
+	}
+	if !has {
+		return ErrNotWatched
+	}
+	return dstore.Delete(key)
+}
+
+// Watched returns the watched names along with the latest record seen for
+// each of them. The record is nil for names we have not seen a record for yet.
+func Watched(dstore ds.Datastore) (map[peer.ID]*pb.IpnsEntry, error) {
+	results, err := dstore.Query(dsquery.Query{
+		Prefix: watchPrefix,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	watched := make(map[peer.ID]*pb.IpnsEntry)
+	for result := range results.Next() {
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if !strings.HasPrefix(result.Key, watchPrefix) {
+			log.Errorf("datastore query for keys with prefix %s returned a key: %s", watchPrefix, result.Key)
+			continue
+		}
+		pid, err := base32.RawStdEncoding.DecodeString(result.Key[len(watchPrefix):])
+		if err != nil {
+			log.Errorf("ipns watch ds key invalid: %s", result.Key)
+			continue
+		}
+
+		var entry *pb.IpnsEntry
+		if len(result.Value) > 0 {
+			entry = new(pb.IpnsEntry)
+			if err := proto.Unmarshal(result.Value, entry); err != nil {
+				log.Errorf("found an invalid IPNS entry for watched name %s: %s", peer.ID(pid), err)
+				entry = nil
+			}
+		}
+		watched[peer.ID(pid)] = entry
+	}
+	return watched, nil
+}
+
+func (rp *Republisher) republishWatched(ctx context.Context) error {
+	watched, err := Watched(rp.ds)
+	if err != nil {
+		return err
+	}
+
+	// A watched name going stale is expected (its owner is offline, after
+	// all), so failures are logged and don't hold up the other names.
+	for id, last := range watched {
+		if err := rp.republishWatchedEntry(ctx, id, last); err != nil {
+			log.Infof("failed to republish watched name %s: %s", id, err)
+		}
+	}
+	return nil
+}
+
+func (rp *Republisher) republishWatchedEntry(ctx context.Context, id peer.ID, last *pb.IpnsEntry) error {
+	log.Debugf("republishing watched ipns entry for %s", id)
+
+	if last != nil {
+		if _, err := namesys.ValidateRecord(ctx, rp.rt, id, last); err != nil {
+			log.Debugf("last known record for watched name %s is no longer valid: %s", id, err)
+			last = nil
+		}
+	}
+
+	// Pick up records published by the owner since the last round.
+	if latest := rp.lookupWatched(ctx, id); latest != nil {
+		newer := last == nil
+		if !newer {
+			cmp, err := ipns.Compare(latest, last)
+			newer = err == nil && cmp > 0
+		}
+		if newer {
+			if err := Watch(rp.ds, id, latest); err != nil {
+				return err
+			}
+			last = latest
+		}
+	}
+
+	if last == nil {
+		return errNoValidEntry
+	}
+
+	pk, err := namesys.RecordPublicKey(ctx, rp.rt, id, last)
+	if err != nil {
+		return err
+	}
+	return namesys.PutRecordToRouting(ctx, rp.rt, pk, last)
+}
+
+// lookupWatched returns the best valid record for id currently held by the
+// routing system, or nil if there is none.
+func (rp *Republisher) lookupWatched(ctx context.Context, id peer.ID) *pb.IpnsEntry {
+	ctx, cancel := context.WithTimeout(ctx, WatchLookupTimeout)
+	defer cancel()
+
+	val, err := rp.rt.GetValue(ctx, ipns.RecordKey(id))
+	if err != nil {
+		if err != routing.ErrNotFound {
+			log.Debugf("error looking up record for watched name %s: %s", id, err)
+		}
+		return nil
+	}
+
+	entry, err := namesys.UnmarshalRecord(val)
+	if err != nil {
+		return nil
+	}
+	if _, err := namesys.ValidateRecord(ctx, rp.rt, id, entry); err != nil {
+		return nil
+	}
+	return entry
+}
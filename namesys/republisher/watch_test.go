@@ -0,0 +1,205 @@
+package republisher
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	namesys "github.com/ipfs/go-ipfs/namesys"
+
+	proto "github.com/gogo/protobuf/proto"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	offroute "github.com/ipfs/go-ipfs-routing/offline"
+	ipns "github.com/ipfs/go-ipns"
+	path "github.com/ipfs/go-path"
+	goprocess "github.com/jbenet/goprocess"
+	ci "github.com/libp2p/go-libp2p-core/crypto"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	routing "github.com/libp2p/go-libp2p-core/routing"
+	pstoremem "github.com/libp2p/go-libp2p-peerstore/pstoremem"
+	record "github.com/libp2p/go-libp2p-record"
+)
+
+func TestWatchList(t *testing.T) {
+	dstore := ds.NewMapDatastore()
+
+	sk, pk, err := ci.GenerateKeyPair(ci.Ed25519, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := peer.IDFromPublicKey(pk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, err := ipns.Create(sk, []byte("/ipfs/QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn"), 3, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other := peer.ID("other")
+
+	if err := Watch(dstore, id, entry); err != nil {
+		t.Fatal(err)
+	}
+	if err := Watch(dstore, other, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	watched, err := Watched(dstore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(watched) != 2 {
+		t.Fatalf("expected 2 watched names, got %d", len(watched))
+	}
+	if got := watched[id]; got == nil || got.GetSequence() != 3 || string(got.GetValue()) != string(entry.GetValue()) {
+		t.Fatalf("unexpected record for watched name: %v", got)
+	}
+	if got, ok := watched[other]; !ok || got != nil {
+		t.Fatalf("expected watched name without record, got %v", got)
+	}
+
+	if err := Unwatch(dstore, other); err != nil {
+		t.Fatal(err)
+	}
+	if err := Unwatch(dstore, other); err != ErrNotWatched {
+		t.Fatalf("expected ErrNotWatched, got %v", err)
+	}
+
+	watched, err = Watched(dstore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := watched[other]; ok || len(watched) != 1 {
+		t.Fatal("name still watched after removal")
+	}
+}
+
+// failingPublisher fails to publish anything.
+type failingPublisher struct{}
+
+func (failingPublisher) Publish(ctx context.Context, k ci.PrivKey, value path.Path) error {
+	return errors.New("publish failed")
+}
+
+func (failingPublisher) PublishWithEOL(ctx context.Context, k ci.PrivKey, value path.Path, eol time.Time) error {
+	return errors.New("publish failed")
+}
+
+func (failingPublisher) Rollback(ctx context.Context, k ci.PrivKey, seq uint64, eol time.Time) (path.Path, error) {
+	return "", errors.New("publish failed")
+}
+
+func newTestRouting() routing.ValueStore {
+	return offroute.NewOfflineRouter(dssync.MutexWrap(ds.NewMapDatastore()), record.NamespacedValidator{
+		"ipns": ipns.Validator{KeyBook: pstoremem.NewPeerstore()},
+		"pk":   record.PublicKeyValidator{},
+	})
+}
+
+func TestRepublishWatched(t *testing.T) {
+	ctx := context.Background()
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	rt := newTestRouting()
+
+	sk, _, err := ci.GenerateKeyPair(ci.Ed25519, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := peer.IDFromPrivateKey(sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, err := ipns.Create(sk, []byte("/ipfs/QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn"), 1, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Watch(dstore, id, entry); err != nil {
+		t.Fatal(err)
+	}
+
+	// Our own record can't be republished: the watched names must be
+	// republished all the same.
+	self, _, err := ci.GenerateKeyPair(ci.Ed25519, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	selfID, err := peer.IDFromPrivateKey(self)
+	if err != nil {
+		t.Fatal(err)
+	}
+	selfEntry, err := ipns.Create(self, []byte("/ipfs/QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn"), 1, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := proto.Marshal(selfEntry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dstore.Put(namesys.IpnsDsKey(selfID), data); err != nil {
+		t.Fatal(err)
+	}
+
+	rp := NewRepublisher(failingPublisher{}, rt, dstore, self, nil)
+	if err := rp.republishEntries(goprocess.Background()); err == nil {
+		t.Fatal("expected the failure to republish our record to be reported")
+	}
+
+	val, err := rt.GetValue(ctx, ipns.RecordKey(id))
+	if err != nil {
+		t.Fatalf("watched record not republished: %s", err)
+	}
+	got, err := namesys.UnmarshalRecord(val)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.GetSequence() != 1 || string(got.GetValue()) != string(entry.GetValue()) {
+		t.Fatalf("unexpected record in routing: %v", got)
+	}
+}
+
+func TestRepublishWatchedPicksUpNewer(t *testing.T) {
+	ctx := context.Background()
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	rt := newTestRouting()
+
+	sk, _, err := ci.GenerateKeyPair(ci.Ed25519, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := peer.IDFromPrivateKey(sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old, err := ipns.Create(sk, []byte("/ipfs/QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn"), 1, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Watch(dstore, id, old); err != nil {
+		t.Fatal(err)
+	}
+
+	// The owner published a newer record since.
+	newer, err := ipns.Create(sk, []byte("/ipfs/QmbFMke1KXqnYyBBWxB74N4c5SBnJMVAiMNRcGu6x1AwQH"), 2, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := namesys.PutRecordToRouting(ctx, rt, sk.GetPublic(), newer); err != nil {
+		t.Fatal(err)
+	}
+
+	rp := NewRepublisher(failingPublisher{}, rt, dstore, sk, nil)
+	if err := rp.republishWatchedEntry(ctx, id, old); err != nil {
+		t.Fatal(err)
+	}
+
+	watched, err := Watched(dstore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := watched[id]; got == nil || got.GetSequence() != 2 {
+		t.Fatalf("newer record not remembered: %v", got)
+	}
+}
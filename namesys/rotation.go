@@ -0,0 +1,63 @@
+package namesys
+
+import (
+	"encoding/json"
+	"time"
+
+	ds "github.com/ipfs/go-datastore"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	base32 "github.com/whyrusleeping/base32"
+)
+
+// Rotation records that the key of an IPNS name was replaced by another one.
+// The last record published under the old key points to /ipns/<To>.
+type Rotation struct {
+	From peer.ID
+	To   peer.ID
+	Time time.Time
+}
+
+// IpnsRotationDsKey returns the datastore key under which the rotation of the
+// given ID is recorded.
+func IpnsRotationDsKey(id peer.ID) ds.Key {
+	return ds.NewKey("/ipns-rotation/" + base32.RawStdEncoding.EncodeToString([]byte(id)))
+}
+
+// RecordRotation records that the key of from was replaced by the key of to.
+func RecordRotation(dstore ds.Datastore, from, to peer.ID) error {
+	data, err := json.Marshal(Rotation{From: from, To: to, Time: time.Now()})
+	if err != nil {
+		return err
+	}
+	return dstore.Put(IpnsRotationDsKey(from), data)
+}
+
+// Rotations returns the chain of rotations recorded by this node starting at
+// the given ID, oldest first. It is empty if the key was never rotated.
+func Rotations(dstore ds.Datastore, id peer.ID) ([]Rotation, error) {
+	var chain []Rotation
+	seen := map[peer.ID]bool{id: true}
+	for {
+		data, err := dstore.Get(IpnsRotationDsKey(id))
+		switch err {
+		case nil:
+		case ds.ErrNotFound:
+			return chain, nil
+		default:
+			return nil, err
+		}
+
+		var r Rotation
+		if err := json.Unmarshal(data, &r); err != nil {
+			return nil, err
+		}
+		chain = append(chain, r)
+
+		// Guard against loops, e.g. after rotating back to an old key.
+		if seen[r.To] {
+			return chain, nil
+		}
+		seen[r.To] = true
+		id = r.To
+	}
+}
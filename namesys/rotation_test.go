@@ -0,0 +1,46 @@
+package namesys
+
+import (
+	"testing"
+
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+)
+
+func TestRotations(t *testing.T) {
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	a, b, c := peer.ID("a"), peer.ID("b"), peer.ID("c")
+
+	chain, err := Rotations(dstore, a)
+	if err != nil || len(chain) != 0 {
+		t.Fatalf("expected no rotations, got %v, %v", chain, err)
+	}
+
+	if err := RecordRotation(dstore, a, b); err != nil {
+		t.Fatal(err)
+	}
+	if err := RecordRotation(dstore, b, c); err != nil {
+		t.Fatal(err)
+	}
+
+	chain, err = Rotations(dstore, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chain) != 2 || chain[0].To != b || chain[1].From != b || chain[1].To != c {
+		t.Fatalf("unexpected chain %v", chain)
+	}
+
+	// Rotating back to an old key must not loop forever.
+	if err := RecordRotation(dstore, c, a); err != nil {
+		t.Fatal(err)
+	}
+	chain, err = Rotations(dstore, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chain) != 3 {
+		t.Fatalf("unexpected chain %v", chain)
+	}
+}
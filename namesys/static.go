@@ -0,0 +1,39 @@
+package namesys
+
+import (
+	"context"
+	"time"
+
+	path "github.com/ipfs/go-path"
+)
+
+// StaticResolver resolves names from a fixed map, e.g. one taken from the
+// config file.
+type StaticResolver struct {
+	names map[string]path.Path
+}
+
+var _ NameResolver = (*StaticResolver)(nil)
+
+// NewStaticResolver constructs a resolver for the given name to path map.
+func NewStaticResolver(names map[string]path.Path) *StaticResolver {
+	return &StaticResolver{names: names}
+}
+
+// Names returns the names known to the resolver.
+func (r *StaticResolver) Names() []string {
+	names := make([]string, 0, len(r.names))
+	for name := range r.names {
+		names = append(names, name)
+	}
+	return names
+}
+
+// ResolveOnce implements NameResolver.
+func (r *StaticResolver) ResolveOnce(ctx context.Context, name string) (path.Path, time.Duration, error) {
+	p, ok := r.names[name]
+	if !ok {
+		return "", 0, ErrResolveFailed
+	}
+	return p, DefaultResolverCacheTTL, nil
+}
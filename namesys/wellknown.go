@@ -0,0 +1,103 @@
+package namesys
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	path "github.com/ipfs/go-path"
+	isd "github.com/jbenet/go-is-domain"
+)
+
+// WellKnownPath is the location, on the HTTPS server of a domain, of the
+// document read by the WellKnownResolver.
+const WellKnownPath = "/.well-known/dnslink"
+
+// maxWellKnownSize bounds the size of the documents read by the
+// WellKnownResolver.
+const maxWellKnownSize = 4 << 10
+
+// WellKnownResolver resolves domain names by fetching
+// https://<domain>/.well-known/dnslink. The document holds a single entry in
+// the same format as a DNS TXT record, e.g. "dnslink=/ipfs/<cid>".
+//
+// This lets names be served by hosts that can't easily set DNS records.
+type WellKnownResolver struct {
+	client *http.Client
+	scheme string
+}
+
+var _ NameResolver = (*WellKnownResolver)(nil)
+
+// NewWellKnownResolver constructs a resolver using the given HTTP client, or
+// a client with a sensible timeout if nil.
+func NewWellKnownResolver(client *http.Client) *WellKnownResolver {
+	if client == nil {
+		client = &http.Client{Timeout: time.Second * 30}
+	}
+	return &WellKnownResolver{client: client, scheme: "https"}
+}
+
+// ResolveOnce implements NameResolver.
+func (r *WellKnownResolver) ResolveOnce(ctx context.Context, name string) (path.Path, time.Duration, error) {
+	if !isd.IsDomain(name) {
+		return "", 0, fmt.Errorf("not a valid domain name: %s", name)
+	}
+	log.Debugf("WellKnownResolver resolving %s", name)
+
+	req, err := http.NewRequest("GET", r.scheme+"://"+name+WellKnownPath, nil)
+	if err != nil {
+		return "", 0, err
+	}
+	resp, err := r.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		log.Debugf("WellKnownResolver: fetching %s%s: %s", name, WellKnownPath, resp.Status)
+		return "", 0, ErrResolveFailed
+	}
+
+	scanner := bufio.NewScanner(io.LimitReader(resp.Body, maxWellKnownSize))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		p, err := parseEntry(line)
+		if err != nil {
+			return "", 0, err
+		}
+		return p, cacheControlTTL(resp.Header.Get("Cache-Control")), nil
+	}
+	if err := scanner.Err(); err != nil {
+		return "", 0, err
+	}
+	return "", 0, ErrResolveFailed
+}
+
+// cacheControlTTL returns how long a response may be cached for according to
+// its Cache-Control header, never exceeding DefaultResolverCacheTTL.
+func cacheControlTTL(header string) time.Duration {
+	ttl := DefaultResolverCacheTTL
+	for _, directive := range strings.Split(header, ",") {
+		directive = strings.TrimSpace(strings.ToLower(directive))
+		switch {
+		case directive == "no-cache" || directive == "no-store":
+			return 0
+		case strings.HasPrefix(directive, "max-age="):
+			secs, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age="))
+			if err == nil && secs >= 0 && time.Duration(secs)*time.Second < ttl {
+				ttl = time.Duration(secs) * time.Second
+			}
+		}
+	}
+	return ttl
+}
@@ -0,0 +1,32 @@
+package p2p
+
+import (
+	peer "github.com/libp2p/go-libp2p-core/peer"
+)
+
+// PeerACL decides which peers may open streams to a listener.
+type PeerACL struct {
+	// Allow lists the only peers allowed, unless empty.
+	Allow []peer.ID
+	// Deny lists peers that are never allowed, even if in Allow.
+	Deny []peer.ID
+}
+
+// Allowed reports whether the given peer passes the ACL. The zero PeerACL
+// allows every peer.
+func (acl PeerACL) Allowed(p peer.ID) bool {
+	for _, d := range acl.Deny {
+		if d == p {
+			return false
+		}
+	}
+	if len(acl.Allow) == 0 {
+		return true
+	}
+	for _, a := range acl.Allow {
+		if a == p {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,149 @@
+package p2p
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	humanize "github.com/dustin/go-humanize"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	pstore "github.com/libp2p/go-libp2p-core/peerstore"
+	protocol "github.com/libp2p/go-libp2p-core/protocol"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// Config is the top-level "P2P" config section. It declares forwards and
+// listeners to create when the daemon starts.
+type Config struct {
+	Forwards  []ForwardConfig  `json:",omitempty"`
+	Listeners []ListenerConfig `json:",omitempty"`
+}
+
+// ForwardConfig declares a forward, as created by 'ipfs p2p forward'.
+type ForwardConfig struct {
+	Protocol      string
+	ListenAddress string
+	// TargetAddress is the /ipfs/<peer> address of the remote listener,
+	// optionally prefixed with transport addresses of the peer.
+	TargetAddress string
+
+	LimitsConfig
+}
+
+// ListenerConfig declares a listener, as created by 'ipfs p2p listen'.
+type ListenerConfig struct {
+	Protocol      string
+	TargetAddress string
+	ReportPeerID  bool     `json:",omitempty"`
+	AllowPeers    []string `json:",omitempty"`
+	DenyPeers     []string `json:",omitempty"`
+
+	LimitsConfig
+}
+
+// LimitsConfig declares the Limits of a forward or listener.
+type LimitsConfig struct {
+	MaxStreams int `json:",omitempty"`
+	// Bandwidth is a rate in bytes per second, such as "1MB".
+	Bandwidth string `json:",omitempty"`
+	// IdleTimeout is a duration, such as "10m".
+	IdleTimeout string `json:",omitempty"`
+}
+
+// Limits parses the declared limits.
+func (c *LimitsConfig) Limits() (Limits, error) {
+	limits := Limits{MaxStreams: c.MaxStreams}
+	if c.MaxStreams < 0 {
+		return limits, fmt.Errorf("invalid max streams %d", c.MaxStreams)
+	}
+	if c.Bandwidth != "" {
+		bw, err := humanize.ParseBytes(c.Bandwidth)
+		if err != nil {
+			return limits, fmt.Errorf("invalid bandwidth %q: %s", c.Bandwidth, err)
+		}
+		limits.Bandwidth = int64(bw)
+	}
+	if c.IdleTimeout != "" {
+		timeout, err := time.ParseDuration(c.IdleTimeout)
+		if err != nil {
+			return limits, fmt.Errorf("invalid idle timeout %q: %s", c.IdleTimeout, err)
+		}
+		if timeout < 0 {
+			return limits, fmt.Errorf("invalid idle timeout %q", c.IdleTimeout)
+		}
+		limits.IdleTimeout = timeout
+	}
+	return limits, nil
+}
+
+// ACL returns the PeerACL of the listener.
+func (c *ListenerConfig) ACL() (PeerACL, error) {
+	var acl PeerACL
+	var err error
+	if acl.Allow, err = ParsePeerList(c.AllowPeers); err != nil {
+		return acl, err
+	}
+	acl.Deny, err = ParsePeerList(c.DenyPeers)
+	return acl, err
+}
+
+// ParsePeerList decodes a list of base58 peer IDs.
+func ParsePeerList(list []string) ([]peer.ID, error) {
+	var peers []peer.ID
+	for _, s := range list {
+		p, err := peer.IDB58Decode(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid peer ID %q: %s", s, err)
+		}
+		peers = append(peers, p)
+	}
+	return peers, nil
+}
+
+// Restore creates the forwards and listeners declared in cfg.
+func (p2p *P2P) Restore(ctx context.Context, cfg Config) error {
+	for _, f := range cfg.Forwards {
+		listen, err := ma.NewMultiaddr(f.ListenAddress)
+		if err != nil {
+			return fmt.Errorf("forward %s: %s", f.Protocol, err)
+		}
+		target, err := ma.NewMultiaddr(f.TargetAddress)
+		if err != nil {
+			return fmt.Errorf("forward %s: %s", f.Protocol, err)
+		}
+		pi, err := peer.AddrInfoFromP2pAddr(target)
+		if err != nil {
+			return fmt.Errorf("forward %s: %s", f.Protocol, err)
+		}
+		limits, err := f.Limits()
+		if err != nil {
+			return fmt.Errorf("forward %s: %s", f.Protocol, err)
+		}
+
+		p2p.peerstore.AddAddrs(pi.ID, pi.Addrs, pstore.PermanentAddrTTL)
+		if _, err := p2p.ForwardLocal(ctx, pi.ID, protocol.ID(f.Protocol), listen, limits); err != nil {
+			return fmt.Errorf("forward %s on %s: %s", f.Protocol, f.ListenAddress, err)
+		}
+	}
+
+	for _, l := range cfg.Listeners {
+		target, err := ma.NewMultiaddr(l.TargetAddress)
+		if err != nil {
+			return fmt.Errorf("listener %s: %s", l.Protocol, err)
+		}
+		acl, err := l.ACL()
+		if err != nil {
+			return fmt.Errorf("listener %s: %s", l.Protocol, err)
+		}
+		limits, err := l.Limits()
+		if err != nil {
+			return fmt.Errorf("listener %s: %s", l.Protocol, err)
+		}
+
+		if _, err := p2p.ForwardRemote(ctx, protocol.ID(l.Protocol), target, l.ReportPeerID, acl, limits); err != nil {
+			return fmt.Errorf("listener %s: %s", l.Protocol, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,128 @@
+package p2p
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Limits bound the resources used by the streams of a listener, so that a
+// single tunnel can't starve the node. Zero values mean no limit.
+type Limits struct {
+	// MaxStreams is the maximum number of concurrent streams.
+	MaxStreams int
+
+	// Bandwidth is the rate, in bytes per second, at which data is
+	// forwarded in each direction. It is shared by all the streams of the
+	// listener.
+	Bandwidth int64
+
+	// IdleTimeout closes streams that didn't forward any data for that
+	// long.
+	IdleTimeout time.Duration
+}
+
+// limiter enforces the Limits of a listener.
+type limiter struct {
+	limits Limits
+
+	mu     sync.Mutex
+	active int
+
+	// in limits the data received from remote peers, out the data sent to
+	// them.
+	in, out *rateLimiter
+}
+
+func newLimiter(limits Limits) *limiter {
+	l := &limiter{limits: limits}
+	if limits.Bandwidth > 0 {
+		l.in = &rateLimiter{rate: limits.Bandwidth}
+		l.out = &rateLimiter{rate: limits.Bandwidth}
+	}
+	return l
+}
+
+// acquire reserves a stream slot, it returns false if the listener already
+// has MaxStreams active streams.
+func (l *limiter) acquire() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.limits.MaxStreams > 0 && l.active >= l.limits.MaxStreams {
+		return false
+	}
+	l.active++
+	return true
+}
+
+// release frees a slot reserved with acquire.
+func (l *limiter) release() {
+	l.mu.Lock()
+	l.active--
+	l.mu.Unlock()
+}
+
+// rateLimiter is a token bucket allowing bursts of up to one second worth of
+// traffic.
+type rateLimiter struct {
+	rate int64
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// limit shortens p so that reads are spread over time instead of forwarding a
+// whole buffer at once.
+func (r *rateLimiter) limit(p []byte) []byte {
+	chunk := int(r.rate / 10)
+	if chunk < 512 {
+		chunk = 512
+	}
+	if len(p) > chunk {
+		return p[:chunk]
+	}
+	return p
+}
+
+// wait blocks until n more bytes can be forwarded.
+func (r *rateLimiter) wait(n int) {
+	r.mu.Lock()
+	now := time.Now()
+	if earliest := now.Add(-time.Second); r.next.Before(earliest) {
+		r.next = earliest
+	}
+	r.next = r.next.Add(time.Duration(int64(n) * int64(time.Second) / r.rate))
+	delay := r.next.Sub(now)
+	r.mu.Unlock()
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+}
+
+// meteredReader counts the bytes read from a stream endpoint, records the
+// stream activity and applies the bandwidth limit.
+type meteredReader struct {
+	r     io.Reader
+	s     *Stream
+	count *uint64
+	rate  *rateLimiter
+}
+
+func (m *meteredReader) Read(p []byte) (int, error) {
+	if m.rate != nil {
+		p = m.rate.limit(p)
+	}
+
+	n, err := m.r.Read(p)
+	if n > 0 {
+		atomic.AddUint64(m.count, uint64(n))
+		m.s.touch()
+		if m.rate != nil {
+			m.rate.wait(n)
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,66 @@
+package p2p
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestLimiterMaxStreams(t *testing.T) {
+	l := newLimiter(Limits{MaxStreams: 2})
+	if !l.acquire() || !l.acquire() {
+		t.Fatal("failed to acquire streams under the limit")
+	}
+	if l.acquire() {
+		t.Fatal("acquired more streams than the limit")
+	}
+
+	l.release()
+	if !l.acquire() {
+		t.Fatal("failed to acquire a released stream")
+	}
+
+	unlimited := newLimiter(Limits{})
+	for i := 0; i < 100; i++ {
+		if !unlimited.acquire() {
+			t.Fatal("failed to acquire a stream without limit")
+		}
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	r := &rateLimiter{rate: 10000}
+
+	// The first second worth of traffic goes through as a burst.
+	start := time.Now()
+	r.wait(10000)
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Fatalf("burst was delayed by %s", elapsed)
+	}
+
+	r.wait(5000)
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Fatalf("traffic over the rate was only delayed by %s", elapsed)
+	}
+}
+
+func TestMeteredReader(t *testing.T) {
+	s := &Stream{}
+	data := bytes.Repeat([]byte("x"), 4096)
+	m := &meteredReader{r: bytes.NewReader(data), s: s, count: &s.bytesIn, rate: &rateLimiter{rate: 1 << 20}}
+
+	out, err := ioutil.ReadAll(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, data) {
+		t.Fatal("metered reader altered the data")
+	}
+	if s.BytesIn() != uint64(len(data)) || s.BytesOut() != 0 {
+		t.Fatalf("counted %d bytes in and %d out, expected %d in", s.BytesIn(), s.BytesOut(), len(data))
+	}
+	if s.Idle() > time.Second {
+		t.Fatal("reading didn't record the stream activity")
+	}
+}
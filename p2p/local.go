@@ -2,6 +2,9 @@ package p2p
 
 import (
 	"context"
+	"io"
+	gonet "net"
+	"sync"
 	"time"
 
 	tec "github.com/jbenet/go-temp-err-catcher"
@@ -12,7 +15,8 @@ import (
 	"github.com/multiformats/go-multiaddr-net"
 )
 
-// localListener manet streams and proxies them to libp2p services
+// localListener accepts local stream connections (TCP or unix sockets) or
+// datagrams (UDP) and proxies them to libp2p services
 type localListener struct {
 	ctx context.Context
 
@@ -22,31 +26,64 @@ type localListener struct {
 	laddr ma.Multiaddr
 	peer  peer.ID
 
-	listener manet.Listener
+	listener io.Closer
+
+	limiter *limiter
 }
 
-// ForwardLocal creates new P2P stream to a remote listener
-func (p2p *P2P) ForwardLocal(ctx context.Context, peer peer.ID, proto protocol.ID, bindAddr ma.Multiaddr) (Listener, error) {
+// ForwardLocal creates new P2P stream to a remote listener, whose streams are
+// bound by limits
+func (p2p *P2P) ForwardLocal(ctx context.Context, peer peer.ID, proto protocol.ID, bindAddr ma.Multiaddr, limits Limits) (Listener, error) {
 	listener := &localListener{
-		ctx:   ctx,
-		p2p:   p2p,
-		proto: proto,
-		peer:  peer,
+		ctx:     ctx,
+		p2p:     p2p,
+		proto:   proto,
+		peer:    peer,
+		laddr:   bindAddr,
+		limiter: newLimiter(limits),
 	}
 
-	maListener, err := manet.Listen(bindAddr)
+	network, address, err := localNetAddr(bindAddr)
 	if err != nil {
 		return nil, err
 	}
 
-	listener.listener = maListener
-	listener.laddr = maListener.Multiaddr()
+	var accept func()
+	if isDatagram(network) {
+		pc, err := gonet.ListenPacket(network, address)
+		if err != nil {
+			return nil, err
+		}
+		listener.listener = pc
+		accept = func() { listener.acceptDatagrams(pc) }
+
+		if listener.laddr, err = manet.FromNetAddr(pc.LocalAddr()); err != nil {
+			pc.Close()
+			return nil, err
+		}
+	} else {
+		nl, err := gonet.Listen(network, address)
+		if err != nil {
+			return nil, err
+		}
+		listener.listener = nl
+		accept = func() { listener.acceptConns(nl) }
+
+		// unix socket addresses are kept as given
+		if network != "unix" {
+			if listener.laddr, err = manet.FromNetAddr(nl.Addr()); err != nil {
+				nl.Close()
+				return nil, err
+			}
+		}
+	}
 
 	if err := p2p.ListenersLocal.Register(listener); err != nil {
+		listener.listener.Close()
 		return nil, err
 	}
 
-	go listener.acceptConns()
+	go accept()
 
 	return listener, nil
 }
@@ -58,9 +95,9 @@ func (l *localListener) dial(ctx context.Context) (net.Stream, error) {
 	return l.p2p.peerHost.NewStream(cctx, l.peer, l.proto)
 }
 
-func (l *localListener) acceptConns() {
+func (l *localListener) acceptConns(nl gonet.Listener) {
 	for {
-		local, err := l.listener.Accept()
+		local, err := nl.Accept()
 		if err != nil {
 			if tec.ErrIsTemporary(err) {
 				continue
@@ -68,13 +105,76 @@ func (l *localListener) acceptConns() {
 			return
 		}
 
-		go l.setupStream(local)
+		origin, err := manet.FromNetAddr(local.RemoteAddr())
+		if err != nil {
+			// e.g. unix sockets, whose clients have no address
+			origin = l.laddr
+		}
+
+		go l.setupStream(local, origin)
 	}
 }
 
-func (l *localListener) setupStream(local manet.Conn) {
+// acceptDatagrams forwards the datagrams of each local client over its own
+// libp2p stream.
+func (l *localListener) acceptDatagrams(pc gonet.PacketConn) {
+	var mu sync.Mutex
+	sessions := make(map[string]*udpSession)
+	defer func() {
+		mu.Lock()
+		toClose := make([]*udpSession, 0, len(sessions))
+		for _, s := range sessions {
+			toClose = append(toClose, s)
+		}
+		mu.Unlock()
+		for _, s := range toClose {
+			s.Close()
+		}
+	}()
+
+	buf := make([]byte, maxDatagramSize)
+	for {
+		n, addr, err := pc.ReadFrom(buf)
+		if err != nil {
+			if tec.ErrIsTemporary(err) {
+				continue
+			}
+			return
+		}
+
+		key := addr.String()
+		mu.Lock()
+		s, ok := sessions[key]
+		if !ok {
+			s = newUDPSession(pc, addr, func() {
+				mu.Lock()
+				delete(sessions, key)
+				mu.Unlock()
+			})
+			sessions[key] = s
+
+			origin, err := manet.FromNetAddr(addr)
+			if err != nil {
+				origin = l.laddr
+			}
+			go l.setupStream(s, origin)
+		}
+		mu.Unlock()
+
+		s.deliver(buf[:n])
+	}
+}
+
+func (l *localListener) setupStream(local io.ReadWriteCloser, origin ma.Multiaddr) {
+	if !l.limiter.acquire() {
+		local.Close()
+		log.Infof("rejecting connection to %s/%s: too many streams", l.peer.Pretty(), l.proto)
+		return
+	}
+
 	remote, err := l.dial(l.ctx)
 	if err != nil {
+		l.limiter.release()
 		local.Close()
 		log.Warningf("failed to dial to remote %s/%s", l.peer.Pretty(), l.proto)
 		return
@@ -83,7 +183,7 @@ func (l *localListener) setupStream(local manet.Conn) {
 	stream := &Stream{
 		Protocol: l.proto,
 
-		OriginAddr: local.RemoteMultiaddr(),
+		OriginAddr: origin,
 		TargetAddr: l.TargetAddress(),
 		peer:       l.peer,
 
@@ -91,6 +191,8 @@ func (l *localListener) setupStream(local manet.Conn) {
 		Remote: remote,
 
 		Registry: l.p2p.Streams,
+
+		limiter: l.limiter,
 	}
 
 	l.p2p.Streams.Register(stream)
@@ -116,6 +218,11 @@ func (l *localListener) TargetAddress() ma.Multiaddr {
 	return addr
 }
 
+// Limits returns the limits applying to the streams of the listener
+func (l *localListener) Limits() Limits {
+	return l.limiter.limits
+}
+
 func (l *localListener) key() string {
 	return l.ListenAddress().String()
 }
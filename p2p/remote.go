@@ -7,7 +7,6 @@ import (
 	net "github.com/libp2p/go-libp2p-core/network"
 	protocol "github.com/libp2p/go-libp2p-core/protocol"
 	ma "github.com/multiformats/go-multiaddr"
-	manet "github.com/multiformats/go-multiaddr-net"
 )
 
 var maPrefix = "/" + ma.ProtocolWithCode(ma.P_IPFS).Name + "/"
@@ -25,10 +24,16 @@ type remoteListener struct {
 	// reportRemote if set to true makes the handler send '<base58 remote peerid>\n'
 	// to target before any data is forwarded
 	reportRemote bool
+
+	// acl restricts the peers allowed to open streams
+	acl PeerACL
+
+	limiter *limiter
 }
 
-// ForwardRemote creates new p2p listener
-func (p2p *P2P) ForwardRemote(ctx context.Context, proto protocol.ID, addr ma.Multiaddr, reportRemote bool) (Listener, error) {
+// ForwardRemote creates new p2p listener, accepting streams from the peers
+// allowed by acl, bound by limits
+func (p2p *P2P) ForwardRemote(ctx context.Context, proto protocol.ID, addr ma.Multiaddr, reportRemote bool, acl PeerACL, limits Limits) (Listener, error) {
 	listener := &remoteListener{
 		p2p: p2p,
 
@@ -36,6 +41,10 @@ func (p2p *P2P) ForwardRemote(ctx context.Context, proto protocol.ID, addr ma.Mu
 		addr:  addr,
 
 		reportRemote: reportRemote,
+
+		acl: acl,
+
+		limiter: newLimiter(limits),
 	}
 
 	if err := p2p.ListenersP2P.Register(listener); err != nil {
@@ -46,23 +55,35 @@ func (p2p *P2P) ForwardRemote(ctx context.Context, proto protocol.ID, addr ma.Mu
 }
 
 func (l *remoteListener) handleStream(remote net.Stream) {
-	local, err := manet.Dial(l.addr)
-	if err != nil {
+	peer := remote.Conn().RemotePeer()
+	if !l.acl.Allowed(peer) {
+		log.Infof("rejecting %s stream from %s: peer not allowed", l.proto, peer.Pretty())
 		_ = remote.Reset()
 		return
 	}
 
-	peer := remote.Conn().RemotePeer()
+	if !l.limiter.acquire() {
+		log.Infof("rejecting %s stream from %s: too many streams", l.proto, peer.Pretty())
+		_ = remote.Reset()
+		return
+	}
 
+	var report string
 	if l.reportRemote {
-		if _, err := fmt.Fprintf(local, "%s\n", peer.Pretty()); err != nil {
-			_ = remote.Reset()
-			return
-		}
+		report = fmt.Sprintf("%s\n", peer.Pretty())
+	}
+
+	local, err := dialLocal(l.addr, report)
+	if err != nil {
+		l.limiter.release()
+		_ = remote.Reset()
+		return
 	}
 
 	peerMa, err := ma.NewMultiaddr(maPrefix + peer.Pretty())
 	if err != nil {
+		l.limiter.release()
+		_ = local.Close()
 		_ = remote.Reset()
 		return
 	}
@@ -78,6 +99,8 @@ func (l *remoteListener) handleStream(remote net.Stream) {
 		Remote: remote,
 
 		Registry: l.p2p.Streams,
+
+		limiter: l.limiter,
 	}
 
 	l.p2p.Streams.Register(stream)
@@ -99,6 +122,16 @@ func (l *remoteListener) TargetAddress() ma.Multiaddr {
 	return l.addr
 }
 
+// ACL returns the peers allowed to open streams to the listener
+func (l *remoteListener) ACL() PeerACL {
+	return l.acl
+}
+
+// Limits returns the limits applying to the streams of the listener
+func (l *remoteListener) Limits() Limits {
+	return l.limiter.limits
+}
+
 func (l *remoteListener) close() {}
 
 func (l *remoteListener) key() string {
@@ -3,19 +3,25 @@ package p2p
 import (
 	"io"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	ifconnmgr "github.com/libp2p/go-libp2p-core/connmgr"
 	net "github.com/libp2p/go-libp2p-core/network"
 	peer "github.com/libp2p/go-libp2p-core/peer"
 	protocol "github.com/libp2p/go-libp2p-core/protocol"
 	ma "github.com/multiformats/go-multiaddr"
-	manet "github.com/multiformats/go-multiaddr-net"
 )
 
 const cmgrTag = "stream-fwd"
 
 // Stream holds information on active incoming and outgoing p2p streams.
 type Stream struct {
+	// accessed atomically, kept first for 64-bit alignment
+	bytesIn    uint64
+	bytesOut   uint64
+	lastActive int64
+
 	id uint64
 
 	Protocol protocol.ID
@@ -24,10 +30,40 @@ type Stream struct {
 	TargetAddr ma.Multiaddr
 	peer       peer.ID
 
-	Local  manet.Conn
+	Local  io.ReadWriteCloser
 	Remote net.Stream
 
 	Registry *StreamRegistry
+
+	// Started is when the stream was registered.
+	Started time.Time
+
+	limiter   *limiter
+	idleTimer *time.Timer
+}
+
+// BytesIn returns the number of bytes received from the remote peer.
+func (s *Stream) BytesIn() uint64 {
+	return atomic.LoadUint64(&s.bytesIn)
+}
+
+// BytesOut returns the number of bytes sent to the remote peer.
+func (s *Stream) BytesOut() uint64 {
+	return atomic.LoadUint64(&s.bytesOut)
+}
+
+// Duration returns how long the stream has been open.
+func (s *Stream) Duration() time.Duration {
+	return time.Since(s.Started)
+}
+
+// Idle returns how long the stream has been without traffic.
+func (s *Stream) Idle() time.Duration {
+	return time.Since(time.Unix(0, atomic.LoadInt64(&s.lastActive)))
+}
+
+func (s *Stream) touch() {
+	atomic.StoreInt64(&s.lastActive, time.Now().UnixNano())
 }
 
 // close stream endpoints and deregister it
@@ -40,9 +76,30 @@ func (s *Stream) reset() {
 	s.Registry.Reset(s)
 }
 
+// checkIdle resets the stream if it has been idle for longer than the idle
+// timeout of its listener, and otherwise checks again when it could expire.
+func (s *Stream) checkIdle() {
+	timeout := s.limiter.limits.IdleTimeout
+	idle := s.Idle()
+	if idle >= timeout {
+		log.Debugf("closing %s stream %d: idle for %s", s.Protocol, s.id, idle)
+		s.reset()
+		return
+	}
+
+	s.Registry.Lock()
+	if s.idleTimer != nil {
+		s.idleTimer.Reset(timeout - idle)
+	}
+	s.Registry.Unlock()
+}
+
 func (s *Stream) startStreaming() {
+	in := &meteredReader{r: s.Remote, s: s, count: &s.bytesIn, rate: s.limiter.in}
+	out := &meteredReader{r: s.Local, s: s, count: &s.bytesOut, rate: s.limiter.out}
+
 	go func() {
-		_, err := io.Copy(s.Local, s.Remote)
+		_, err := io.Copy(s.Local, in)
 		if err != nil {
 			s.reset()
 		} else {
@@ -51,7 +108,7 @@ func (s *Stream) startStreaming() {
 	}()
 
 	go func() {
-		_, err := io.Copy(s.Remote, s.Local)
+		_, err := io.Copy(s.Remote, out)
 		if err != nil {
 			s.reset()
 		} else {
@@ -71,7 +128,8 @@ type StreamRegistry struct {
 	ifconnmgr.ConnManager
 }
 
-// Register registers a stream to the registry
+// Register registers a stream to the registry. The stream must hold a slot
+// of its listener limiter, which is released when it is deregistered.
 func (r *StreamRegistry) Register(streamInfo *Stream) {
 	r.Lock()
 	defer r.Unlock()
@@ -83,6 +141,12 @@ func (r *StreamRegistry) Register(streamInfo *Stream) {
 	r.Streams[r.nextID] = streamInfo
 	r.nextID++
 
+	streamInfo.Started = time.Now()
+	streamInfo.touch()
+	if timeout := streamInfo.limiter.limits.IdleTimeout; timeout > 0 {
+		streamInfo.idleTimer = time.AfterFunc(timeout, streamInfo.checkIdle)
+	}
+
 	streamInfo.startStreaming()
 }
 
@@ -102,6 +166,12 @@ func (r *StreamRegistry) Deregister(streamID uint64) {
 		r.ConnManager.UntagPeer(p, cmgrTag)
 	}
 
+	if s.idleTimer != nil {
+		s.idleTimer.Stop()
+		s.idleTimer = nil
+	}
+	s.limiter.release()
+
 	delete(r.Streams, streamID)
 }
 
@@ -0,0 +1,213 @@
+package p2p
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr-net"
+)
+
+// Datagrams are forwarded over libp2p streams as frames made of a 2 bytes
+// big endian length followed by the datagram.
+const (
+	frameHeaderSize = 2
+	maxDatagramSize = 1<<16 - 1
+)
+
+// udpSessionTimeout is how long the stream forwarding the datagrams of a
+// local UDP client is kept open without traffic.
+var udpSessionTimeout = 2 * time.Minute
+
+// localNetAddr returns the network and address used to listen on or dial a
+// local multiaddr. On top of what manet supports, /unix/<path> addresses are
+// mapped to unix domain sockets.
+func localNetAddr(addr ma.Multiaddr) (string, string, error) {
+	if path, err := addr.ValueForProtocol(ma.P_UNIX); err == nil {
+		return "unix", path, nil
+	}
+	return manet.DialArgs(addr)
+}
+
+func isDatagram(network string) bool {
+	return strings.HasPrefix(network, "udp")
+}
+
+// dialLocal connects to a local service. Datagram services are wrapped so
+// that they can be forwarded over a stream. If reportRemote is set, the
+// given line is sent to the service first, as a datagram for datagram
+// services.
+func dialLocal(addr ma.Multiaddr, report string) (io.ReadWriteCloser, error) {
+	network, address, err := localNetAddr(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	if report != "" {
+		if _, err := io.WriteString(conn, report); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	if isDatagram(network) {
+		return &datagramConn{conn: conn}, nil
+	}
+	return conn, nil
+}
+
+// deframer splits the bytes written to it into frames, and hands out the
+// datagrams they contain.
+type deframer struct {
+	buf []byte
+}
+
+func (d *deframer) write(p []byte, send func([]byte) error) (int, error) {
+	d.buf = append(d.buf, p...)
+
+	off := 0
+	for len(d.buf)-off >= frameHeaderSize {
+		n := int(binary.BigEndian.Uint16(d.buf[off:]))
+		if len(d.buf)-off < frameHeaderSize+n {
+			break
+		}
+		off += frameHeaderSize
+		if err := send(d.buf[off : off+n]); err != nil {
+			return 0, err
+		}
+		off += n
+	}
+	d.buf = append(d.buf[:0], d.buf[off:]...)
+
+	return len(p), nil
+}
+
+func frame(datagram []byte) []byte {
+	framed := make([]byte, frameHeaderSize+len(datagram))
+	binary.BigEndian.PutUint16(framed, uint16(len(datagram)))
+	copy(framed[frameHeaderSize:], datagram)
+	return framed
+}
+
+// datagramConn adapts a connected datagram socket to a stream of frames.
+type datagramConn struct {
+	conn net.Conn
+
+	deframer
+	rbuf    []byte
+	pending []byte
+}
+
+func (c *datagramConn) Read(p []byte) (int, error) {
+	if len(c.pending) == 0 {
+		if c.rbuf == nil {
+			c.rbuf = make([]byte, frameHeaderSize+maxDatagramSize)
+		}
+		n, err := c.conn.Read(c.rbuf[frameHeaderSize:])
+		if err != nil {
+			return 0, err
+		}
+		binary.BigEndian.PutUint16(c.rbuf, uint16(n))
+		c.pending = c.rbuf[:frameHeaderSize+n]
+	}
+
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+func (c *datagramConn) Write(p []byte) (int, error) {
+	return c.write(p, func(datagram []byte) error {
+		if _, err := c.conn.Write(datagram); err != nil {
+			// Datagrams may get lost anyway, e.g. when the service
+			// isn't up yet.
+			log.Debugf("dropping datagram to %s: %s", c.conn.RemoteAddr(), err)
+		}
+		return nil
+	})
+}
+
+func (c *datagramConn) Close() error {
+	return c.conn.Close()
+}
+
+// udpSession is the stream of frames exchanged with one client of a local
+// UDP listener.
+type udpSession struct {
+	pc   net.PacketConn
+	addr net.Addr
+
+	deframer
+	in      chan []byte
+	pending []byte
+
+	idle      *time.Timer
+	closed    chan struct{}
+	closeOnce sync.Once
+	onClose   func()
+}
+
+func newUDPSession(pc net.PacketConn, addr net.Addr, onClose func()) *udpSession {
+	s := &udpSession{
+		pc:      pc,
+		addr:    addr,
+		in:      make(chan []byte, 64),
+		closed:  make(chan struct{}),
+		onClose: onClose,
+	}
+	s.idle = time.AfterFunc(udpSessionTimeout, func() { s.Close() })
+	return s
+}
+
+// deliver queues a datagram received from the client, dropping it if the
+// stream doesn't keep up.
+func (s *udpSession) deliver(datagram []byte) {
+	s.idle.Reset(udpSessionTimeout)
+	select {
+	case s.in <- frame(datagram):
+	default:
+		log.Debugf("dropping datagram from %s: queue full", s.addr)
+	}
+}
+
+func (s *udpSession) Read(p []byte) (int, error) {
+	if len(s.pending) == 0 {
+		select {
+		case s.pending = <-s.in:
+		case <-s.closed:
+			return 0, io.EOF
+		}
+	}
+
+	n := copy(p, s.pending)
+	s.pending = s.pending[n:]
+	return n, nil
+}
+
+func (s *udpSession) Write(p []byte) (int, error) {
+	return s.write(p, func(datagram []byte) error {
+		s.idle.Reset(udpSessionTimeout)
+		if _, err := s.pc.WriteTo(datagram, s.addr); err != nil {
+			log.Debugf("dropping datagram to %s: %s", s.addr, err)
+		}
+		return nil
+	})
+}
+
+func (s *udpSession) Close() error {
+	s.closeOnce.Do(func() {
+		s.idle.Stop()
+		close(s.closed)
+		s.onClose()
+	})
+	return nil
+}
@@ -0,0 +1,90 @@
+package p2p
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+func TestDeframer(t *testing.T) {
+	var d deframer
+	var got [][]byte
+	send := func(datagram []byte) error {
+		got = append(got, append([]byte(nil), datagram...))
+		return nil
+	}
+
+	stream := append(frame([]byte("hello")), frame([]byte("world!"))...)
+	stream = append(stream, frame(nil)...)
+
+	// Feed the frames one byte at a time.
+	for i := range stream {
+		if _, err := d.write(stream[i:i+1], send); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(got) != 3 || string(got[0]) != "hello" || string(got[1]) != "world!" || len(got[2]) != 0 {
+		t.Fatalf("unexpected datagrams %q", got)
+	}
+	if len(d.buf) != 0 {
+		t.Fatal("deframer holds leftover bytes")
+	}
+}
+
+func TestDialLocalUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	addr, err := ma.NewMultiaddr("/ip4/127.0.0.1/udp/" + portOf(t, pc.LocalAddr()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	local, err := dialLocal(addr, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer local.Close()
+
+	// Frames written to the stream side come out as datagrams...
+	if _, err := local.Write(frame([]byte("ping"))); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 64)
+	pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, from, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf[:n]) != "ping" {
+		t.Fatalf("unexpected datagram %q", buf[:n])
+	}
+
+	// ...and datagrams come out framed.
+	if _, err := pc.WriteTo([]byte("pong"), from); err != nil {
+		t.Fatal(err)
+	}
+	framed := make([]byte, frameHeaderSize+4)
+	if _, err := io.ReadFull(local, framed); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(framed, frame([]byte("pong"))) {
+		t.Fatalf("unexpected frame %q", framed)
+	}
+}
+
+func portOf(t *testing.T, addr net.Addr) string {
+	_, port, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return port
+}
@@ -0,0 +1,24 @@
+package plugin
+
+import (
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+// Command is a command tree added to the root command by a plugin.
+type Command struct {
+	Command *cmds.Command
+
+	// ReadOnly commands are also served by the read-only API of the gateway.
+	// They must not modify the node.
+	ReadOnly bool
+}
+
+// PluginCommands is an interface that can be implemented to add commands to
+// the CLI and the HTTP API.
+type PluginCommands interface {
+	Plugin
+
+	// Commands returns the commands to add, keyed by name. The names must not
+	// be taken by a built-in command or by another plugin.
+	Commands() (map[string]Command, error)
+}
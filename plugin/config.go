@@ -0,0 +1,48 @@
+package plugin
+
+// ConfigKey is the top-level config section holding the Config of plugins.
+const ConfigKey = "Plugins"
+
+// Config is the config section of plugins.
+type Config struct {
+	// NonFatal makes the loader skip the plugins failing to load,
+	// initialize, inject or start, rather than failing altogether.
+	NonFatal bool `json:",omitempty"`
+
+	// Plugins maps plugin names to their settings.
+	Plugins map[string]PluginConfig `json:",omitempty"`
+}
+
+// PluginConfig holds the settings of a plugin.
+type PluginConfig struct {
+	// Enabled is false to keep the plugin from being loaded. Plugins are
+	// enabled by default.
+	Enabled *bool `json:",omitempty"`
+
+	// Config is passed as-is to plugins implementing PluginConfigurable.
+	Config interface{} `json:",omitempty"`
+}
+
+// IsEnabled returns whether the plugin should be loaded.
+func (c PluginConfig) IsEnabled() bool {
+	return c.Enabled == nil || *c.Enabled
+}
+
+// Environment is what PluginConfigurable plugins are initialized with.
+type Environment struct {
+	// Repo is the path of the repo, it may not be initialized yet.
+	Repo string
+
+	// Config is the Config of the plugin, decoded from JSON. It is nil
+	// when the plugin has no settings.
+	Config interface{}
+}
+
+// PluginConfigurable is an interface that can be implemented by plugins
+// taking settings from the config file. The loader calls InitWithConfig
+// instead of Init for these plugins.
+type PluginConfigurable interface {
+	Plugin
+
+	InitWithConfig(env *Environment) error
+}
@@ -0,0 +1,17 @@
+package plugin
+
+import (
+	exchange "github.com/ipfs/go-ipfs-exchange-interface"
+	host "github.com/libp2p/go-libp2p-core/host"
+	routing "github.com/libp2p/go-libp2p-core/routing"
+)
+
+// PluginExchange is an interface that can be implemented to wrap or replace
+// the block exchange (Bitswap) of online nodes.
+type PluginExchange interface {
+	Plugin
+
+	// WrapExchange returns the exchange to use instead of exch. The host and
+	// routing of the node are given to build a replacement.
+	WrapExchange(exch exchange.Interface, h host.Host, rt routing.Routing) (exchange.Interface, error)
+}
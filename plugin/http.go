@@ -0,0 +1,41 @@
+package plugin
+
+import (
+	"net/http"
+
+	coreiface "github.com/ipfs/interface-go-ipfs-core"
+)
+
+// HTTPListener selects the HTTP listeners of the daemon serving a handler.
+type HTTPListener int
+
+const (
+	// HTTPAPI is the listener of the API.
+	HTTPAPI HTTPListener = 1 << iota
+	// HTTPGateway is the listener of the gateway.
+	HTTPGateway
+)
+
+// HTTPHandler is an HTTP endpoint added by a plugin.
+type HTTPHandler struct {
+	// Path is the path the handler is mounted at. A path ending with a slash
+	// mounts the handler on the whole subtree, as with http.ServeMux.
+	Path string
+
+	Handler http.Handler
+
+	// Listeners are the listeners serving the handler.
+	Listeners HTTPListener
+}
+
+// PluginHTTP is an interface that can be implemented to serve HTTP endpoints
+// on the API and gateway listeners of the daemon. Requests to the endpoints
+// are subject to the same origin checks and CORS headers as the built-in
+// endpoints of the listener.
+type PluginHTTP interface {
+	Plugin
+
+	// HTTPHandlers returns the handlers to mount. It is called once per
+	// listener, when the daemon starts serving it.
+	HTTPHandlers(api coreiface.CoreAPI) ([]HTTPHandler, error)
+}
@@ -17,7 +17,7 @@ func init() {
 	loadPluginsFunc = linuxLoadFunc
 }
 
-func linuxLoadFunc(pluginDir string) ([]iplugin.Plugin, error) {
+func linuxLoadFunc(pluginDir string, fail func(string, error) error) ([]iplugin.Plugin, error) {
 	var plugins []iplugin.Plugin
 
 	err := filepath.Walk(pluginDir, func(fi string, info os.FileInfo, err error) error {
@@ -42,7 +42,7 @@ func linuxLoadFunc(pluginDir string) ([]iplugin.Plugin, error) {
 		if newPlugins, err := loadPlugin(fi); err == nil {
 			plugins = append(plugins, newPlugins...)
 		} else {
-			return fmt.Errorf("loading plugin %s: %s", fi, err)
+			return fail(fi, fmt.Errorf("loading plugin %s: %s", fi, err))
 		}
 		return nil
 	})
@@ -1,14 +1,24 @@
 package loader
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 
 	coredag "github.com/ipfs/go-ipfs/core/coredag"
+	node "github.com/ipfs/go-ipfs/core/node"
+	libp2p "github.com/ipfs/go-ipfs/core/node/libp2p"
+	namesys "github.com/ipfs/go-ipfs/namesys"
 	plugin "github.com/ipfs/go-ipfs/plugin"
 	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
 
+	blocks "github.com/ipfs/go-block-format"
+	config "github.com/ipfs/go-ipfs-config"
+	serialize "github.com/ipfs/go-ipfs-config/serialize"
 	ipld "github.com/ipfs/go-ipld-format"
 	logging "github.com/ipfs/go-log"
 	coreiface "github.com/ipfs/interface-go-ipfs-core"
@@ -17,10 +27,42 @@ import (
 
 var log = logging.Logger("plugin/loader")
 
-var loadPluginsFunc = func(string) ([]plugin.Plugin, error) {
+// loadPluginsFunc loads the plugins of a directory. Files failing to load are
+// passed to fail, loading stops if it returns an error.
+var loadPluginsFunc = func(dir string, fail func(path string, err error) error) ([]plugin.Plugin, error) {
 	return nil, nil
 }
 
+// NonFatalEnv is the environment variable which, when set to true, makes the
+// loader skip the plugins failing to load, initialize, inject or start, rather
+// than failing altogether. It overrides the NonFatal setting of the Plugins
+// config section.
+const NonFatalEnv = "IPFS_PLUGINS_NONFATAL"
+
+// Plugin states, as reported by PluginLoader.Plugins.
+const (
+	PluginLoaded      = "loaded"
+	PluginDisabled    = "disabled"
+	PluginInitialized = "initialized"
+	PluginInjected    = "injected"
+	PluginStarted     = "started"
+	PluginClosed      = "closed"
+	PluginFailed      = "failed"
+)
+
+// PluginInfo describes a plugin known to the loader.
+type PluginInfo struct {
+	Name      string
+	Version   string
+	Preloaded bool
+	// Interfaces are the plugin interfaces the plugin implements, such as
+	// "Datastore" for plugin.PluginDatastore.
+	Interfaces []string
+	State      string
+	// Error is the error the plugin failed with.
+	Error string `json:",omitempty"`
+}
+
 type loaderState int
 
 const (
@@ -76,19 +118,84 @@ type PluginLoader struct {
 	state   loaderState
 	plugins map[string]plugin.Plugin
 	started []plugin.Plugin
+
+	commands map[string]plugin.Command
+
+	repo     string
+	config   plugin.Config
+	nonFatal bool
+
+	infoLk sync.Mutex
+	info   map[string]*PluginInfo
 }
 
 // NewPluginLoader creates new plugin loader
 func NewPluginLoader() (*PluginLoader, error) {
-	loader := &PluginLoader{plugins: make(map[string]plugin.Plugin, len(preloadPlugins))}
+	return newPluginLoader("", plugin.Config{})
+}
+
+// NewRepoPluginLoader creates a plugin loader configured by the Plugins
+// section of the config file of the repo at repoPath. Disabled plugins are
+// not loaded, configurable ones are initialized with their settings.
+func NewRepoPluginLoader(repoPath string) (*PluginLoader, error) {
+	cfg, err := readConfig(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	return newPluginLoader(repoPath, cfg)
+}
+
+func newPluginLoader(repoPath string, cfg plugin.Config) (*PluginLoader, error) {
+	nonFatal := cfg.NonFatal
+	if v, err := strconv.ParseBool(os.Getenv(NonFatalEnv)); err == nil {
+		nonFatal = v
+	}
+	loader := &PluginLoader{
+		plugins:  make(map[string]plugin.Plugin, len(preloadPlugins)),
+		repo:     repoPath,
+		config:   cfg,
+		nonFatal: nonFatal,
+		info:     make(map[string]*PluginInfo, len(preloadPlugins)),
+	}
 	for _, v := range preloadPlugins {
-		if err := loader.Load(v); err != nil {
+		if err := loader.load(v, true); err != nil {
 			return nil, err
 		}
 	}
 	return loader, nil
 }
 
+// readConfig reads the Plugins section of the config file of the repo at
+// repoPath. The repo doesn't need to be initialized.
+func readConfig(repoPath string) (plugin.Config, error) {
+	var cfg plugin.Config
+	fname, err := config.Filename(repoPath)
+	if err != nil {
+		return cfg, err
+	}
+
+	var mapconf map[string]interface{}
+	if err := serialize.ReadConfigFile(fname, &mapconf); err != nil {
+		if os.IsNotExist(err) {
+			return cfg, nil
+		}
+		return cfg, fmt.Errorf("reading the plugins config: %s", err)
+	}
+
+	raw, ok := mapconf[plugin.ConfigKey]
+	if !ok {
+		return cfg, nil
+	}
+	buf, err := json.Marshal(raw)
+	if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(buf, &cfg); err != nil {
+		return cfg, fmt.Errorf("failure to parse config section %s: %s", plugin.ConfigKey, err)
+	}
+	return cfg, nil
+}
+
 func (loader *PluginLoader) assertState(state loaderState) error {
 	if loader.state != state {
 		return fmt.Errorf("loader state must be %s, was %s", state, loader.state)
@@ -106,11 +213,20 @@ func (loader *PluginLoader) transition(from, to loaderState) error {
 
 // Load loads a plugin into the plugin loader.
 func (loader *PluginLoader) Load(pl plugin.Plugin) error {
+	return loader.load(pl, false)
+}
+
+func (loader *PluginLoader) load(pl plugin.Plugin, preloaded bool) error {
 	if err := loader.assertState(loaderLoading); err != nil {
 		return err
 	}
 
 	name := pl.Name()
+	if !loader.config.Plugins[name].IsEnabled() {
+		log.Infof("plugin %s is disabled, not loading it", name)
+		loader.addInfo(pl, preloaded, PluginDisabled)
+		return nil
+	}
 	if ppl, ok := loader.plugins[name]; ok {
 		// plugin is already loaded
 		return fmt.Errorf(
@@ -119,6 +235,65 @@ func (loader *PluginLoader) Load(pl plugin.Plugin) error {
 			name, ppl.Version(), pl.Version())
 	}
 	loader.plugins[name] = pl
+	loader.addInfo(pl, preloaded, PluginLoaded)
+	return nil
+}
+
+// Plugins describes the plugins known to the loader, sorted by name. It
+// includes the disabled plugins and the ones that failed.
+func (loader *PluginLoader) Plugins() []PluginInfo {
+	loader.infoLk.Lock()
+	defer loader.infoLk.Unlock()
+
+	infos := make([]PluginInfo, 0, len(loader.info))
+	for _, info := range loader.info {
+		infos = append(infos, *info)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+	return infos
+}
+
+func (loader *PluginLoader) addInfo(pl plugin.Plugin, preloaded bool, state string) {
+	loader.infoLk.Lock()
+	defer loader.infoLk.Unlock()
+	loader.info[pl.Name()] = &PluginInfo{
+		Name:       pl.Name(),
+		Version:    pl.Version(),
+		Preloaded:  preloaded,
+		Interfaces: pluginInterfaces(pl),
+		State:      state,
+	}
+}
+
+func (loader *PluginLoader) setState(name, state string) {
+	loader.infoLk.Lock()
+	defer loader.infoLk.Unlock()
+	if info, ok := loader.info[name]; ok {
+		info.State = state
+	}
+}
+
+// fail records the failure of the plugin name, or of the plugin file name when
+// it failed to load. It returns err, or nil and unloads the plugin when
+// failures are not fatal.
+func (loader *PluginLoader) fail(name string, err error) error {
+	loader.infoLk.Lock()
+	info, ok := loader.info[name]
+	if !ok {
+		info = &PluginInfo{Name: name}
+		loader.info[name] = info
+	}
+	info.State = PluginFailed
+	info.Error = err.Error()
+	loader.infoLk.Unlock()
+
+	if !loader.nonFatal {
+		return err
+	}
+	log.Errorf("plugin %s failed, skipping it: %s", name, err)
+	delete(loader.plugins, name)
 	return nil
 }
 
@@ -127,20 +302,23 @@ func (loader *PluginLoader) LoadDirectory(pluginDir string) error {
 	if err := loader.assertState(loaderLoading); err != nil {
 		return err
 	}
-	newPls, err := loadDynamicPlugins(pluginDir)
+	newPls, err := loadDynamicPlugins(pluginDir, loader.fail)
 	if err != nil {
 		return err
 	}
 
 	for _, pl := range newPls {
 		if err := loader.Load(pl); err != nil {
-			return err
+			if !loader.nonFatal {
+				return err
+			}
+			log.Errorf("skipping plugin: %s", err)
 		}
 	}
 	return nil
 }
 
-func loadDynamicPlugins(pluginDir string) ([]plugin.Plugin, error) {
+func loadDynamicPlugins(pluginDir string, fail func(string, error) error) ([]plugin.Plugin, error) {
 	_, err := os.Stat(pluginDir)
 	if os.IsNotExist(err) {
 		return nil, nil
@@ -149,7 +327,7 @@ func loadDynamicPlugins(pluginDir string) ([]plugin.Plugin, error) {
 		return nil, err
 	}
 
-	return loadPluginsFunc(pluginDir)
+	return loadPluginsFunc(pluginDir, fail)
 }
 
 // Initialize initializes all loaded plugins
@@ -157,12 +335,24 @@ func (loader *PluginLoader) Initialize() error {
 	if err := loader.transition(loaderLoading, loaderInitializing); err != nil {
 		return err
 	}
-	for _, p := range loader.plugins {
-		err := p.Init()
+	for name, p := range loader.plugins {
+		var err error
+		if p, ok := p.(plugin.PluginConfigurable); ok {
+			err = p.InitWithConfig(&plugin.Environment{
+				Repo:   loader.repo,
+				Config: loader.config.Plugins[name].Config,
+			})
+		} else {
+			err = p.Init()
+		}
 		if err != nil {
-			loader.state = loaderFailed
-			return err
+			if err := loader.fail(name, err); err != nil {
+				loader.state = loaderFailed
+				return err
+			}
+			continue
 		}
+		loader.setState(name, PluginInitialized)
 	}
 
 	return loader.transition(loaderInitializing, loaderInitialized)
@@ -174,31 +364,77 @@ func (loader *PluginLoader) Inject() error {
 		return err
 	}
 
-	for _, pl := range loader.plugins {
-		if pl, ok := pl.(plugin.PluginIPLD); ok {
-			err := injectIPLDPlugin(pl)
-			if err != nil {
+	for name, pl := range loader.plugins {
+		if err := loader.inject(pl); err != nil {
+			if err := loader.fail(name, err); err != nil {
 				loader.state = loaderFailed
 				return err
 			}
+			continue
 		}
-		if pl, ok := pl.(plugin.PluginTracer); ok {
-			err := injectTracerPlugin(pl)
-			if err != nil {
-				loader.state = loaderFailed
-				return err
-			}
+		loader.setState(name, PluginInjected)
+	}
+
+	return loader.transition(loaderInjecting, loaderInjected)
+}
+
+// inject hooks a plugin into the subsystems matching the interfaces it
+// implements. Whatever may fail is done before hooking the plugin anywhere,
+// so that a plugin failing to inject is not hooked into some subsystems only.
+func (loader *PluginLoader) inject(pl plugin.Plugin) error {
+	var hooks []func() error
+	prepare := func(hook func() error, err error) error {
+		if err != nil {
+			return err
 		}
-		if pl, ok := pl.(plugin.PluginDatastore); ok {
-			err := injectDatastorePlugin(pl)
-			if err != nil {
-				loader.state = loaderFailed
-				return err
-			}
+		hooks = append(hooks, hook)
+		return nil
+	}
+
+	if pl, ok := pl.(plugin.PluginIPLD); ok {
+		if err := prepare(prepareIPLDPlugin(pl)); err != nil {
+			return err
+		}
+	}
+	if pl, ok := pl.(plugin.PluginTracer); ok {
+		if err := prepare(prepareTracerPlugin(pl)); err != nil {
+			return err
+		}
+	}
+	if pl, ok := pl.(plugin.PluginDatastore); ok {
+		if err := prepare(prepareDatastorePlugin(pl)); err != nil {
+			return err
+		}
+	}
+	if pl, ok := pl.(plugin.PluginNamesys); ok {
+		if err := prepare(prepareNamesysPlugin(pl)); err != nil {
+			return err
+		}
+	}
+	if pl, ok := pl.(plugin.PluginRouting); ok {
+		if err := prepare(prepareRoutingPlugin(pl)); err != nil {
+			return err
+		}
+	}
+	if pl, ok := pl.(plugin.PluginExchange); ok {
+		hooks = append(hooks, func() error {
+			node.RegisterExchangeWrapper(pl.WrapExchange)
+			return nil
+		})
+	}
+	if pl, ok := pl.(plugin.PluginCommands); ok {
+		if err := prepare(loader.prepareCommandsPlugin(pl)); err != nil {
+			return err
 		}
 	}
 
-	return loader.transition(loaderInjecting, loaderInjected)
+	// The hooks were checked not to fail.
+	for _, hook := range hooks {
+		if err := hook(); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // Start starts all long-running plugins.
@@ -206,20 +442,41 @@ func (loader *PluginLoader) Start(iface coreiface.CoreAPI) error {
 	if err := loader.transition(loaderInjected, loaderStarting); err != nil {
 		return err
 	}
-	for _, pl := range loader.plugins {
+	for name, pl := range loader.plugins {
 		if pl, ok := pl.(plugin.PluginDaemon); ok {
 			err := pl.Start(iface)
 			if err != nil {
-				_ = loader.Close()
-				return err
+				if err := loader.fail(name, err); err != nil {
+					_ = loader.Close()
+					return err
+				}
+				continue
 			}
 			loader.started = append(loader.started, pl)
+			loader.setState(name, PluginStarted)
 		}
 	}
 
 	return loader.transition(loaderStarting, loaderStarted)
 }
 
+// Commands returns the commands added by the plugins, keyed by name. They
+// are collected when the plugins are injected.
+func (loader *PluginLoader) Commands() map[string]plugin.Command {
+	return loader.commands
+}
+
+// HTTPPlugins returns the loaded plugins serving HTTP endpoints.
+func (loader *PluginLoader) HTTPPlugins() []plugin.PluginHTTP {
+	var plugins []plugin.PluginHTTP
+	for _, pl := range loader.plugins {
+		if pl, ok := pl.(plugin.PluginHTTP); ok {
+			plugins = append(plugins, pl)
+		}
+	}
+	return plugins
+}
+
 // StopDaemon stops all long-running plugins.
 func (loader *PluginLoader) Close() error {
 	switch loader.state {
@@ -241,7 +498,9 @@ func (loader *PluginLoader) Close() error {
 					pl.Name(),
 					err.Error(),
 				))
+				continue
 			}
+			loader.setState(pl.Name(), PluginClosed)
 		}
 	}
 	if errs != nil {
@@ -252,23 +511,151 @@ func (loader *PluginLoader) Close() error {
 	return nil
 }
 
-func injectDatastorePlugin(pl plugin.PluginDatastore) error {
-	return fsrepo.AddDatastoreConfigHandler(pl.DatastoreTypeName(), pl.DatastoreConfigParser())
+// pluginInterfaces returns the names of the plugin interfaces pl implements.
+func pluginInterfaces(pl plugin.Plugin) []string {
+	var ifaces []string
+	if _, ok := pl.(plugin.PluginIPLD); ok {
+		ifaces = append(ifaces, "IPLD")
+	}
+	if _, ok := pl.(plugin.PluginTracer); ok {
+		ifaces = append(ifaces, "Tracer")
+	}
+	if _, ok := pl.(plugin.PluginDatastore); ok {
+		ifaces = append(ifaces, "Datastore")
+	}
+	if _, ok := pl.(plugin.PluginNamesys); ok {
+		ifaces = append(ifaces, "Namesys")
+	}
+	if _, ok := pl.(plugin.PluginRouting); ok {
+		ifaces = append(ifaces, "Routing")
+	}
+	if _, ok := pl.(plugin.PluginExchange); ok {
+		ifaces = append(ifaces, "Exchange")
+	}
+	if _, ok := pl.(plugin.PluginCommands); ok {
+		ifaces = append(ifaces, "Commands")
+	}
+	if _, ok := pl.(plugin.PluginHTTP); ok {
+		ifaces = append(ifaces, "HTTP")
+	}
+	if _, ok := pl.(plugin.PluginDaemon); ok {
+		ifaces = append(ifaces, "Daemon")
+	}
+	if _, ok := pl.(plugin.PluginConfigurable); ok {
+		ifaces = append(ifaces, "Configurable")
+	}
+	return ifaces
 }
 
-func injectIPLDPlugin(pl plugin.PluginIPLD) error {
-	err := pl.RegisterBlockDecoders(ipld.DefaultBlockDecoder)
+func prepareDatastorePlugin(pl plugin.PluginDatastore) (func() error, error) {
+	name := pl.DatastoreTypeName()
+	if fsrepo.HasDatastoreConfigHandler(name) {
+		return nil, fmt.Errorf("already have a datastore named %q", name)
+	}
+	return func() error {
+		return fsrepo.AddDatastoreConfigHandler(name, pl.DatastoreConfigParser())
+	}, nil
+}
+
+func prepareRoutingPlugin(pl plugin.PluginRouting) (func() error, error) {
+	name := pl.RoutingTypeName()
+	if _, ok := libp2p.NamedRoutingOption(name); ok {
+		return nil, fmt.Errorf("already have a routing option named %q", name)
+	}
+	return func() error {
+		return libp2p.RegisterRoutingOption(name, pl.RoutingOption())
+	}, nil
+}
+
+func prepareNamesysPlugin(pl plugin.PluginNamesys) (func() error, error) {
+	resolvers, err := pl.NameResolvers()
 	if err != nil {
-		return err
+		return nil, err
+	}
+	for pattern := range resolvers {
+		if err := namesys.CheckResolver(pattern); err != nil {
+			return nil, err
+		}
 	}
-	return pl.RegisterInputEncParsers(coredag.DefaultInputEncParsers)
+	return func() error {
+		for pattern, r := range resolvers {
+			if err := namesys.RegisterResolver(pattern, r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, nil
+}
+
+func (loader *PluginLoader) prepareCommandsPlugin(pl plugin.PluginCommands) (func() error, error) {
+	commands, err := pl.Commands()
+	if err != nil {
+		return nil, err
+	}
+	for name, c := range commands {
+		if c.Command == nil {
+			return nil, fmt.Errorf("plugin %s: command %s is nil", pl.Name(), name)
+		}
+		if _, ok := loader.commands[name]; ok {
+			return nil, fmt.Errorf("plugin %s: command %s is already added by another plugin", pl.Name(), name)
+		}
+	}
+	return func() error {
+		if loader.commands == nil {
+			loader.commands = make(map[string]plugin.Command, len(commands))
+		}
+		for name, c := range commands {
+			loader.commands[name] = c
+		}
+		return nil
+	}, nil
 }
 
-func injectTracerPlugin(pl plugin.PluginTracer) error {
+// stagedBlockDecoder records the block decoders registered by a plugin, to
+// register them with the default one once the plugin is known not to fail.
+type stagedBlockDecoder map[uint64]ipld.DecodeBlockFunc
+
+func (d stagedBlockDecoder) Register(codec uint64, decoder ipld.DecodeBlockFunc) {
+	d[codec] = decoder
+}
+
+func (d stagedBlockDecoder) Decode(blk blocks.Block) (ipld.Node, error) {
+	decoder, ok := d[blk.Cid().Type()]
+	if !ok {
+		return nil, fmt.Errorf("unrecognized object type: %d", blk.Cid().Type())
+	}
+	return decoder(blk)
+}
+
+func prepareIPLDPlugin(pl plugin.PluginIPLD) (func() error, error) {
+	decoders := make(stagedBlockDecoder)
+	if err := pl.RegisterBlockDecoders(decoders); err != nil {
+		return nil, err
+	}
+	parsers := make(coredag.InputEncParsers)
+	if err := pl.RegisterInputEncParsers(parsers); err != nil {
+		return nil, err
+	}
+	return func() error {
+		for codec, decoder := range decoders {
+			ipld.DefaultBlockDecoder.Register(codec, decoder)
+		}
+		for ienc, formats := range parsers {
+			for format, parser := range formats {
+				coredag.DefaultInputEncParsers.AddParser(ienc, format, parser)
+			}
+		}
+		return nil
+	}, nil
+}
+
+func prepareTracerPlugin(pl plugin.PluginTracer) (func() error, error) {
 	tracer, err := pl.InitTracer()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	opentracing.SetGlobalTracer(tracer)
-	return nil
+	return func() error {
+		opentracing.SetGlobalTracer(tracer)
+		return nil
+	}, nil
 }
@@ -0,0 +1,169 @@
+package loader
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+
+	libp2p "github.com/ipfs/go-ipfs/core/node/libp2p"
+	plugin "github.com/ipfs/go-ipfs/plugin"
+)
+
+type configurablePlugin struct {
+	name string
+	env  *plugin.Environment
+}
+
+func (p *configurablePlugin) Name() string    { return p.name }
+func (p *configurablePlugin) Version() string { return "0.0.1" }
+func (p *configurablePlugin) Init() error     { panic("Init called on a configurable plugin") }
+
+func (p *configurablePlugin) InitWithConfig(env *plugin.Environment) error {
+	p.env = env
+	return nil
+}
+
+func writeRepoConfig(t *testing.T, config string) string {
+	repo, err := ioutil.TempDir("", "plugin-loader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(repo, "config"), []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return repo
+}
+
+func TestInitWithConfig(t *testing.T) {
+	repo := writeRepoConfig(t, `{
+		"Plugins": {
+			"Plugins": {
+				"configured": {"Config": {"Path": "somewhere", "Size": 3}},
+				"disabled": {"Enabled": false}
+			}
+		}
+	}`)
+	defer os.RemoveAll(repo)
+
+	loader, err := NewRepoPluginLoader(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	configured := &configurablePlugin{name: "configured"}
+	unconfigured := &configurablePlugin{name: "unconfigured"}
+	disabled := &configurablePlugin{name: "disabled"}
+	for _, pl := range []plugin.Plugin{configured, unconfigured, disabled} {
+		if err := loader.Load(pl); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := loader.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+
+	if configured.env == nil {
+		t.Fatal("InitWithConfig wasn't called")
+	}
+	if configured.env.Repo != repo {
+		t.Errorf("expected repo %s, got %s", repo, configured.env.Repo)
+	}
+	expected := map[string]interface{}{"Path": "somewhere", "Size": float64(3)}
+	if !reflect.DeepEqual(configured.env.Config, expected) {
+		t.Errorf("expected config %v, got %v", expected, configured.env.Config)
+	}
+
+	if unconfigured.env == nil {
+		t.Fatal("InitWithConfig wasn't called without settings")
+	}
+	if unconfigured.env.Config != nil {
+		t.Errorf("expected no config, got %v", unconfigured.env.Config)
+	}
+
+	if disabled.env != nil {
+		t.Error("disabled plugin was initialized")
+	}
+	for _, info := range loader.Plugins() {
+		if info.Name == "disabled" && info.State != PluginDisabled {
+			t.Errorf("expected the disabled plugin to be %s, got %s", PluginDisabled, info.State)
+		}
+	}
+}
+
+func TestReadConfig(t *testing.T) {
+	repo, err := ioutil.TempDir("", "plugin-loader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(repo)
+
+	// A repo without config has no plugin settings.
+	cfg, err := readConfig(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg, plugin.Config{}) {
+		t.Fatalf("expected no config, got %v", cfg)
+	}
+
+	if runtime.GOOS == "windows" || os.Geteuid() == 0 {
+		t.Skip("file permissions don't apply")
+	}
+	cfgFile := filepath.Join(repo, "config")
+	if err := ioutil.WriteFile(cfgFile, []byte(`{"Plugins": {}}`), 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readConfig(repo); err == nil {
+		t.Fatal("expected an unreadable config to fail")
+	}
+}
+
+// conflictingPlugin adds a routing option, and an invalid command. Commands
+// are injected after routing options.
+type conflictingPlugin struct{}
+
+func (conflictingPlugin) Name() string    { return "conflicting" }
+func (conflictingPlugin) Version() string { return "0.0.1" }
+func (conflictingPlugin) Init() error     { return nil }
+
+func (conflictingPlugin) RoutingTypeName() string { return "conflicting-routing" }
+func (conflictingPlugin) RoutingOption() libp2p.RoutingOption {
+	return libp2p.NilRouterOption
+}
+
+func (conflictingPlugin) Commands() (map[string]plugin.Command, error) {
+	return map[string]plugin.Command{"conflicting": {}}, nil
+}
+
+func TestInjectNonFatal(t *testing.T) {
+	os.Unsetenv(NonFatalEnv)
+	loader, err := newPluginLoader("", plugin.Config{NonFatal: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := loader.Load(conflictingPlugin{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := loader.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	if err := loader.Inject(); err != nil {
+		t.Fatalf("a failing plugin should be skipped in non-fatal mode: %s", err)
+	}
+
+	for _, info := range loader.Plugins() {
+		if info.Name == "conflicting" && info.State != PluginFailed {
+			t.Errorf("expected the plugin to be %s, got %s", PluginFailed, info.State)
+		}
+	}
+	// The plugin failed on its command, its routing option isn't registered
+	// either.
+	if _, ok := libp2p.NamedRoutingOption("conflicting-routing"); ok {
+		t.Error("the failed plugin is partly injected")
+	}
+	if _, ok := loader.Commands()["conflicting"]; ok {
+		t.Error("the command of the failed plugin is added")
+	}
+}
@@ -0,0 +1,15 @@
+package plugin
+
+import (
+	"github.com/ipfs/go-ipfs/namesys"
+)
+
+// PluginNamesys is an interface that can be implemented to add resolvers for
+// custom naming schemes to the name system.
+type PluginNamesys interface {
+	Plugin
+
+	// NameResolvers returns the resolvers to add, keyed by the pattern of
+	// the names they claim (see namesys.RegisterResolver).
+	NameResolvers() (map[string]namesys.NameResolver, error)
+}
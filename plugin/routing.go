@@ -0,0 +1,15 @@
+package plugin
+
+import (
+	"github.com/ipfs/go-ipfs/core/node/libp2p"
+)
+
+// PluginRouting is an interface that can be implemented to add routing
+// options, selected by name with the Routing.Type config or the --routing
+// flag of the daemon.
+type PluginRouting interface {
+	Plugin
+
+	RoutingTypeName() string
+	RoutingOption() libp2p.RoutingOption
+}
@@ -0,0 +1,39 @@
+package repo
+
+import (
+	"encoding/json"
+)
+
+// ConfigGetter reads the raw value of a config key, like Repo.GetConfigKey.
+type ConfigGetter interface {
+	GetConfigKey(key string) (interface{}, error)
+}
+
+// ConfigGetterFunc adapts a function to a ConfigGetter.
+type ConfigGetterFunc func(key string) (interface{}, error)
+
+// GetConfigKey implements ConfigGetter.
+func (f ConfigGetterFunc) GetConfigKey(key string) (interface{}, error) {
+	return f(key)
+}
+
+// ConfigSection decodes the value stored under the given key of the config
+// file into v, which should be a pointer. It is meant for top-level config
+// sections that go-ipfs-config doesn't know about: these are preserved in the
+// config file as-is but are not part of the config struct.
+//
+// v is left untouched if the key is not set.
+func ConfigSection(r ConfigGetter, key string, v interface{}) error {
+	raw, err := r.GetConfigKey(key)
+	if err != nil {
+		// GetConfigKey fails when the key is not set, which simply means
+		// that the section wasn't configured.
+		return nil
+	}
+
+	buf, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(buf, v)
+}
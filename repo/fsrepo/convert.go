@@ -0,0 +1,444 @@
+package fsrepo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	repo "github.com/ipfs/go-ipfs/repo"
+	dir "github.com/ipfs/go-ipfs/thirdparty/dir"
+
+	ds "github.com/ipfs/go-datastore"
+	dsquery "github.com/ipfs/go-datastore/query"
+	lockfile "github.com/ipfs/go-fs-lock"
+	config "github.com/ipfs/go-ipfs-config"
+	serialize "github.com/ipfs/go-ipfs-config/serialize"
+)
+
+const (
+	// convertDir is where the new datastore is built during a conversion,
+	// relative to the repo.
+	convertDir = "datastore-convert"
+	// ConvertBackupDir is where the previous datastore is moved once a
+	// conversion is done, relative to the repo.
+	ConvertBackupDir = "datastore-backup"
+
+	convertCheckpointFn = "checkpoint.json"
+	convertBatchSize    = 1024
+)
+
+// ConvertProgress reports the progress of a datastore conversion.
+type ConvertProgress struct {
+	// Copied is the number of keys copied so far.
+	Copied uint64
+	// Resumed is the number of keys copied by a previous, interrupted
+	// conversion.
+	Resumed uint64
+	// Total is the number of keys of the datastore.
+	Total uint64
+}
+
+// convertCheckpoint records a conversion in progress, so that it can be
+// resumed.
+type convertCheckpoint struct {
+	// Spec is the disk spec of the new datastore.
+	Spec   string
+	Copied uint64
+	// Swap is set once all keys are copied, while the datastores are
+	// swapped.
+	Swap *convertSwap `json:",omitempty"`
+}
+
+// convertSwap records what a swap of the datastores does, so that an
+// interrupted swap can be finished.
+type convertSwap struct {
+	// Config is the Datastore.Spec config of the new datastore.
+	Config map[string]interface{}
+	// OldPaths and NewPaths are the paths of the previous and the new
+	// datastores, relative to the repo.
+	OldPaths []string
+	NewPaths []string
+}
+
+// ErrConvertSwap is returned when opening a repo whose datastores were being
+// swapped by an interrupted conversion.
+var ErrConvertSwap = errors.New("the datastore conversion of this repo was interrupted, run 'ipfs repo convert' again with the same target to finish it")
+
+// Convert copies all the keys of the datastore of the repo at repoPath into a
+// new datastore built from spec, checks that both hold the same number of
+// keys, then switches the repo to the new datastore. The previous datastore is
+// moved to ConvertBackupDir, whose path is returned.
+//
+// The conversion is checkpointed: calling Convert again with the same spec
+// after an interruption resumes it. This includes the swap of the datastores,
+// during which the repo can't be opened. The repo must not be in use.
+func Convert(repoPath string, spec map[string]interface{}, progress func(ConvertProgress)) (string, error) {
+	packageLock.Lock()
+	defer packageLock.Unlock()
+
+	r, err := newFSRepo(repoPath)
+	if err != nil {
+		return "", err
+	}
+	if err := checkInitialized(r.path); err != nil {
+		return "", err
+	}
+
+	lock, err := lockfile.Lock(r.path, LockFile)
+	if err != nil {
+		return "", err
+	}
+	defer lock.Close()
+
+	if err := checkVersion(r.path); err != nil {
+		return "", err
+	}
+
+	newDsc, err := AnyDatastoreConfig(spec)
+	if err != nil {
+		return "", fmt.Errorf("invalid datastore spec: %s", err)
+	}
+	newSpec := newDsc.DiskSpec()
+
+	// The config and the datastore may not match while the datastores are
+	// swapped: finish an interrupted swap before looking at them.
+	staging := filepath.Join(r.path, convertDir)
+	backup := filepath.Join(r.path, ConvertBackupDir)
+	cp, err := readCheckpoint(staging)
+	if err != nil {
+		return "", err
+	}
+	if cp != nil && cp.Swap != nil {
+		if cp.Spec != newSpec.String() {
+			return "", fmt.Errorf("an interrupted conversion to '%s' is being finished in %s, run the conversion to that datastore again", cp.Spec, staging)
+		}
+		return backup, finishSwap(r.path, staging, backup, cp)
+	}
+
+	cfgFile, err := config.Filename(r.path)
+	if err != nil {
+		return "", err
+	}
+	cfg, err := serialize.Load(cfgFile)
+	if err != nil {
+		return "", err
+	}
+
+	oldDsc, err := AnyDatastoreConfig(cfg.Datastore.Spec)
+	if err != nil {
+		return "", err
+	}
+	oldSpec := oldDsc.DiskSpec()
+
+	onDisk, err := r.readSpec()
+	if err != nil {
+		return "", err
+	}
+	if onDisk != oldSpec.String() {
+		return "", fmt.Errorf("datastore configuration of '%s' does not match what is on disk '%s'",
+			oldSpec.String(), onDisk)
+	}
+	if newSpec.String() == oldSpec.String() {
+		return "", errors.New("the repo already uses this datastore")
+	}
+
+	newPaths := specPaths(newSpec)
+	for _, p := range newPaths {
+		if filepath.IsAbs(p) {
+			return "", fmt.Errorf("datastore path %s must be relative to the repo", p)
+		}
+	}
+
+	if _, err := os.Stat(backup); err == nil {
+		return "", fmt.Errorf("%s already exists, remove the backup of the previous conversion first", backup)
+	}
+
+	if err := startCheckpoint(staging, newSpec.String()); err != nil {
+		return "", err
+	}
+
+	if err := convertCopy(r.path, staging, oldDsc, newDsc, newSpec.String(), progress); err != nil {
+		return "", err
+	}
+
+	// Record the swap before starting it: from then on, the repo can't be
+	// opened until it is finished.
+	cp, err = readCheckpoint(staging)
+	if err != nil {
+		return "", err
+	}
+	cp.Swap = &convertSwap{
+		Config:   spec,
+		OldPaths: specPaths(oldSpec),
+		NewPaths: newPaths,
+	}
+	if err := saveCheckpoint(staging, *cp); err != nil {
+		return "", err
+	}
+
+	return backup, finishSwap(r.path, staging, backup, cp)
+}
+
+// finishSwap moves the previous datastore to backup and the new one from
+// staging to the repo, then switches the config and the datastore spec to
+// the new datastore. Every step can be run again, so that a swap interrupted
+// at any point can be finished. The checkpoint is only removed at the end.
+func finishSwap(repoPath, staging, backup string, cp *convertCheckpoint) error {
+	if err := os.MkdirAll(backup, 0755); err != nil {
+		return err
+	}
+
+	// The new datastore may reuse the paths of the previous one: all of the
+	// previous datastore is moved away before any of the new one is moved in,
+	// so a path found in the backup was moved already.
+	for _, p := range cp.Swap.OldPaths {
+		if _, err := os.Stat(filepath.Join(backup, p)); err == nil {
+			continue
+		}
+		if err := moveDatastorePath(repoPath, backup, p); err != nil {
+			return err
+		}
+	}
+	for _, p := range cp.Swap.NewPaths {
+		if err := moveDatastorePath(staging, repoPath, p); err != nil {
+			return err
+		}
+	}
+	if err := dir.SyncDir(repoPath); err != nil {
+		return err
+	}
+
+	cfgFile, err := config.Filename(repoPath)
+	if err != nil {
+		return err
+	}
+	var mapconf map[string]interface{}
+	if err := serialize.ReadConfigFile(cfgFile, &mapconf); err != nil {
+		return err
+	}
+	dsconf, ok := mapconf["Datastore"].(map[string]interface{})
+	if !ok {
+		return errors.New("invalid Datastore config")
+	}
+	dsconf["Spec"] = cp.Swap.Config
+	data, err := config.Marshal(mapconf)
+	if err != nil {
+		return err
+	}
+	if err := dir.WriteFileAtomic(cfgFile, data, 0660); err != nil {
+		return err
+	}
+
+	specFile, err := config.Path(repoPath, specFn)
+	if err != nil {
+		return err
+	}
+	if err := dir.WriteFileAtomic(specFile, []byte(cp.Spec), 0600); err != nil {
+		return err
+	}
+
+	return os.RemoveAll(staging)
+}
+
+// convertCopy copies the keys of the datastore of the repo to the datastore
+// being built in staging, skipping the ones copied already.
+func convertCopy(repoPath, staging string, oldDsc, newDsc DatastoreConfig, spec string, progress func(ConvertProgress)) error {
+	src, err := oldDsc.Create(repoPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := newDsc.Create(staging)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	total, err := countKeys(src)
+	if err != nil {
+		return err
+	}
+
+	p := ConvertProgress{Total: total}
+	progress(p)
+
+	results, err := src.Query(dsquery.Query{})
+	if err != nil {
+		return err
+	}
+	defer results.Close()
+
+	batch, err := dst.Batch()
+	if err != nil {
+		return err
+	}
+	pending := 0
+	commit := func() error {
+		if err := batch.Commit(); err != nil {
+			return err
+		}
+		if batch, err = dst.Batch(); err != nil {
+			return err
+		}
+		pending = 0
+		progress(p)
+		return saveCheckpoint(staging, convertCheckpoint{Spec: spec, Copied: p.Copied + p.Resumed})
+	}
+
+	for result := range results.Next() {
+		if result.Error != nil {
+			return result.Error
+		}
+
+		key := ds.NewKey(result.Key)
+		has, err := dst.Has(key)
+		if err != nil {
+			return err
+		}
+		if has {
+			p.Resumed++
+			continue
+		}
+
+		if err := batch.Put(key, result.Value); err != nil {
+			return err
+		}
+		p.Copied++
+		pending++
+		if pending >= convertBatchSize {
+			if err := commit(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := commit(); err != nil {
+		return err
+	}
+
+	copied, err := countKeys(dst)
+	if err != nil {
+		return err
+	}
+	if copied != total {
+		return fmt.Errorf("the new datastore holds %d keys instead of %d", copied, total)
+	}
+	return nil
+}
+
+func countKeys(d repo.Datastore) (uint64, error) {
+	results, err := d.Query(dsquery.Query{KeysOnly: true})
+	if err != nil {
+		return 0, err
+	}
+	defer results.Close()
+
+	var n uint64
+	for result := range results.Next() {
+		if result.Error != nil {
+			return 0, result.Error
+		}
+		n++
+	}
+	return n, nil
+}
+
+// readCheckpoint returns the checkpoint of the conversion in staging, or nil
+// if there is none.
+func readCheckpoint(staging string) (*convertCheckpoint, error) {
+	data, err := ioutil.ReadFile(filepath.Join(staging, convertCheckpointFn))
+	switch {
+	case err == nil:
+	case os.IsNotExist(err):
+		return nil, nil
+	default:
+		return nil, err
+	}
+
+	cp := new(convertCheckpoint)
+	if err := json.Unmarshal(data, cp); err != nil {
+		return nil, fmt.Errorf("invalid conversion checkpoint: %s", err)
+	}
+	return cp, nil
+}
+
+// checkConvertSwap returns ErrConvertSwap if the datastores of the repo are
+// being swapped.
+func checkConvertSwap(repoPath string) error {
+	cp, err := readCheckpoint(filepath.Join(repoPath, convertDir))
+	if err != nil {
+		return err
+	}
+	if cp != nil && cp.Swap != nil {
+		return ErrConvertSwap
+	}
+	return nil
+}
+
+// startCheckpoint prepares the staging directory of a conversion to spec,
+// unless it is resumed.
+func startCheckpoint(staging, spec string) error {
+	cp, err := readCheckpoint(staging)
+	if err != nil {
+		return err
+	}
+	if cp != nil {
+		if cp.Spec != spec {
+			return fmt.Errorf("an interrupted conversion to '%s' is in %s, remove it to convert to another datastore", cp.Spec, staging)
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(staging, 0755); err != nil {
+		return err
+	}
+	return saveCheckpoint(staging, convertCheckpoint{Spec: spec})
+}
+
+func saveCheckpoint(staging string, cp convertCheckpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	return dir.WriteFileAtomic(filepath.Join(staging, convertCheckpointFn), data, 0600)
+}
+
+// moveDatastorePath moves the datastore directory or file at path from one
+// directory to another, if it exists.
+func moveDatastorePath(from, to, path string) error {
+	src := filepath.Join(from, path)
+	if _, err := os.Stat(src); os.IsNotExist(err) {
+		return nil
+	}
+
+	dst := filepath.Join(to, path)
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	return os.Rename(src, dst)
+}
+
+// specPaths returns the paths of the datastores of a disk spec.
+func specPaths(spec interface{}) []string {
+	var paths []string
+	switch s := spec.(type) {
+	case DiskSpec:
+		return specPaths(map[string]interface{}(s))
+	case map[string]interface{}:
+		for k, v := range s {
+			if p, ok := v.(string); ok && k == "path" {
+				paths = append(paths, p)
+				continue
+			}
+			paths = append(paths, specPaths(v)...)
+		}
+	case []interface{}:
+		for _, v := range s {
+			paths = append(paths, specPaths(v)...)
+		}
+	}
+	return paths
+}
@@ -0,0 +1,163 @@
+package fsrepo_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/go-ipfs/repo/fsrepo"
+
+	datastore "github.com/ipfs/go-datastore"
+	config "github.com/ipfs/go-ipfs-config"
+)
+
+// The datastore plugins are injected by TestDefaultDatastoreConfig.
+
+var leveldbSpec = map[string]interface{}{
+	"type":        "levelds",
+	"path":        "leveldb",
+	"compression": "none",
+}
+
+func initConvertRepo(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "ipfs-convert-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fsrepo.Init(dir, &config.Config{Datastore: config.DefaultDatastoreConfig()}); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := fsrepo.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	for _, k := range []string{"/foo", "/bar/baz", "/blocks/CIQBLOCK"} {
+		if err := r.Datastore().Put(datastore.NewKey(k), []byte(k)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestConvert(t *testing.T) {
+	dir := initConvertRepo(t)
+	defer os.RemoveAll(dir)
+
+	var last fsrepo.ConvertProgress
+	backup, err := fsrepo.Convert(dir, leveldbSpec, func(p fsrepo.ConvertProgress) { last = p })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last.Copied != last.Total || last.Total < 3 {
+		t.Errorf("unexpected progress %+v", last)
+	}
+
+	for _, p := range []string{"blocks", "datastore"} {
+		if _, err := os.Stat(filepath.Join(backup, p)); err != nil {
+			t.Errorf("previous datastore not backed up: %s", err)
+		}
+	}
+
+	r, err := fsrepo.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	for _, k := range []string{"/foo", "/bar/baz", "/blocks/CIQBLOCK"} {
+		v, err := r.Datastore().Get(datastore.NewKey(k))
+		if err != nil {
+			t.Fatalf("%s: %s", k, err)
+		}
+		if string(v) != k {
+			t.Errorf("%s: got %q", k, v)
+		}
+	}
+}
+
+func TestConvertSameSpec(t *testing.T) {
+	dir := initConvertRepo(t)
+	defer os.RemoveAll(dir)
+
+	_, err := fsrepo.Convert(dir, config.DefaultDatastoreConfig().Spec, func(fsrepo.ConvertProgress) {})
+	if err == nil {
+		t.Fatal("expected converting to the current datastore to fail")
+	}
+}
+
+func TestConvertOtherCheckpoint(t *testing.T) {
+	dir := initConvertRepo(t)
+	defer os.RemoveAll(dir)
+
+	staging := filepath.Join(dir, "datastore-convert")
+	if err := os.Mkdir(staging, 0755); err != nil {
+		t.Fatal(err)
+	}
+	err := ioutil.WriteFile(filepath.Join(staging, "checkpoint.json"), []byte(`{"Spec":"{}","Copied":1}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = fsrepo.Convert(dir, leveldbSpec, func(fsrepo.ConvertProgress) {})
+	if err == nil || !strings.Contains(err.Error(), "interrupted conversion") {
+		t.Fatalf("expected the conversion to be refused, got %v", err)
+	}
+}
+
+func TestConvertInterruptedSwap(t *testing.T) {
+	dir := initConvertRepo(t)
+	defer os.RemoveAll(dir)
+
+	// Make moving the new datastore into the repo fail, once the previous
+	// one has been moved to the backup.
+	obstacle := filepath.Join(dir, "leveldb", "obstacle")
+	if err := os.MkdirAll(obstacle, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fsrepo.Convert(dir, leveldbSpec, func(fsrepo.ConvertProgress) {}); err == nil {
+		t.Fatal("expected the swap to fail")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "datastore-backup", "blocks")); err != nil {
+		t.Fatalf("expected the previous datastore to be moved already: %s", err)
+	}
+
+	if _, err := fsrepo.Open(dir); err != fsrepo.ErrConvertSwap {
+		t.Fatalf("expected ErrConvertSwap opening the repo, got %v", err)
+	}
+	_, err := fsrepo.Convert(dir, config.DefaultDatastoreConfig().Spec, func(fsrepo.ConvertProgress) {})
+	if err == nil || !strings.Contains(err.Error(), "interrupted conversion") {
+		t.Fatalf("expected converting to another datastore to be refused, got %v", err)
+	}
+
+	// Running the conversion again finishes the swap.
+	if err := os.RemoveAll(filepath.Join(dir, "leveldb")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fsrepo.Convert(dir, leveldbSpec, func(fsrepo.ConvertProgress) {}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "datastore-convert")); !os.IsNotExist(err) {
+		t.Fatalf("expected the staging directory to be removed, got %v", err)
+	}
+
+	r, err := fsrepo.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	for _, k := range []string{"/foo", "/bar/baz", "/blocks/CIQBLOCK"} {
+		v, err := r.Datastore().Get(datastore.NewKey(k))
+		if err != nil {
+			t.Fatalf("%s: %s", k, err)
+		}
+		if string(v) != k {
+			t.Errorf("%s: got %q", k, v)
+		}
+	}
+}
@@ -64,6 +64,13 @@ func init() {
 	}
 }
 
+// HasDatastoreConfigHandler returns whether a handler is added for the
+// datastores named name.
+func HasDatastoreConfigHandler(name string) bool {
+	_, ok := datastores[name]
+	return ok
+}
+
 func AddDatastoreConfigHandler(name string, dsc ConfigFromMap) error {
 	_, ok := datastores[name]
 	if ok {
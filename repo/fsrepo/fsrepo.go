@@ -35,6 +35,21 @@ const LockFile = "repo.lock"
 
 var log = logging.Logger("fsrepo")
 
+// EnvKeystorePassphrase is the environment variable holding the passphrase
+// used to unlock an encrypted keystore when opening the repo.
+const EnvKeystorePassphrase = "IPFS_KEYSTORE_PASSPHRASE"
+
+// KeystoreConfig is the top-level "Keystore" config section.
+type KeystoreConfig struct {
+	// Type is the keystore implementation: "fs" (default), "encrypted" or
+	// "remote".
+	Type string
+
+	// Socket is the unix socket of the signer holding the keys of a
+	// "remote" keystore, relative to the repo if not absolute.
+	Socket string
+}
+
 // version number that we are currently expecting to see
 var RepoVersion = 7
 
@@ -143,19 +158,13 @@ func open(repoPath string) (repo.Repo, error) {
 	}()
 
 	// Check version, and error out if not matching
-	ver, err := mfsr.RepoPath(r.path).Version()
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, ErrNoVersion
-		}
+	if err := checkVersion(r.path); err != nil {
 		return nil, err
 	}
 
-	if RepoVersion > ver {
-		return nil, ErrNeedMigration
-	} else if ver > RepoVersion {
-		// program version too low for existing repo
-		return nil, fmt.Errorf(programTooLowMessage, RepoVersion, ver)
+	// The datastore may be half moved.
+	if err := checkConvertSwap(r.path); err != nil {
+		return nil, err
 	}
 
 	// check repo path, then check all constituent parts.
@@ -185,6 +194,53 @@ func open(repoPath string) (repo.Repo, error) {
 	return r, nil
 }
 
+// checkVersion returns an error if the repo at path doesn't have the version
+// this program expects.
+func checkVersion(path string) error {
+	ver, err := mfsr.RepoPath(path).Version()
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ErrNoVersion
+		}
+		return err
+	}
+
+	if RepoVersion > ver {
+		return ErrNeedMigration
+	} else if ver > RepoVersion {
+		// program version too low for existing repo
+		return fmt.Errorf(programTooLowMessage, RepoVersion, ver)
+	}
+	return nil
+}
+
+// Migrate brings the repo at repoPath to the given version with the
+// migrations registered in mfsr. The repo must not be in use.
+func Migrate(repoPath string, version int, opts ...mfsr.MigrateOption) error {
+	packageLock.Lock()
+	defer packageLock.Unlock()
+
+	r, err := newFSRepo(repoPath)
+	if err != nil {
+		return err
+	}
+	if err := checkInitialized(r.path); err != nil {
+		return err
+	}
+
+	lock, err := lockfile.Lock(r.path, LockFile)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+
+	rp := mfsr.RepoPath(r.path)
+	if _, err := rp.Version(); os.IsNotExist(err) {
+		return ErrNoVersion
+	}
+	return rp.CheckVersion(version, opts...)
+}
+
 func newFSRepo(rpath string) (*FSRepo, error) {
 	expPath, err := homedir.Expand(filepath.Clean(rpath))
 	if err != nil {
@@ -384,13 +440,45 @@ func (r *FSRepo) openConfig() error {
 }
 
 func (r *FSRepo) openKeystore() error {
-	ksp := filepath.Join(r.path, "keystore")
-	ks, err := keystore.NewFSKeystore(ksp)
-	if err != nil {
-		return err
+	var cfg KeystoreConfig
+	// packageLock is held while opening: read the config file directly.
+	if err := repo.ConfigSection(repo.ConfigGetterFunc(r.getConfigKey), "Keystore", &cfg); err != nil {
+		return fmt.Errorf("invalid Keystore config: %s", err)
 	}
 
-	r.keystore = ks
+	ksp := filepath.Join(r.path, "keystore")
+	switch cfg.Type {
+	case "", "fs":
+		ks, err := keystore.NewFSKeystore(ksp)
+		if err != nil {
+			return err
+		}
+		r.keystore = ks
+	case "encrypted":
+		ks, err := keystore.NewEncryptedKeystore(ksp)
+		if err != nil {
+			return err
+		}
+		// Without a passphrase, the keystore stays locked until
+		// 'ipfs key unlock' is run.
+		if pass := os.Getenv(EnvKeystorePassphrase); pass != "" {
+			if err := ks.Unlock([]byte(pass)); err != nil {
+				return fmt.Errorf("unlocking keystore: %s", err)
+			}
+		}
+		r.keystore = ks
+	case "remote":
+		if cfg.Socket == "" {
+			return errors.New("Keystore.Socket must be set for a remote keystore")
+		}
+		socket := cfg.Socket
+		if !filepath.IsAbs(socket) {
+			socket = filepath.Join(r.path, socket)
+		}
+		r.keystore = keystore.NewRemoteKeystore(socket)
+	default:
+		return fmt.Errorf("unknown keystore type: %q", cfg.Type)
+	}
 
 	return nil
 }
@@ -574,6 +662,12 @@ func (r *FSRepo) GetConfigKey(key string) (interface{}, error) {
 		return nil, errors.New("repo is closed")
 	}
 
+	return r.getConfigKey(key)
+}
+
+// getConfigKey reads a key straight from the config file. The caller must
+// hold packageLock.
+func (r *FSRepo) getConfigKey(key string) (interface{}, error) {
 	filename, err := config.Filename(r.path)
 	if err != nil {
 		return nil, err
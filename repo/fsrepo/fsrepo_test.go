@@ -2,11 +2,13 @@ package fsrepo
 
 import (
 	"bytes"
+	"encoding/json"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"testing"
 
+	mfsr "github.com/ipfs/go-ipfs/repo/fsrepo/migrations"
 	"github.com/ipfs/go-ipfs/thirdparty/assert"
 
 	datastore "github.com/ipfs/go-datastore"
@@ -116,3 +118,72 @@ func TestOpenMoreThanOnceInSameProcess(t *testing.T) {
 	assert.Nil(r1.Close(), t)
 	assert.Nil(r2.Close(), t)
 }
+
+// setMigrationMark sets or removes the MigrationMark key of the config of the
+// repo at repoPath, without opening it.
+func setMigrationMark(repoPath string, set bool) error {
+	cfgPath := filepath.Join(repoPath, "config")
+	data, err := ioutil.ReadFile(cfgPath)
+	if err != nil {
+		return err
+	}
+	var cfg map[string]interface{}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return err
+	}
+	if set {
+		cfg["MigrationMark"] = true
+	} else {
+		delete(cfg, "MigrationMark")
+	}
+	if data, err = json.Marshal(cfg); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(cfgPath, data, 0600)
+}
+
+func TestMigrate(t *testing.T) {
+	path := testRepoPath("migrate", t)
+	defer os.RemoveAll(path)
+	assert.Nil(Init(path, &config.Config{Datastore: config.DefaultDatastoreConfig()}), t)
+
+	// The registry is global, the migration stays registered: no other test
+	// of this package migrates repos.
+	err := mfsr.Register(&mfsr.Migration{
+		From:        RepoVersion - 1,
+		Description: "test migration",
+		Apply:       func(repoPath string) error { return setMigrationMark(repoPath, true) },
+		Revert:      func(repoPath string) error { return setMigrationMark(repoPath, false) },
+	})
+	assert.Nil(err, t)
+
+	assert.Nil(mfsr.RepoPath(path).WriteVersion(RepoVersion-1), t)
+	if _, err := Open(path); err != ErrNeedMigration {
+		t.Fatalf("expected ErrNeedMigration, got %v", err)
+	}
+
+	var out bytes.Buffer
+	assert.Nil(Migrate(path, RepoVersion, mfsr.RunMigrations(&out)), t, "migrating the repo")
+	backups, _ := filepath.Glob(path + "-v*-backup-*")
+	for _, backup := range backups {
+		defer os.RemoveAll(backup)
+	}
+	if len(backups) != 1 {
+		t.Fatalf("expected one backup, got %v", backups)
+	}
+
+	r, err := Open(path)
+	assert.Nil(err, t, "the migrated repo should open")
+	mark, err := r.GetConfigKey("MigrationMark")
+	assert.Nil(err, t)
+	assert.True(mark == true, t, "the migration should have been applied")
+	assert.Nil(r.Close(), t)
+
+	assert.Nil(Migrate(path, RepoVersion-1, mfsr.RunMigrations(&out), mfsr.SkipBackup()), t, "reverting the migration")
+	if _, err := Open(path); err != ErrNeedMigration {
+		t.Fatalf("expected ErrNeedMigration after reverting, got %v", err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(path, "config"))
+	assert.Nil(err, t)
+	assert.True(!bytes.Contains(data, []byte("MigrationMark")), t, "the migration should have been reverted")
+}
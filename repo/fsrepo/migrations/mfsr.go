@@ -36,17 +36,27 @@ func (rp RepoPath) Version() (int, error) {
 	return strconv.Atoi(s)
 }
 
-func (rp RepoPath) CheckVersion(version int) error {
+// CheckVersion returns an error if the repo isn't of the given version. With
+// RunMigrations, the registered migrations are run to bring it to that
+// version instead, after backing it up unless SkipBackup is given.
+func (rp RepoPath) CheckVersion(version int, opts ...MigrateOption) error {
 	v, err := rp.Version()
 	if err != nil {
 		return err
 	}
 
-	if v != version {
-		return fmt.Errorf("versions differ (expected: %d, actual:%d)", version, v)
+	if v == version {
+		return nil
 	}
 
-	return nil
+	s := migrateSettings{backup: true, out: ioutil.Discard}
+	for _, opt := range opts {
+		opt(&s)
+	}
+	if !s.run {
+		return fmt.Errorf("versions differ (expected: %d, actual:%d)", version, v)
+	}
+	return rp.migrate(v, version, &s)
 }
 
 func (rp RepoPath) WriteVersion(version int) error {
@@ -0,0 +1,244 @@
+package mfsr
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// FirstBuiltinVersion is the oldest repo version the built-in migrations
+// start from. The migrations of older repos, up to this version, aren't
+// ported from fs-repo-migrations: those repos are still migrated by running
+// fs-repo-migrations, which RunMigration finds in the PATH before trying to
+// download it. Hosts without network access need it installed.
+const FirstBuiltinVersion = 7
+
+// Migration upgrades a repo from version From to version From+1. Migrations
+// are compiled in and registered with Register, so that repos at
+// FirstBuiltinVersion or newer can be migrated without fs-repo-migrations.
+type Migration struct {
+	From        int
+	Description string
+
+	// Apply migrates the repo at repoPath to version From+1.
+	Apply func(repoPath string) error
+	// Revert migrates the repo at repoPath back to version From. It is nil
+	// when the migration can't be reverted.
+	Revert func(repoPath string) error
+}
+
+// NoMigrationError is returned when the migrations needed to bring a repo to
+// a version aren't registered.
+type NoMigrationError struct {
+	From, To int
+}
+
+func (e *NoMigrationError) Error() string {
+	if e.From < FirstBuiltinVersion || e.To < FirstBuiltinVersion {
+		return fmt.Sprintf("no built-in migration from repo version %d to %d: versions older than %d need fs-repo-migrations", e.From, e.To, FirstBuiltinVersion)
+	}
+	return fmt.Sprintf("no built-in migration from repo version %d to %d", e.From, e.To)
+}
+
+var (
+	registryLock sync.Mutex
+	registry     = make(map[int]*Migration)
+)
+
+// Register adds a migration to the registry.
+func Register(m *Migration) error {
+	if m.Apply == nil {
+		return fmt.Errorf("migration from version %d has no Apply function", m.From)
+	}
+
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	if _, ok := registry[m.From]; ok {
+		return fmt.Errorf("already have a migration from version %d", m.From)
+	}
+	registry[m.From] = m
+	return nil
+}
+
+// Migrations returns the registered migrations, by version.
+func Migrations() []*Migration {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	ms := make([]*Migration, 0, len(registry))
+	for _, m := range registry {
+		ms = append(ms, m)
+	}
+	sort.Slice(ms, func(i, j int) bool { return ms[i].From < ms[j].From })
+	return ms
+}
+
+// migrationStep is a migration to apply or revert.
+type migrationStep struct {
+	m      *Migration
+	revert bool
+}
+
+func (s migrationStep) from() int {
+	if s.revert {
+		return s.m.From + 1
+	}
+	return s.m.From
+}
+
+func (s migrationStep) to() int {
+	if s.revert {
+		return s.m.From
+	}
+	return s.m.From + 1
+}
+
+// plan returns the steps migrating a repo from one version to another.
+func plan(from, to int) ([]migrationStep, error) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	var steps []migrationStep
+	for v := from; v < to; v++ {
+		m, ok := registry[v]
+		if !ok {
+			return nil, &NoMigrationError{From: from, To: to}
+		}
+		steps = append(steps, migrationStep{m: m})
+	}
+	for v := from; v > to; v-- {
+		m, ok := registry[v-1]
+		if !ok || m.Revert == nil {
+			return nil, &NoMigrationError{From: from, To: to}
+		}
+		steps = append(steps, migrationStep{m: m, revert: true})
+	}
+	return steps, nil
+}
+
+// MigrateOption configures how CheckVersion migrates a repo.
+type MigrateOption func(*migrateSettings)
+
+type migrateSettings struct {
+	run    bool
+	backup bool
+	out    io.Writer
+}
+
+// RunMigrations makes CheckVersion migrate the repo to the expected version,
+// instead of failing. Progress is reported to out.
+func RunMigrations(out io.Writer) MigrateOption {
+	return func(s *migrateSettings) {
+		s.run = true
+		s.out = out
+	}
+}
+
+// SkipBackup disables the backup of the repo taken before migrating it.
+func SkipBackup() MigrateOption {
+	return func(s *migrateSettings) {
+		s.backup = false
+	}
+}
+
+// migrate runs the steps bringing the repo from one version to another. The
+// version file is updated after every step, so that a failed migration can
+// be resumed.
+func (rp RepoPath) migrate(from, to int, s *migrateSettings) error {
+	steps, err := plan(from, to)
+	if err != nil {
+		return err
+	}
+
+	if s.backup {
+		backup, err := rp.backup(from)
+		if err != nil {
+			return fmt.Errorf("backing up the repo: %s", err)
+		}
+		fmt.Fprintf(s.out, "  => Backed up the repo to %s\n", backup)
+	}
+
+	for _, step := range steps {
+		verb, run := "Migrating", step.m.Apply
+		if step.revert {
+			verb, run = "Reverting", step.m.Revert
+		}
+		fmt.Fprintf(s.out, "  => %s from version %d to %d: %s\n", verb, step.from(), step.to(), step.m.Description)
+
+		if err := run(string(rp)); err != nil {
+			return fmt.Errorf("migration from version %d to %d failed: %s", step.from(), step.to(), err)
+		}
+		if err := rp.WriteVersion(step.to()); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(s.out, "  => Success: fs-repo has been migrated to version %d.\n", to)
+	return nil
+}
+
+// backupSkip are the files of the repo not copied to backups.
+var backupSkip = map[string]bool{
+	"repo.lock": true,
+	"api":       true,
+}
+
+// backup copies the repo next to it, and returns the path of the copy.
+func (rp RepoPath) backup(version int) (string, error) {
+	src := filepath.Clean(string(rp))
+	dst := fmt.Sprintf("%s-v%d-backup-%s", src, version, time.Now().Format("20060102150405"))
+
+	err := filepath.Walk(src, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if backupSkip[rel] {
+			return nil
+		}
+		target := filepath.Join(dst, rel)
+
+		switch {
+		case fi.IsDir():
+			return os.Mkdir(target, fi.Mode().Perm())
+		case fi.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		default:
+			return copyFile(path, target, fi.Mode().Perm())
+		}
+	})
+	if err != nil {
+		return "", err
+	}
+	return dst, nil
+}
+
+func copyFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
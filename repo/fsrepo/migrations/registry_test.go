@@ -0,0 +1,112 @@
+package mfsr
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// The test migrations use versions no real repo has.
+const testVersion = 1000
+
+func registerTestMigrations(t *testing.T) {
+	for v := testVersion; v < testVersion+2; v++ {
+		from := v
+		err := Register(&Migration{
+			From:        from,
+			Description: "test migration",
+			Apply: func(repoPath string) error {
+				return ioutil.WriteFile(filepath.Join(repoPath, "migrated"), []byte(strconv.Itoa(from+1)), 0644)
+			},
+			Revert: func(repoPath string) error {
+				return ioutil.WriteFile(filepath.Join(repoPath, "migrated"), []byte(strconv.Itoa(from)), 0644)
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func unregisterTestMigrations() {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	for v := testVersion; v < testVersion+2; v++ {
+		delete(registry, v)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	registerTestMigrations(t)
+	defer unregisterTestMigrations()
+
+	if err := Register(&Migration{From: testVersion, Apply: func(string) error { return nil }}); err == nil {
+		t.Error("expected registering a migration twice to fail")
+	}
+
+	rp := testVersionFile("migrate", t)
+	defer os.RemoveAll(string(rp))
+	if err := rp.WriteVersion(testVersion); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := rp.CheckVersion(testVersion + 2); err == nil {
+		t.Fatal("expected CheckVersion to fail without RunMigrations")
+	}
+
+	var out bytes.Buffer
+	if err := rp.CheckVersion(testVersion+2, RunMigrations(&out)); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := rp.Version(); v != testVersion+2 {
+		t.Fatalf("expected version %d, got %d", testVersion+2, v)
+	}
+	data, _ := ioutil.ReadFile(filepath.Join(string(rp), "migrated"))
+	if string(data) != strconv.Itoa(testVersion+2) {
+		t.Errorf("migrations not applied in order: %s", data)
+	}
+
+	// The repo was backed up before migrating.
+	if !strings.Contains(out.String(), "Backed up the repo to ") {
+		t.Fatalf("no backup reported:\n%s", out.String())
+	}
+	backups, _ := filepath.Glob(string(rp) + "-v*-backup-*")
+	if len(backups) != 1 {
+		t.Fatalf("expected one backup, got %v", backups)
+	}
+	defer os.RemoveAll(backups[0])
+	if v, err := RepoPath(backups[0]).Version(); err != nil || v != testVersion {
+		t.Errorf("backup is at version %d: %v", v, err)
+	}
+
+	if err := rp.CheckVersion(testVersion+1, RunMigrations(&out), SkipBackup()); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := rp.Version(); v != testVersion+1 {
+		t.Fatalf("expected version %d, got %d", testVersion+1, v)
+	}
+	data, _ = ioutil.ReadFile(filepath.Join(string(rp), "migrated"))
+	if string(data) != strconv.Itoa(testVersion+1) {
+		t.Errorf("migration not reverted: %s", data)
+	}
+}
+
+func TestMigrateMissing(t *testing.T) {
+	rp := testVersionFile("migrate", t)
+	defer os.RemoveAll(string(rp))
+	if err := rp.WriteVersion(testVersion); err != nil {
+		t.Fatal(err)
+	}
+
+	err := rp.CheckVersion(testVersion+1, RunMigrations(ioutil.Discard))
+	if _, ok := err.(*NoMigrationError); !ok {
+		t.Fatalf("expected a NoMigrationError, got %v", err)
+	}
+	if v, _ := rp.Version(); v != testVersion {
+		t.Errorf("version changed to %d", v)
+	}
+}
@@ -22,6 +22,10 @@ $(d)/ma-pipe-unidir: test/dependencies/ma-pipe-unidir
 	$(go-build-relative)
 TGTS_$(d) += $(d)/ma-pipe-unidir
 
+$(d)/ipfs-signer: test/dependencies/ipfs-signer
+	$(go-build-relative)
+TGTS_$(d) += $(d)/ipfs-signer
+
 $(d)/json-to-junit: test/dependencies/json-to-junit
 	$(go-build-relative)
 TGTS_$(d) += $(d)/json-to-junit
@@ -0,0 +1,72 @@
+// ipfs-signer is a reference signer for remote keystores. It serves the keys
+// of a keystore directory over a unix socket, using the protocol implemented
+// by keystore.RemoteKeystore.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	keystore "github.com/ipfs/go-ipfs/keystore"
+)
+
+const USAGE = "ipfs-signer [--pidFile=path] [-h|--help] <keystore-dir> <socket>\n"
+
+func app() int {
+	var pidFile string
+	flag.StringVar(&pidFile, "pidFile", "", "")
+	flag.Usage = func() {
+		fmt.Print(USAGE)
+	}
+	flag.Parse()
+	args := flag.Args()
+
+	if len(args) != 2 {
+		fmt.Print(USAGE)
+		return 1
+	}
+
+	ks, err := keystore.NewFSKeystore(args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	// Remove the socket left behind by a previous run.
+	os.Remove(args[1])
+	l, err := net.Listen("unix", args[1])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer l.Close()
+
+	if pidFile != "" {
+		err := ioutil.WriteFile(pidFile, []byte(strconv.Itoa(os.Getpid())), 0644)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer os.Remove(pidFile)
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigs
+		l.Close()
+	}()
+
+	keystore.ServeSigner(l, ks)
+	return 0
+}
+
+func main() {
+	os.Exit(app())
+}
@@ -0,0 +1,61 @@
+#!/usr/bin/env bash
+#
+# Copyright (c) 2019 Protocol Labs
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test ipfs add --workers and --resume"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "create a directory tree" '
+  mkdir -p tree/a tree/b &&
+  random 1000000 1 > tree/a/one &&
+  random 500000 2 > tree/a/two &&
+  random 700000 3 > tree/b/three &&
+  random 10 4 > tree/four
+'
+
+test_expect_success "add the tree sequentially" '
+  ipfs add -r tree > add_seq &&
+  HASH=$(tail -n1 add_seq | cut -d" " -f2)
+'
+
+test_expect_success "adding in parallel gives the same output" '
+  ipfs add -r --workers=4 tree > add_par &&
+  test_cmp add_seq add_par
+'
+
+test_expect_success "--workers must be positive" '
+  test_must_fail ipfs add -r --workers=0 tree 2> workers_err &&
+  grep "at least 1" workers_err
+'
+
+test_expect_success "adding with --resume gives the same hash" '
+  ipfs add -r -Q --resume --workers=2 tree > add_resume &&
+  echo $HASH > expected &&
+  test_cmp expected add_resume
+'
+
+test_expect_success "adding with --resume again gives the same hash" '
+  ipfs add -r -Q --resume tree > add_resume &&
+  test_cmp expected add_resume
+'
+
+test_launch_ipfs_daemon
+
+test_expect_success "adding in parallel through the daemon gives the same output" '
+  ipfs add -r --workers=4 tree > add_par &&
+  test_cmp add_seq add_par
+'
+
+test_expect_success "adding with --resume through the daemon gives the same hash" '
+  ipfs add -r -Q --resume --workers=4 tree > add_resume &&
+  test_cmp expected add_resume
+'
+
+test_kill_ipfs_daemon
+
+test_done
@@ -34,6 +34,11 @@ test_expect_success "ipfs daemon --migrate=true runs migration" '
   test_expect_code 1 ipfs daemon --migrate=true > true_out
 '
 
+test_expect_success "built-in migrations are tried first" '
+  grep "no built-in migration from repo version 3 to" true_out > /dev/null &&
+  grep "looking for fs-repo-migrations" true_out > /dev/null
+'
+
 test_expect_failure "output looks good" '
   grep "Running: " true_out > /dev/null &&
   grep "Success: fs-repo has been migrated to version 5." true_out > /dev/null
@@ -49,4 +54,25 @@ test_expect_success "output looks good" '
   grep "Please get fs-repo-migrations from https://dist.ipfs.io" daemon_out > /dev/null
 '
 
+test_expect_success "'ipfs repo migrate' fails without built-in migrations" '
+  test_must_fail ipfs repo migrate 2> migrate_err &&
+  grep "no built-in migration from repo version 3 to" migrate_err &&
+  cat "$IPFS_PATH"/version | grep 3
+'
+
+test_expect_success "'ipfs repo migrate' requires --revert to go back" '
+  test_must_fail ipfs repo migrate --to=2 2> migrate_err &&
+  grep "use --revert to migrate it back to version 2" migrate_err
+'
+
+test_expect_success "'ipfs repo migrate --revert' fails without built-in migrations" '
+  test_must_fail ipfs repo migrate --revert 2> migrate_err &&
+  grep "no built-in migration from repo version 3 to 2" migrate_err
+'
+
+test_expect_success "'ipfs repo migrate' does nothing at the current version" '
+  ipfs repo migrate --to=3 > migrate_out &&
+  grep "already at version 3" migrate_out
+'
+
 test_done
@@ -0,0 +1,61 @@
+#!/usr/bin/env bash
+
+test_description="Test the hard storage quota of the repo"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "enforce a quota of 1MB over the current usage" '
+  REPO_SIZE=$(ipfs repo stat --size-only | grep RepoSize | awk "{ print \$2 }") &&
+  ipfs config Datastore.StorageMax "$((REPO_SIZE + 1000000))B" &&
+  ipfs config --json Quota "{\"Enforce\": true}"
+'
+
+test_expect_success "adding a file under the quota succeeds" '
+  random 100000 1 > small &&
+  ipfs add -q small
+'
+
+test_expect_success "adding a file over the quota fails" '
+  random 2000000 2 > big &&
+  test_must_fail ipfs add -q big 2> add_err &&
+  grep "repo full" add_err
+'
+
+test_expect_success "'ipfs block put' over the quota fails" '
+  random 1500000 3 > bigblock &&
+  test_must_fail ipfs block put bigblock 2> put_err &&
+  grep "repo full" put_err
+'
+
+test_expect_success "an invalid quota is reported" '
+  ipfs config Datastore.StorageMax "" &&
+  test_must_fail ipfs add -q small 2> add_err &&
+  grep "Quota.Enforce requires Datastore.StorageMax" add_err &&
+  ipfs config Datastore.StorageMax "$((REPO_SIZE + 1000000))B"
+'
+
+test_config_ipfs_gateway_writable
+test_launch_ipfs_daemon
+
+test_expect_success "the API reports a full repo" '
+  curl -s -X POST -F "file=@big" "http://$API_ADDR/api/v0/add" > api_out ;
+  grep "repo full" api_out
+'
+
+test_expect_success "the writable gateway answers 507 when the repo is full" '
+  curl -sv -X POST --data-binary @big "http://$GWAY_ADDR/ipfs/" 2> gw_err > gw_out ;
+  grep "HTTP/1.1 507 Insufficient Storage" gw_err &&
+  grep "repo full" gw_out
+'
+
+test_expect_success "garbage collection frees the quota" '
+  random 600000 4 > medium &&
+  ipfs repo gc &&
+  ipfs add -q medium
+'
+
+test_kill_ipfs_daemon
+
+test_done
@@ -0,0 +1,83 @@
+#!/usr/bin/env bash
+
+test_description="Test 'ipfs repo backup' and 'ipfs repo restore'"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "add and pin some content" '
+  random 100000 42 > afile &&
+  HASH=$(ipfs add -q afile) &&
+  ipfs key gen --type=rsa --size=2048 backupkey > backupkey_id &&
+  ipfs files mkdir /backup-dir &&
+  ipfs files cp /ipfs/$HASH /backup-dir/afile &&
+  ipfs config --json PubsubTopics "{\"backup-topic\": {\"MaxMessageSize\": 10}}" &&
+  PEERID=$(ipfs config Identity.PeerID) &&
+  MFSROOT=$(ipfs files stat --hash /)
+'
+
+test_launch_ipfs_daemon
+
+test_expect_success "'ipfs repo backup' succeeds with the daemon running" '
+  ipfs repo backup full.tar > backup_out &&
+  grep "backup written to full.tar" backup_out &&
+  tar tf full.tar > full_entries &&
+  head -n 1 full_entries | grep backup.json &&
+  grep "^config$" full_entries &&
+  grep "^keystore/backupkey$" full_entries &&
+  grep "^datastore/blocks/" full_entries &&
+  grep "^datastore/local/pins$" full_entries
+'
+
+test_expect_success "'ipfs repo backup' doesn't overwrite files" '
+  test_must_fail ipfs repo backup full.tar
+'
+
+test_expect_success "'ipfs repo backup --metadata-only' leaves blocks out" '
+  ipfs repo backup --metadata-only meta.tar &&
+  tar tf meta.tar > meta_entries &&
+  grep "^datastore/local/pins$" meta_entries &&
+  test_must_fail grep "^datastore/blocks/" meta_entries
+'
+
+test_kill_ipfs_daemon
+
+test_expect_success "'ipfs repo restore' refuses an existing repo" '
+  test_must_fail ipfs repo restore full.tar 2> restore_err &&
+  grep "repo already exists" restore_err
+'
+
+test_expect_success "a failed 'ipfs repo restore' leaves no repo behind" '
+  mv "$IPFS_PATH" ipfs_old &&
+  head -c 20000 full.tar > truncated.tar &&
+  test_must_fail ipfs repo restore truncated.tar &&
+  test ! -e "$IPFS_PATH" &&
+  test -z "$(ls -a "$(dirname "$IPFS_PATH")" | grep "\.restore-")"
+'
+
+test_expect_success "'ipfs repo restore' rebuilds the repo" '
+  ipfs repo restore full.tar > restore_out &&
+  grep "restored repo of peer $PEERID" restore_out
+'
+
+test_expect_success "the restored repo holds the backed up state" '
+  test "$(ipfs config Identity.PeerID)" = "$PEERID" &&
+  ipfs key list > keys &&
+  grep backupkey keys &&
+  ipfs config PubsubTopics.backup-topic.MaxMessageSize | grep 10 &&
+  ipfs pin ls --type=recursive | grep "$HASH" &&
+  test "$(ipfs files stat --hash /)" = "$MFSROOT" &&
+  ipfs cat "$HASH" > afile_out &&
+  test_cmp afile afile_out
+'
+
+test_expect_success "'ipfs repo restore' of a metadata-only backup" '
+  rm -rf "$IPFS_PATH" &&
+  ipfs repo restore meta.tar > restore_out &&
+  grep "holds no blocks" restore_out &&
+  ipfs pin ls --type=recursive | grep "$HASH" &&
+  test_must_fail ipfs block stat --offline "$HASH"
+'
+
+test_done
@@ -0,0 +1,68 @@
+#!/usr/bin/env bash
+
+test_description="Test 'ipfs repo convert'"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "add some content" '
+  random 100000 41 > afile &&
+  HASH=$(ipfs add -q afile)
+'
+
+test_expect_success "'ipfs repo convert' requires a target" '
+  test_must_fail ipfs repo convert 2> convert_err &&
+  grep "missing --to" convert_err
+'
+
+test_expect_success "'ipfs repo convert' refuses the current datastore" '
+  test_must_fail ipfs repo convert --to=flatfs 2> convert_err &&
+  grep "already uses this datastore" convert_err
+'
+
+test_expect_success "'ipfs repo convert --to=badgerds' succeeds" '
+  ipfs repo convert --to=badgerds > convert_out &&
+  grep "conversion complete" convert_out
+'
+
+test_expect_success "the repo uses badger" '
+  ipfs config Datastore.Spec.child.type > ds_type &&
+  echo badgerds > ds_type_exp &&
+  test_cmp ds_type_exp ds_type &&
+  grep badgerds "$IPFS_PATH/datastore_spec" &&
+  test -d "$IPFS_PATH/datastore-backup/blocks" &&
+  test ! -e "$IPFS_PATH/datastore-convert"
+'
+
+test_expect_success "content is still there" '
+  ipfs cat "$HASH" > afile_out &&
+  test_cmp afile afile_out &&
+  ipfs pin ls --type=recursive | grep "$HASH"
+'
+
+test_expect_success "'ipfs repo convert' refuses to overwrite a backup" '
+  test_must_fail ipfs repo convert --to=flatfs 2> convert_err &&
+  grep "datastore-backup already exists" convert_err
+'
+
+test_expect_success "'ipfs repo convert' takes a spec file" '
+  rm -rf "$IPFS_PATH/datastore-backup" &&
+  cat > spec.json <<-\EOF &&
+	{"type": "mount", "mounts": [{"mountpoint": "/", "type": "levelds", "path": "leveldb", "compression": "none"}]}
+	EOF
+  ipfs repo convert --to=spec.json &&
+  grep leveldb "$IPFS_PATH/datastore_spec" &&
+  ipfs cat "$HASH" > afile_out &&
+  test_cmp afile afile_out
+'
+
+test_launch_ipfs_daemon
+
+test_expect_success "'ipfs repo convert' cannot run with the daemon" '
+  test_must_fail ipfs repo convert --to=flatfs
+'
+
+test_kill_ipfs_daemon
+
+test_done
@@ -176,6 +176,40 @@ test_expect_success "resolve output looks good" '
   test_cmp expected4 output
 '
 
+test_expect_success "'ipfs name get' succeeds" '
+  ipfs name get "$PEERID" >record
+'
+
+test_expect_success "'ipfs name inspect --verify' succeeds" '
+  ipfs name inspect --verify="$PEERID" <record >inspect_out
+'
+
+test_expect_success "inspect output looks good" '
+  grep "Value:         /ipld/$OBJECT_HASH/thing" inspect_out &&
+  grep "Valid:       true" inspect_out
+'
+
+test_expect_success "'ipfs name inspect' rejects a record for another name" '
+  ipfs key gen --type=rsa --size=2048 inspectkey >inspectkey_id &&
+  ipfs name inspect --verify="$(cat inspectkey_id)" <record >inspect_out &&
+  grep "Valid:       false" inspect_out
+'
+
+test_expect_success "'ipfs name inspect' reads a record file" '
+  ipfs name inspect --verify="$PEERID" record >inspect_out &&
+  grep "Value:         /ipld/$OBJECT_HASH/thing" inspect_out &&
+  grep "Valid:       true" inspect_out
+'
+
+test_expect_success "'ipfs name inspect' fetches the record of a name" '
+  ipfs name inspect "$PEERID" >inspect_out &&
+  grep "Value:         /ipld/$OBJECT_HASH/thing" inspect_out
+'
+
+test_expect_success "'ipfs name inspect' fails on a missing file" '
+  test_must_fail ipfs name inspect not-a-record
+'
+
 test_launch_ipfs_daemon
 
 test_expect_success "'ipfs name resolve --offline' succeeds" '
@@ -194,6 +228,11 @@ test_expect_success "resolve output looks good" '
   test_cmp expected4 output
 '
 
+test_expect_success "'ipfs name inspect' uploads the record file to the daemon" '
+  ipfs name inspect record >inspect_out &&
+  grep "Value:         /ipld/$OBJECT_HASH/thing" inspect_out
+'
+
 test_expect_success "empty request to name publish doesn't panic and returns error" '
   curl "http://$API_ADDR/api/v0/name/publish" > curl_out || true &&
     grep "argument \"ipfs-path\" is required" curl_out
@@ -213,4 +252,41 @@ test_expect_success "'ipfs name publish' fails offline mode" '
 
 test_kill_ipfs_daemon
 
+# test history and rollback
+
+test_expect_success "'ipfs name history' lists the published values" '
+  ipfs name history >history_out &&
+  grep "/ipfs/$HASH_WELCOME_DOCS" history_out
+'
+
+test_expect_success "'ipfs name rollback' publishes a previous value of self" '
+  SEQ=$(grep "/ipfs/$HASH_WELCOME_DOCS" history_out | head -n1 | cut -d" " -f1) &&
+  ipfs name rollback --allow-offline --seq="$SEQ" >rollback_out &&
+  echo "Published to ${PEERID}: /ipfs/$HASH_WELCOME_DOCS" >expected_rollback &&
+  test_cmp expected_rollback rollback_out
+'
+
+test_expect_success "rolled back value resolves" '
+  ipfs name resolve "$PEERID" >output &&
+  printf "/ipfs/%s\n" "$HASH_WELCOME_DOCS" >expected_rollback &&
+  test_cmp expected_rollback output
+'
+
+test_expect_success "'ipfs name rollback' accepts the peer ID of the node" '
+  ipfs name history | tail -n1 | cut -d" " -f1 >seq_before &&
+  ipfs name rollback --allow-offline --seq="$SEQ" "$PEERID" >rollback_out &&
+  echo "Published to ${PEERID}: /ipfs/$HASH_WELCOME_DOCS" >expected_rollback &&
+  test_cmp expected_rollback rollback_out
+'
+
+test_expect_success "rolling back to the current value raises the sequence number" '
+  ipfs name history | tail -n1 | cut -d" " -f1 >seq_after &&
+  test "$(cat seq_after)" -gt "$(cat seq_before)"
+'
+
+test_expect_success "'ipfs name rollback' fails on an unknown sequence number" '
+  test_must_fail ipfs name rollback --allow-offline --seq=1000 2>rollback_err &&
+  grep "no such entry" rollback_err
+'
+
 test_done
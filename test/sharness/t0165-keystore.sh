@@ -74,8 +74,106 @@ test_key_cmd() {
     test_must_fail ipfs key rename -f fooed self 2>&1 | tee key_rename_out &&
     grep -q "Error: cannot overwrite key with name" key_rename_out
   '
+
+  test_expect_success "key export writes the key to a file" '
+    ipfs key export fooed &&
+    test -f fooed.key
+  '
+
+  test_expect_success "key export doesn't overwrite files" '
+    test_must_fail ipfs key export fooed
+  '
+
+  test_expect_success "key import refuses existing names and keys" '
+    test_must_fail ipfs key import fooed fooed.key 2>&1 | tee key_import_out &&
+    grep -q "already exists" key_import_out &&
+    test_must_fail ipfs key import other fooed.key 2>&1 | tee key_import_out &&
+    grep -q "already imported as" key_import_out
+  '
+
+  test_expect_success "key export and import round trip through encrypted PEM" '
+    fooedhash=$(ipfs key list -l | grep fooed | cut -d" " -f1) &&
+    ipfs key export --format=pem-pkcs8 --passphrase=secret -o fooed.pem fooed &&
+    grep -q "BEGIN ENCRYPTED PRIVATE KEY" fooed.pem &&
+    ipfs key rm fooed &&
+    test_must_fail ipfs key import fooed fooed.pem &&
+    ipfs key import --passphrase=secret fooed fooed.pem > import_out &&
+    echo $fooedhash > import_exp &&
+    test_cmp import_exp import_out
+  '
+
+  test_expect_success "key rotate moves a name to a new key" '
+    HASH=$(echo "rotate me" | ipfs add -q) &&
+    ipfs name publish --allow-offline --key=fooed "/ipfs/$HASH" &&
+    ipfs key rotate --allow-offline fooed > rotate_out &&
+    grep -q "rotated from $fooedhash" rotate_out &&
+    newhash=$(ipfs key list -l | grep "\s\+fooed\s*\$" | cut -d" " -f1) &&
+    test "$newhash" != "$fooedhash" &&
+    ipfs key list | grep -q "fooed-$fooedhash"
+  '
+
+  test_expect_success "the old name resolves through the new key" '
+    ipfs name resolve -r=false "$fooedhash" > resolve_out &&
+    echo "/ipns/$newhash" > resolve_exp &&
+    test_cmp resolve_exp resolve_out &&
+    ipfs name resolve "$newhash" > resolve_out &&
+    echo "/ipfs/$HASH" > resolve_exp &&
+    test_cmp resolve_exp resolve_out
+  '
+
+  test_expect_success "name resolve reports the rotation" '
+    ipfs name resolve --rotations "$fooedhash" > resolve_out &&
+    grep -q "rotated: $fooedhash -> $newhash" resolve_out
+  '
 }
 
 test_key_cmd
 
+test_expect_success "switch to an encrypted keystore" '
+  ipfs key list -l > list_plain &&
+  ipfs config --json Keystore "{\"Type\": \"encrypted\"}"
+'
+
+test_expect_success "keys are listed while the keystore is locked" '
+  ipfs key list -l > list_locked &&
+  test_cmp list_plain list_locked
+'
+
+test_launch_ipfs_daemon
+
+test_expect_success "the passphrase can't be set through the daemon" '
+  test_must_fail ipfs key unlock secret 2> unlock_err &&
+  grep -q "stop the daemon" unlock_err
+'
+
+test_expect_success "self can't be exported through the daemon" '
+  test_must_fail ipfs key export -o self.key self 2> export_err &&
+  grep -q "stop the daemon" export_err &&
+  test_must_fail curl -sf -X POST "http://$API_ADDR/api/v0/key/export?arg=self" &&
+  test ! -e self.key
+'
+
+test_kill_ipfs_daemon
+
+test_expect_success "self is exported locally" '
+  ipfs key export -o self.key self &&
+  test -s self.key
+'
+
+test_expect_success "the passphrase is set locally" '
+  ipfs key unlock secret &&
+  grep -q "ipfs-key-v1" "$IPFS_PATH/keystore/fooed"
+'
+
+test_launch_ipfs_daemon
+
+test_expect_success "the daemon keystore unlocks with the passphrase" '
+  test_must_fail ipfs key unlock wrong &&
+  ipfs key unlock secret &&
+  ipfs key list -l > list_unlocked &&
+  test_cmp list_plain list_unlocked
+'
+
+test_kill_ipfs_daemon
+
 test_done
@@ -0,0 +1,55 @@
+#!/usr/bin/env bash
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test the remote signing keystore"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "move a key to the signer" '
+  keyhash=$(ipfs key gen signed --type=ed25519) &&
+  mv "$IPFS_PATH/keystore" signer-keys
+'
+
+test_expect_success "start the signer" '
+  ipfs-signer --pidFile=signer.pid signer-keys "$(pwd)/signer.sock" &
+  go-sleep 500ms &&
+  kill -0 $(cat signer.pid)
+'
+
+test_expect_success "configure the remote keystore" '
+  ipfs config --json Keystore "{\"Type\": \"remote\", \"Socket\": \"$(pwd)/signer.sock\"}"
+'
+
+test_expect_success "keys of the signer are listed" '
+  ipfs key list -l | grep "$keyhash\s\+signed"
+'
+
+test_expect_success "keys can't be added to the remote keystore" '
+  test_must_fail ipfs key gen other --type=ed25519 2>&1 | tee gen_out &&
+  grep -q "read-only" gen_out
+'
+
+test_expect_success "publish with a remote key" '
+  HASH=$(echo "remote" | ipfs add -q) &&
+  ipfs name publish --allow-offline --key=signed "/ipfs/$HASH" > publish_out &&
+  echo "Published to $keyhash: /ipfs/$HASH" > publish_exp &&
+  test_cmp publish_exp publish_out
+'
+
+test_expect_success "the key material never reached the node" '
+  test_must_fail ipfs key export signed
+'
+
+test_expect_success "stop the signer" '
+  kill $(cat signer.pid)
+'
+
+test_expect_success "publishing fails without the signer" '
+  test_must_fail ipfs name publish --allow-offline --key=signed "/ipfs/$HASH"
+'
+
+test_done
@@ -246,6 +246,12 @@ test_expect_success "'ipfs p2p stream ls' succeeds(2)" '
   test_cmp expected actual
 '
 
+test_expect_success "'ipfs p2p stream ls -v' prints traffic statistics" '
+  ipfsi 0 p2p stream ls -v > actual &&
+  grep -q "^ID \+Protocol \+Origin \+Target \+In \+Out \+Duration \+Idle" actual &&
+  grep -q "^4 \+/x/p2p-test2 \+/ipfs/$PEERID_1 \+/ip4/127.0.0.1/tcp/10101 \+[0-9]" actual
+'
+
 test_expect_success "'ipfs p2p close -a' closes remote app handlers" '
   ipfsi 0 p2p close -a &&
   ipfsi 0 p2p ls > actual &&
@@ -342,6 +348,85 @@ test_expect_success 'C->S Close listeners' '
   test_must_be_empty actual
 '
 
+# Access control
+
+test_expect_success 'start p2p listener denying peer' '
+  ipfsi 0 p2p listen /x/p2p-test /ip4/127.0.0.1/tcp/10101 --deny-peer=${PEERID_1} 2>&1 > listener-stdouterr.log &&
+  ipfsi 0 p2p ls | grep "deny=${PEERID_1}"
+'
+
+test_expect_success 'C->S Spawn receiving server' '
+  ma-pipe-unidir --listen --pidFile=listener.pid recv /ip4/127.0.0.1/tcp/10101 > server.out &
+
+  test_wait_for_file 30 100ms listener.pid &&
+  kill -0 $(cat listener.pid)
+'
+
+test_expect_success 'C->S Setup client side' '
+  ipfsi 1 p2p forward /x/p2p-test /ip4/127.0.0.1/tcp/10102 /ipfs/${PEERID_0} 2>&1 > dialer-stdouterr.log
+'
+
+test_expect_success 'C->S Denied peer does not reach the server' '
+  ma-pipe-unidir send /ip4/127.0.0.1/tcp/10102 < test1.bin &&
+  go-sleep 250ms &&
+  kill -0 $(cat listener.pid) &&
+  test_must_be_empty server.out
+'
+
+test_expect_success 'C->S Allowed peer reaches the server' '
+  ipfsi 0 p2p close -p /x/p2p-test &&
+  ipfsi 0 p2p listen /x/p2p-test /ip4/127.0.0.1/tcp/10101 --allow-peer=${PEERID_1} 2>&1 > listener-stdouterr.log &&
+  ipfsi 0 p2p ls | grep "allow=${PEERID_1}" &&
+  ma-pipe-unidir send /ip4/127.0.0.1/tcp/10102 < test1.bin &&
+  go-sleep 250ms &&
+  test ! -f listener.pid &&
+  test_cmp server.out test1.bin
+'
+
+test_expect_success 'C->S Close listeners' '
+  ipfsi 1 p2p close -p /x/p2p-test &&
+  ipfsi 0 p2p close -p /x/p2p-test
+'
+
+# Stream limits
+
+check_test_ports
+
+test_expect_success 'start p2p listener with limits' '
+  ipfsi 0 p2p listen /x/p2p-test /ip4/127.0.0.1/tcp/10101 --max-streams=1 --bandwidth=1MB --idle-timeout=1s 2>&1 > listener-stdouterr.log &&
+  ipfsi 0 p2p ls > actual &&
+  grep "max-streams=1" actual &&
+  grep "bandwidth=1.0 MB/s" actual &&
+  grep "idle-timeout=1s" actual
+'
+
+test_expect_success 'invalid limits are rejected' '
+  test_must_fail ipfsi 0 p2p listen /x/p2p-test-bad /ip4/127.0.0.1/tcp/10101 --idle-timeout=soon &&
+  test_must_fail ipfsi 1 p2p forward /x/p2p-test-bad /ip4/127.0.0.1/tcp/10103 /ipfs/${PEERID_0} --bandwidth=fast
+'
+
+test_expect_success 'Setup: Idle stream with timeout' '
+  ma-pipe-unidir --listen --pidFile=listener.pid recv /ip4/127.0.0.1/tcp/10101 &
+
+  ipfsi 1 p2p forward /x/p2p-test /ip4/127.0.0.1/tcp/10102 /ipfs/$PEERID_0 &&
+  ma-pipe-unidir --pidFile=client.pid recv /ip4/127.0.0.1/tcp/10102 &
+
+  test_wait_for_file 30 100ms listener.pid &&
+  test_wait_for_file 30 100ms client.pid
+'
+
+test_expect_success 'idle stream is closed after the timeout' '
+  go-sleep 2s &&
+  ipfsi 0 p2p stream ls > actual &&
+  test_must_be_empty actual &&
+  [ ! -f listener.pid ] && [ ! -f client.pid ]
+'
+
+test_expect_success 'Close listeners with limits' '
+  ipfsi 1 p2p close -p /x/p2p-test &&
+  ipfsi 0 p2p close -p /x/p2p-test
+'
+
 test_expect_success "non /x/ scoped protocols are not allowed" '
   test_must_fail ipfsi 0 p2p listen /its/not/a/x/path /ip4/127.0.0.1/tcp/10101 2> actual &&
   echo "Error: protocol name must be within '"'"'/x/'"'"' namespace" > expected
@@ -371,6 +456,39 @@ test_expect_success 'C->S Close local listener' '
 
 check_test_ports
 
+# Persistence
+
+test_expect_success 'persist p2p listener and forward' '
+  ipfsi 0 p2p listen --persist --allow-peer=${PEERID_1} --max-streams=4 /x/p2p-persist /ip4/127.0.0.1/tcp/10101 &&
+  ipfsi 1 p2p forward --persist /x/p2p-persist /ip4/127.0.0.1/tcp/10102 /ipfs/${PEERID_0} &&
+  ipfsi 0 config P2P | grep -q "/x/p2p-persist" &&
+  ipfsi 0 config P2P | grep -q "\"MaxStreams\": 4" &&
+  ipfsi 1 config P2P | grep -q "/ip4/127.0.0.1/tcp/10102"
+'
+
+test_expect_success 'restart nodes' '
+  iptb stop 0 &&
+  iptb stop 1 &&
+  iptb start -wait [0-1] &&
+  iptb connect 0 1
+'
+
+test_expect_success 'persisted listener and forward are restored' '
+  ipfsi 0 p2p ls | grep "/x/p2p-persist" | grep "allow=${PEERID_1}" | grep -q "max-streams=4" &&
+  ipfsi 1 p2p ls | grep -q "/x/p2p-persist /ip4/127.0.0.1/tcp/10102"
+'
+
+test_expect_success 'close --persist removes them from the config' '
+  ipfsi 0 p2p close --persist -p /x/p2p-persist &&
+  ipfsi 1 p2p close --persist -p /x/p2p-persist &&
+  ipfsi 0 config P2P > p2p_config &&
+  test_must_fail grep -q "/x/p2p-persist" p2p_config &&
+  ipfsi 1 config P2P > p2p_config &&
+  test_must_fail grep -q "/x/p2p-persist" p2p_config
+'
+
+check_test_ports
+
 test_expect_success 'stop iptb' '
   iptb stop
 '
@@ -151,4 +151,106 @@ test_expect_success 'stop iptb' '
   iptb stop
 '
 
+# Test sender filtering and topic validators
+
+test_expect_success 'configure validators of validTopic on node 0' '
+  ipfsi 0 config --json PubsubTopics "{\"validTopic\": {\"MaxMessageSize\": 16, \"Schema\": {\"type\": \"object\"}}}"
+'
+
+test_expect_success 'invalid --from peers are rejected' '
+  test_must_fail ipfsi 0 pubsub sub --from=notapeer fromTopic
+'
+
+startup_cluster $NUM_NODES --enable-pubsub-experiment
+
+test_expect_success 'subscribe with validators and sender filter' '
+  PEERID_1=$(iptb attr get 1 id) &&
+  ipfsi 0 pubsub sub --enc=ndpayload validTopic > valid_actual &
+  ipfsi 0 pubsub sub --enc=ndpayload --verify --from=$PEERID_1 fromTopic > from_actual &
+  go-sleep 500ms
+'
+
+test_expect_success 'publish messages' '
+  ipfsi 1 pubsub pub validTopic "not json" &&
+  ipfsi 1 pubsub pub validTopic "{\"too\": \"long to be valid\"}" &&
+  ipfsi 1 pubsub pub validTopic "{\"ok\": 1}" &&
+  ipfsi 2 pubsub pub fromTopic "from 2" &&
+  ipfsi 1 pubsub pub fromTopic "from 1" &&
+  go-sleep 500ms
+'
+
+test_expect_success 'stop iptb' '
+  iptb stop
+'
+
+test_expect_success 'only valid messages were received' '
+  echo "{\"ok\": 1}" > valid_expected &&
+  test_cmp valid_expected valid_actual
+'
+
+test_expect_success 'only messages of the given peer were received' '
+  echo "from 1" > from_expected &&
+  test_cmp from_expected from_actual
+'
+
+# Test message logs
+
+test_expect_success 'enable the log of logTopic on node 0' '
+  ipfsi 0 config --json PubsubTopics "{\"logTopic\": {\"Log\": {\"MaxMessages\": 2}}}"
+'
+
+test_expect_success '--since requires a logged topic' '
+  test_must_fail ipfsi 0 pubsub sub --since=0 otherTopic
+'
+
+startup_cluster $NUM_NODES --enable-pubsub-experiment
+
+test_expect_success 'node 0 is subscribed to the logged topic' '
+  ipfsi 0 pubsub ls > ls_actual &&
+  grep -q "^logTopic$" ls_actual
+'
+
+test_expect_success 'publish messages to the logged topic' '
+  go-sleep 500ms &&
+  ipfsi 1 pubsub pub logTopic "log1" &&
+  ipfsi 1 pubsub pub logTopic "log2" &&
+  ipfsi 1 pubsub pub logTopic "log3" &&
+  go-sleep 500ms
+'
+
+test_expect_success 'history lists the last messages' '
+  ipfsi 0 pubsub history logTopic > history_actual &&
+  test $(wc -l < history_actual) = 2 &&
+  grep -q "^1 .* log2$" history_actual &&
+  grep -q "^2 .* log3$" history_actual
+'
+
+test_expect_success 'history --since skips older messages' '
+  ipfsi 0 pubsub history --since=2 logTopic > history_actual &&
+  test $(wc -l < history_actual) = 1 &&
+  grep -q "^2 .* log3$" history_actual
+'
+
+test_expect_success 'stop iptb' '
+  iptb stop
+'
+
+startup_cluster $NUM_NODES --enable-pubsub-experiment
+
+test_expect_success 'sub --since replays the log before live messages' '
+  ipfsi 0 pubsub sub --enc=ndpayload --since=0 logTopic > replay_actual &
+  go-sleep 500ms &&
+  ipfsi 1 pubsub pub logTopic "log4" &&
+  go-sleep 500ms
+'
+
+test_expect_success 'stop iptb' '
+  iptb stop
+'
+
+test_expect_success 'replayed and live messages were received once' '
+  printf "log2\nlog3\nlog4\n" > replay_expected &&
+  test_cmp replay_expected replay_actual
+'
+
 test_done
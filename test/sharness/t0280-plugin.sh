@@ -14,6 +14,12 @@ test_expect_success "ipfs id succeeds" '
   ipfs id
 '
 
+test_expect_success "ipfs plugin ls lists the preloaded plugins" '
+  ipfs plugin ls > ls_out &&
+  grep "^ds-flatfs .* true .*injected .*Datastore" ls_out &&
+  grep "^ipld-git .* true .*injected .*IPLD" ls_out
+'
+
 test_expect_success "make a bad plugin" '
   mkdir -p "$IPFS_PATH/plugins" &&
   echo foobar > "$IPFS_PATH/plugins/foo.so" &&
@@ -24,8 +30,50 @@ test_expect_success "ipfs id fails due to a bad plugin" '
   test_expect_code 1 ipfs id
 '
 
+test_expect_success "a bad plugin is skipped when failures are not fatal" '
+  IPFS_PLUGINS_NONFATAL=true ipfs id
+'
+
+test_expect_success "ipfs plugin ls reports the bad plugin" '
+  IPFS_PLUGINS_NONFATAL=true ipfs plugin ls > ls_out &&
+  grep "foo.so .*failed" ls_out &&
+  grep "foo.so failed: loading plugin" ls_out
+'
+
+test_expect_success "a bad plugin is skipped when the config says so" '
+  cp "$IPFS_PATH/config" config.nonfatal &&
+  IPFS_PLUGINS_NONFATAL=true ipfs config --json Plugins.NonFatal true &&
+  ipfs id
+'
+
+test_expect_success "the environment overrides the config" '
+  test_expect_code 1 env IPFS_PLUGINS_NONFATAL=false ipfs id &&
+  cp config.nonfatal "$IPFS_PATH/config"
+'
+
 test_expect_success "cleanup bad plugin" '
   rm "$IPFS_PATH/plugins/foo.so"
 '
 
+test_expect_success "disable the flatfs plugin" '
+  cp "$IPFS_PATH/config" config.bak &&
+  ipfs config --json Plugins.Plugins "{\"ds-flatfs\": {\"Enabled\": false}}"
+'
+
+test_expect_success "a disabled plugin is not loaded" '
+  test_must_fail ipfs repo stat 2> stat_err &&
+  grep "unknown datastore type: flatfs" stat_err
+'
+
+test_expect_success "ipfs plugin ls reports the disabled plugin" '
+  ipfs plugin ls > ls_out &&
+  grep "^ds-flatfs .*disabled" ls_out
+'
+
+test_expect_success "plugins are enabled by default" '
+  cp config.bak "$IPFS_PATH/config" &&
+  ipfs config --json Plugins.Plugins "{\"ds-flatfs\": {\"Config\": {}}}" &&
+  ipfs repo stat
+'
+
 test_done
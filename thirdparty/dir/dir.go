@@ -4,8 +4,11 @@ package dir
 
 import (
 	"errors"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 )
 
 // Writable ensures the directory exists and is writable
@@ -23,3 +26,50 @@ func Writable(path string) error {
 	}
 	return nil
 }
+
+// WriteFileAtomic replaces the file at path with data, never leaving a
+// partially written file behind: data is synced to disk before the file is
+// renamed into place, and the rename is synced before returning.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+strings.TrimPrefix(filepath.Base(path), ".")+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	return SyncDir(filepath.Dir(path))
+}
+
+// SyncDir syncs the entries of the directory at path to disk, e.g. after
+// files were created or renamed in it.
+func SyncDir(path string) error {
+	// Directories can't be synced on Windows, where renames are durable.
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	d, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,160 @@
+// Package quotabs implements a blockstore enforcing a hard limit on the size
+// of the blocks it stores.
+package quotabs
+
+import (
+	"fmt"
+	"sync"
+
+	humanize "github.com/dustin/go-humanize"
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/pkg/errors"
+)
+
+// ErrRepoFull is the cause of the errors returned when storing blocks would
+// take the repo over its storage limit.
+var ErrRepoFull = errors.New("repo full")
+
+// RepoFullError is returned when storing blocks would take the repo over its
+// storage limit. Its cause is ErrRepoFull.
+type RepoFullError struct {
+	// Size is the size of the rejected blocks.
+	Size  uint64
+	Usage uint64
+	Max   uint64
+}
+
+func (e *RepoFullError) Error() string {
+	return fmt.Sprintf("%s: storing %s would exceed the storage limit of %s (%s used), run 'ipfs repo gc' or raise Datastore.StorageMax",
+		ErrRepoFull, humanize.Bytes(e.Size), humanize.Bytes(e.Max), humanize.Bytes(e.Usage))
+}
+
+// Cause returns ErrRepoFull.
+func (e *RepoFullError) Cause() error {
+	return ErrRepoFull
+}
+
+// IsRepoFull returns whether err was caused by ErrRepoFull. Errors wrapping
+// it must implement Cause, as the errors of github.com/pkg/errors do.
+func IsRepoFull(err error) bool {
+	return err != nil && errors.Cause(err) == ErrRepoFull
+}
+
+// Blockstore rejects the blocks that would take the repo over its storage
+// limit. Usage is tracked as blocks are added and removed, starting from the
+// usage of the repo when the blockstore is created.
+type Blockstore struct {
+	bstore.Blockstore
+
+	max uint64
+
+	// writeLk serializes the writes, so that whether a block is already
+	// stored doesn't change between checking it and accounting for it.
+	writeLk sync.Mutex
+
+	mu    sync.Mutex
+	usage uint64
+}
+
+// New returns a Blockstore storing blocks in bs as long as the repo uses less
+// than max bytes. usage is the number of bytes the repo uses already.
+func New(bs bstore.Blockstore, max, usage uint64) *Blockstore {
+	return &Blockstore{
+		Blockstore: bs,
+		max:        max,
+		usage:      usage,
+	}
+}
+
+// Usage returns the number of bytes the repo is estimated to use.
+func (bs *Blockstore) Usage() uint64 {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	return bs.usage
+}
+
+// reserve accounts for size more bytes, unless it takes the repo over its
+// limit.
+func (bs *Blockstore) reserve(size uint64) error {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	if bs.usage+size > bs.max {
+		return &RepoFullError{Size: size, Usage: bs.usage, Max: bs.max}
+	}
+	bs.usage += size
+	return nil
+}
+
+func (bs *Blockstore) release(size uint64) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	if size > bs.usage {
+		size = bs.usage
+	}
+	bs.usage -= size
+}
+
+// newSize returns the size of the blocks not stored yet.
+func (bs *Blockstore) newSize(blks []blocks.Block) (uint64, error) {
+	var size uint64
+	for _, b := range blks {
+		has, err := bs.Blockstore.Has(b.Cid())
+		if err != nil {
+			return 0, err
+		}
+		if !has {
+			size += uint64(len(b.RawData()))
+		}
+	}
+	return size, nil
+}
+
+func (bs *Blockstore) Put(b blocks.Block) error {
+	return bs.PutMany([]blocks.Block{b})
+}
+
+func (bs *Blockstore) PutMany(blks []blocks.Block) error {
+	bs.writeLk.Lock()
+	defer bs.writeLk.Unlock()
+
+	size, err := bs.newSize(blks)
+	if err != nil {
+		return err
+	}
+	if err := bs.reserve(size); err != nil {
+		return err
+	}
+
+	if len(blks) == 1 {
+		err = bs.Blockstore.Put(blks[0])
+	} else {
+		err = bs.Blockstore.PutMany(blks)
+	}
+	if err != nil {
+		bs.release(size)
+	}
+	return err
+}
+
+func (bs *Blockstore) DeleteBlock(c cid.Cid) error {
+	bs.writeLk.Lock()
+	defer bs.writeLk.Unlock()
+
+	size, err := bs.Blockstore.GetSize(c)
+	if err == bstore.ErrNotFound {
+		return bs.Blockstore.DeleteBlock(c)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := bs.Blockstore.DeleteBlock(c); err != nil {
+		return err
+	}
+	bs.release(uint64(size))
+	return nil
+}
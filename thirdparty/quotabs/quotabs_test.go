@@ -0,0 +1,93 @@
+package quotabs
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	blocks "github.com/ipfs/go-block-format"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/pkg/errors"
+)
+
+func newBlock(size int, seed byte) blocks.Block {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = seed
+	}
+	return blocks.NewBlock(data)
+}
+
+func TestQuota(t *testing.T) {
+	base := bstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	bs := New(base, 1000, 100)
+
+	a := newBlock(500, 'a')
+	if err := bs.Put(a); err != nil {
+		t.Fatal(err)
+	}
+	if bs.Usage() != 600 {
+		t.Fatalf("expected a usage of 600, got %d", bs.Usage())
+	}
+
+	// Storing a block again takes no space.
+	if err := bs.Put(a); err != nil {
+		t.Fatal(err)
+	}
+	if bs.Usage() != 600 {
+		t.Fatalf("expected a usage of 600, got %d", bs.Usage())
+	}
+
+	b := newBlock(500, 'b')
+	err := bs.PutMany([]blocks.Block{newBlock(100, 'c'), b})
+	if _, ok := err.(*RepoFullError); !ok {
+		t.Fatalf("expected a RepoFullError, got %v", err)
+	}
+	if !IsRepoFull(errors.Wrap(err, "adding file")) {
+		t.Error("wrapped repo full error not recognized")
+	}
+	if IsRepoFull(fmt.Errorf("adding file: %s", ErrRepoFull)) {
+		t.Error("error merely mentioning a full repo recognized")
+	}
+	if has, _ := base.Has(b.Cid()); has {
+		t.Fatal("rejected block was stored")
+	}
+	if bs.Usage() != 600 {
+		t.Fatalf("expected a usage of 600, got %d", bs.Usage())
+	}
+
+	if err := bs.DeleteBlock(a.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	if bs.Usage() != 100 {
+		t.Fatalf("expected a usage of 100, got %d", bs.Usage())
+	}
+	if err := bs.Put(b); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestQuotaConcurrentPuts(t *testing.T) {
+	base := bstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	bs := New(base, 10000, 0)
+
+	a := newBlock(500, 'a')
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := bs.Put(a); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// The block is accounted for once, however many times it was put.
+	if bs.Usage() != 500 {
+		t.Fatalf("expected a usage of 500, got %d", bs.Usage())
+	}
+}
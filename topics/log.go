@@ -0,0 +1,245 @@
+package topics
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	ds "github.com/ipfs/go-datastore"
+	dsquery "github.com/ipfs/go-datastore/query"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	floodsub "github.com/libp2p/go-libp2p-pubsub"
+	base32 "github.com/whyrusleeping/base32"
+)
+
+const logPrefix = "/pubsub-log/"
+
+// LogConfig enables the message log of a topic. The messages received on the
+// topic are persisted in the datastore, so that subscribers can replay them.
+// The oldest messages are dropped once the log is over one of the limits.
+type LogConfig struct {
+	// MaxMessages is the maximum number of messages kept.
+	MaxMessages int `json:",omitempty"`
+	// MaxAge is how long messages are kept, as a duration such as "24h".
+	MaxAge string `json:",omitempty"`
+}
+
+func (c *LogConfig) maxAge() (time.Duration, error) {
+	if c.MaxAge == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(c.MaxAge)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid log max age %q", c.MaxAge)
+	}
+	return d, nil
+}
+
+// Entry is a message recorded in the log of a topic.
+type Entry struct {
+	// Seq is the position of the message in the log, it is specific to
+	// this node.
+	Seq      uint64
+	Received time.Time
+
+	From      []byte
+	Data      []byte
+	Seqno     []byte
+	TopicIDs  []string
+	Signature []byte `json:",omitempty"`
+	Key       []byte `json:",omitempty"`
+}
+
+// ID identifies the message across peers, as the pubsub router does.
+func (e *Entry) ID() string {
+	return string(e.From) + string(e.Seqno)
+}
+
+// Message returns the logged message.
+func (e *Entry) Message() SignedMessage {
+	return entryMessage{e}
+}
+
+type entryMessage struct {
+	e *Entry
+}
+
+func (m entryMessage) From() peer.ID     { return peer.ID(m.e.From) }
+func (m entryMessage) Data() []byte      { return m.e.Data }
+func (m entryMessage) Seq() []byte       { return m.e.Seqno }
+func (m entryMessage) Topics() []string  { return m.e.TopicIDs }
+func (m entryMessage) Signature() []byte { return m.e.Signature }
+func (m entryMessage) Key() []byte       { return m.e.Key }
+
+// Since selects the entries of a log from a position or a time.
+type Since struct {
+	Seq  uint64
+	Time time.Time
+}
+
+// ParseSince parses a log position, a time in RFC 3339 format, or a duration
+// before now such as "1h".
+func ParseSince(s string) (Since, error) {
+	if seq, err := strconv.ParseUint(s, 10, 64); err == nil {
+		return Since{Seq: seq}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return Since{Time: time.Now().Add(-d)}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return Since{Time: t}, nil
+	}
+	return Since{}, fmt.Errorf("invalid log position %q, expected a sequence number, a time or a duration", s)
+}
+
+func (s Since) includes(e *Entry) bool {
+	return e.Seq >= s.Seq && !e.Received.Before(s.Time)
+}
+
+func logKeyPrefix(topic string) string {
+	return logPrefix + base32.RawStdEncoding.EncodeToString([]byte(topic)) + "/"
+}
+
+func logKey(topic string, seq uint64) ds.Key {
+	return ds.NewKey(fmt.Sprintf("%s%020d", logKeyPrefix(topic), seq))
+}
+
+// ReadLog returns the entries of the log of topic selected by since, oldest
+// first.
+func ReadLog(dstore ds.Datastore, topic string, since Since) ([]Entry, error) {
+	prefix := logKeyPrefix(topic)
+	results, err := dstore.Query(dsquery.Query{Prefix: prefix})
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	var entries []Entry
+	for result := range results.Next() {
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if !strings.HasPrefix(result.Key, prefix) {
+			continue
+		}
+
+		var e Entry
+		if err := json.Unmarshal(result.Value, &e); err != nil {
+			log.Errorf("pubsub log entry invalid: %s", result.Key)
+			continue
+		}
+		if since.includes(&e) {
+			entries = append(entries, e)
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Seq < entries[j].Seq })
+	return entries, nil
+}
+
+// topicLog appends the messages of a topic to its log, and prunes it.
+type topicLog struct {
+	dstore ds.Datastore
+	topic  string
+
+	maxMessages int
+	maxAge      time.Duration
+
+	mu sync.Mutex
+	// first is the position of the oldest entry, next the position of the
+	// next one. The log is empty when they are equal.
+	first, next uint64
+}
+
+func openLog(dstore ds.Datastore, topic string, cfg *LogConfig) (*topicLog, error) {
+	maxAge, err := cfg.maxAge()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.MaxMessages < 0 {
+		return nil, fmt.Errorf("invalid log max messages %d", cfg.MaxMessages)
+	}
+
+	entries, err := ReadLog(dstore, topic, Since{})
+	if err != nil {
+		return nil, err
+	}
+
+	l := &topicLog{
+		dstore:      dstore,
+		topic:       topic,
+		maxMessages: cfg.MaxMessages,
+		maxAge:      maxAge,
+	}
+	if len(entries) > 0 {
+		l.first = entries[0].Seq
+		l.next = entries[len(entries)-1].Seq + 1
+	}
+	return l, l.prune(time.Now())
+}
+
+func (l *topicLog) append(msg *floodsub.Message, received time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e := Entry{
+		Seq:       l.next,
+		Received:  received,
+		From:      msg.From,
+		Data:      msg.Data,
+		Seqno:     msg.Seqno,
+		TopicIDs:  msg.TopicIDs,
+		Signature: msg.Signature,
+		Key:       msg.Key,
+	}
+	data, err := json.Marshal(&e)
+	if err != nil {
+		return err
+	}
+	if err := l.dstore.Put(logKey(l.topic, e.Seq), data); err != nil {
+		return err
+	}
+	l.next++
+
+	return l.pruneLocked(received)
+}
+
+func (l *topicLog) prune(now time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.pruneLocked(now)
+}
+
+// pruneLocked drops the entries over the limits, oldest first.
+func (l *topicLog) pruneLocked(now time.Time) error {
+	for l.first < l.next {
+		key := logKey(l.topic, l.first)
+
+		drop := l.maxMessages > 0 && l.next-l.first > uint64(l.maxMessages)
+		if !drop && l.maxAge > 0 {
+			data, err := l.dstore.Get(key)
+			switch err {
+			case nil:
+				var e Entry
+				drop = json.Unmarshal(data, &e) != nil || now.Sub(e.Received) > l.maxAge
+			case ds.ErrNotFound:
+				drop = true
+			default:
+				return err
+			}
+		}
+		if !drop {
+			return nil
+		}
+
+		if err := l.dstore.Delete(key); err != nil && err != ds.ErrNotFound {
+			return err
+		}
+		l.first++
+	}
+	return nil
+}
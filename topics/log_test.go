@@ -0,0 +1,124 @@
+package topics
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	floodsub "github.com/libp2p/go-libp2p-pubsub"
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+)
+
+func testMsg(i int) *floodsub.Message {
+	return &floodsub.Message{Message: &pb.Message{
+		From:     []byte("peer"),
+		Data:     []byte(fmt.Sprintf("message %d", i)),
+		Seqno:    []byte{byte(i)},
+		TopicIDs: []string{"test"},
+	}}
+}
+
+func checkLog(t *testing.T, dstore ds.Datastore, since Since, expected ...uint64) {
+	t.Helper()
+
+	entries, err := ReadLog(dstore, "test", since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("expected %d entries, got %d", len(expected), len(entries))
+	}
+	for i, e := range entries {
+		if e.Seq != expected[i] {
+			t.Fatalf("expected entry %d, got %d", expected[i], e.Seq)
+		}
+		if string(e.Data) != fmt.Sprintf("message %d", e.Seq) {
+			t.Fatalf("entry %d holds %q", e.Seq, e.Data)
+		}
+	}
+}
+
+func TestLogMaxMessages(t *testing.T) {
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+
+	l, err := openLog(dstore, "test", &LogConfig{MaxMessages: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		if err := l.append(testMsg(i), now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkLog(t, dstore, Since{}, 2, 3, 4)
+	checkLog(t, dstore, Since{Seq: 4}, 4)
+
+	// The log goes on where it stopped once reopened.
+	l, err = openLog(dstore, "test", &LogConfig{MaxMessages: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.append(testMsg(5), now); err != nil {
+		t.Fatal(err)
+	}
+	checkLog(t, dstore, Since{}, 3, 4, 5)
+
+	// Other topics have their own log.
+	entries, err := ReadLog(dstore, "other", Since{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Fatal("unexpected entries in the log of another topic")
+	}
+}
+
+func TestLogMaxAge(t *testing.T) {
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+
+	l, err := openLog(dstore, "test", &LogConfig{MaxAge: "1h"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now().Add(-3 * time.Hour)
+	for i := 0; i < 3; i++ {
+		if err := l.append(testMsg(i), start.Add(time.Duration(i)*time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkLog(t, dstore, Since{}, 1, 2)
+	checkLog(t, dstore, Since{Time: start.Add(90 * time.Minute)}, 2)
+
+	if err := l.prune(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	checkLog(t, dstore, Since{})
+
+	if _, err := openLog(dstore, "test", &LogConfig{MaxAge: "forever"}); err == nil {
+		t.Fatal("accepted an invalid max age")
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	since, err := ParseSince("42")
+	if err != nil || since.Seq != 42 || !since.Time.IsZero() {
+		t.Fatalf("unexpected position %v, %v", since, err)
+	}
+
+	since, err = ParseSince("1h")
+	if err != nil || since.Seq != 0 || time.Since(since.Time) < time.Hour {
+		t.Fatalf("unexpected position %v, %v", since, err)
+	}
+
+	since, err = ParseSince("2019-07-01T10:00:00Z")
+	if err != nil || !since.Time.Equal(time.Date(2019, 7, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected position %v, %v", since, err)
+	}
+
+	if _, err := ParseSince("yesterday"); err == nil {
+		t.Fatal("accepted an invalid position")
+	}
+}
@@ -0,0 +1,69 @@
+package topics
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+const testSchema = `{
+	"type": "object",
+	"required": ["name", "version"],
+	"additionalProperties": false,
+	"properties": {
+		"name": {"type": "string", "minLength": 1, "pattern": "^[a-z-]+$"},
+		"version": {"type": "integer", "minimum": 1},
+		"tags": {"type": "array", "maxItems": 2, "items": {"enum": ["stable", "beta"]}},
+		"note": {"type": ["string", "null"]}
+	}
+}`
+
+func TestSchema(t *testing.T) {
+	var s Schema
+	if err := json.Unmarshal([]byte(testSchema), &s); err != nil {
+		t.Fatal(err)
+	}
+
+	valid := []string{
+		`{"name": "go-ipfs", "version": 1}`,
+		`{"name": "go-ipfs", "version": 2, "tags": ["stable"], "note": null}`,
+		`{"name": "go-ipfs", "version": 3, "note": "hello"}`,
+	}
+	for _, doc := range valid {
+		if err := s.ValidateJSON([]byte(doc)); err != nil {
+			t.Errorf("%s: unexpected error: %s", doc, err)
+		}
+	}
+
+	invalid := []string{
+		`not json`,
+		`[]`,
+		`{"name": "go-ipfs"}`,
+		`{"name": "", "version": 1}`,
+		`{"name": "Go IPFS", "version": 1}`,
+		`{"name": "go-ipfs", "version": 1.5}`,
+		`{"name": "go-ipfs", "version": 0}`,
+		`{"name": "go-ipfs", "version": 1, "tags": ["alpha"]}`,
+		`{"name": "go-ipfs", "version": 1, "tags": ["beta", "beta", "beta"]}`,
+		`{"name": "go-ipfs", "version": 1, "note": 3}`,
+		`{"name": "go-ipfs", "version": 1, "extra": true}`,
+	}
+	for _, doc := range invalid {
+		if err := s.ValidateJSON([]byte(doc)); err == nil {
+			t.Errorf("%s: expected an error", doc)
+		}
+	}
+}
+
+func TestSchemaInvalid(t *testing.T) {
+	for _, schema := range []string{
+		`{"type": "text"}`,
+		`{"type": 3}`,
+		`{"pattern": "("}`,
+		`{"properties": {"a": {"type": ["int"]}}}`,
+	} {
+		var s Schema
+		if err := json.Unmarshal([]byte(schema), &s); err == nil {
+			t.Errorf("%s: expected an error", schema)
+		}
+	}
+}
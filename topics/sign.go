@@ -0,0 +1,83 @@
+package topics
+
+import (
+	"errors"
+	"fmt"
+
+	ci "github.com/libp2p/go-libp2p-core/crypto"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+)
+
+// signPrefix is prepended to messages before signing them, as done by the
+// pubsub router.
+const signPrefix = "libp2p-pubsub:"
+
+// ErrUnsigned is returned when verifying a message without signature.
+var ErrUnsigned = errors.New("message is not signed")
+
+// SignedMessage is a message carrying the signature of its author.
+type SignedMessage interface {
+	From() peer.ID
+	Data() []byte
+	Seq() []byte
+	Topics() []string
+
+	Signature() []byte
+	Key() []byte
+}
+
+// Verify checks that msg is signed by its author.
+func Verify(msg SignedMessage) error {
+	return verify(&pb.Message{
+		From:      []byte(msg.From()),
+		Data:      msg.Data(),
+		Seqno:     msg.Seq(),
+		TopicIDs:  msg.Topics(),
+		Signature: msg.Signature(),
+		Key:       msg.Key(),
+	})
+}
+
+func verify(m *pb.Message) error {
+	if len(m.Signature) == 0 {
+		return ErrUnsigned
+	}
+
+	author, err := peer.IDFromBytes(m.From)
+	if err != nil {
+		return fmt.Errorf("invalid author: %s", err)
+	}
+
+	var pub ci.PubKey
+	if m.Key == nil {
+		// The key must be inlined in the peer ID.
+		if pub, err = author.ExtractPublicKey(); err != nil || pub == nil {
+			return fmt.Errorf("cannot extract the key of %s", author)
+		}
+	} else {
+		if pub, err = ci.UnmarshalPublicKey(m.Key); err != nil {
+			return fmt.Errorf("invalid signing key: %s", err)
+		}
+		if !author.MatchesPublicKey(pub) {
+			return fmt.Errorf("signing key doesn't match author %s", author)
+		}
+	}
+
+	unsigned := *m
+	unsigned.Signature = nil
+	unsigned.Key = nil
+	data, err := unsigned.Marshal()
+	if err != nil {
+		return err
+	}
+
+	ok, err := pub.Verify(append([]byte(signPrefix), data...), m.Signature)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+// Package topics implements per-topic pubsub settings: the validators
+// registered with the pubsub router for the messages of a topic, and the
+// durable logs of their messages.
+package topics
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	ds "github.com/ipfs/go-datastore"
+	logging "github.com/ipfs/go-log"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	floodsub "github.com/libp2p/go-libp2p-pubsub"
+)
+
+var log = logging.Logger("pubsub-topics")
+
+// logPruneInterval is how often logs with a maximum age are pruned.
+var logPruneInterval = time.Minute
+
+// ConfigKey is the top-level config section holding the Config.
+const ConfigKey = "PubsubTopics"
+
+// Config maps topic names to their settings.
+type Config map[string]TopicConfig
+
+// TopicConfig declares the validators of a topic, and whether its messages
+// are logged. Messages failing the validators are neither delivered to local
+// subscribers nor forwarded to other peers.
+type TopicConfig struct {
+	// MaxMessageSize rejects messages carrying more bytes of data.
+	MaxMessageSize int `json:",omitempty"`
+
+	// Schema is a JSON schema the data of every message must conform to.
+	Schema *Schema `json:",omitempty"`
+
+	// Log enables the message log of the topic.
+	Log *LogConfig `json:",omitempty"`
+}
+
+// HasValidator returns whether messages of the topic need validation.
+func (c TopicConfig) HasValidator() bool {
+	return c.MaxMessageSize > 0 || c.Schema != nil
+}
+
+// Validate checks a message against the validators of the topic.
+func (c TopicConfig) Validate(data []byte) error {
+	if c.MaxMessageSize > 0 && len(data) > c.MaxMessageSize {
+		return fmt.Errorf("message of %d bytes exceeds the maximum size of %d bytes", len(data), c.MaxMessageSize)
+	}
+	if c.Schema != nil {
+		if err := c.Schema.ValidateJSON(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c TopicConfig) validator(topic string) floodsub.Validator {
+	return func(_ context.Context, src peer.ID, msg *floodsub.Message) bool {
+		if err := c.Validate(msg.GetData()); err != nil {
+			log.Debugf("dropping message on %s from %s: %s", topic, msg.GetFrom(), err)
+			return false
+		}
+		return true
+	}
+}
+
+// Manager registers the validators of topics with the pubsub router while
+// they have subscribers, and records the messages of logged topics.
+type Manager struct {
+	ctx    context.Context
+	ps     *floodsub.PubSub
+	dstore ds.Datastore
+
+	mu    sync.Mutex
+	users map[string]int
+
+	logMu sync.Mutex
+	logs  map[string]*topicLog
+}
+
+// NewManager returns a Manager registering validators with ps and storing
+// logs in dstore. The logs are recorded until ctx is done.
+func NewManager(ctx context.Context, ps *floodsub.PubSub, dstore ds.Datastore) *Manager {
+	return &Manager{
+		ctx:    ctx,
+		ps:     ps,
+		dstore: dstore,
+		users:  make(map[string]int),
+		logs:   make(map[string]*topicLog),
+	}
+}
+
+// Acquire registers the validator declared by cfg for topic, unless it is
+// already registered. It must be called before subscribing to the topic, the
+// returned function must be called once unsubscribed. The validator is
+// unregistered when the last subscriber is gone.
+func (m *Manager) Acquire(topic string, cfg TopicConfig) (func(), error) {
+	if !cfg.HasValidator() {
+		return func() {}, nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.users[topic] == 0 {
+		if err := m.ps.RegisterTopicValidator(topic, cfg.validator(topic)); err != nil {
+			return nil, fmt.Errorf("registering validator for topic %s: %s", topic, err)
+		}
+	}
+	m.users[topic]++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { m.release(topic) })
+	}, nil
+}
+
+func (m *Manager) release(topic string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.users[topic]--
+	if m.users[topic] > 0 {
+		return
+	}
+	delete(m.users, topic)
+	if err := m.ps.UnregisterTopicValidator(topic); err != nil {
+		log.Warningf("unregistering validator for topic %s: %s", topic, err)
+	}
+}
+
+// StartLog starts recording the messages of topic in its log, as declared by
+// cfg, unless they are already recorded. The node stays subscribed to the
+// topic until the manager is done.
+func (m *Manager) StartLog(topic string, cfg TopicConfig) error {
+	if cfg.Log == nil {
+		return fmt.Errorf("topic %s has no message log", topic)
+	}
+
+	m.logMu.Lock()
+	defer m.logMu.Unlock()
+
+	if _, ok := m.logs[topic]; ok {
+		return nil
+	}
+
+	l, err := openLog(m.dstore, topic, cfg.Log)
+	if err != nil {
+		return fmt.Errorf("opening log of topic %s: %s", topic, err)
+	}
+
+	release, err := m.Acquire(topic, cfg)
+	if err != nil {
+		return err
+	}
+	sub, err := m.ps.Subscribe(topic)
+	if err != nil {
+		release()
+		return err
+	}
+	m.logs[topic] = l
+
+	go func() {
+		defer release()
+		defer sub.Cancel()
+
+		for {
+			msg, err := sub.Next(m.ctx)
+			if err != nil {
+				return
+			}
+			if err := l.append(msg, time.Now()); err != nil {
+				log.Errorf("logging message on %s: %s", topic, err)
+			}
+		}
+	}()
+
+	if l.maxAge > 0 {
+		go func() {
+			ticker := time.NewTicker(logPruneInterval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case now := <-ticker.C:
+					if err := l.prune(now); err != nil {
+						log.Errorf("pruning log of %s: %s", topic, err)
+					}
+				case <-m.ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	return nil
+}
+
+// Logging returns whether the messages of topic are being logged.
+func (m *Manager) Logging(topic string) bool {
+	m.logMu.Lock()
+	defer m.logMu.Unlock()
+	_, ok := m.logs[topic]
+	return ok
+}
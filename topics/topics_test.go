@@ -0,0 +1,110 @@
+package topics
+
+import (
+	"encoding/json"
+	"testing"
+
+	ci "github.com/libp2p/go-libp2p-core/crypto"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+)
+
+func TestTopicConfigValidate(t *testing.T) {
+	var cfg TopicConfig
+	if cfg.HasValidator() {
+		t.Fatal("empty config has a validator")
+	}
+
+	err := json.Unmarshal([]byte(`{"MaxMessageSize": 20, "Schema": {"type": "object"}}`), &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.HasValidator() {
+		t.Fatal("config has no validator")
+	}
+
+	if err := cfg.Validate([]byte(`{"a": 1}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Validate([]byte(`{"a": "some long value"}`)); err == nil {
+		t.Fatal("accepted a message over the maximum size")
+	}
+	if err := cfg.Validate([]byte(`[1]`)); err == nil {
+		t.Fatal("accepted a message not matching the schema")
+	}
+}
+
+type testMessage struct {
+	pb.Message
+}
+
+func (m *testMessage) From() peer.ID     { return peer.ID(m.Message.From) }
+func (m *testMessage) Data() []byte      { return m.Message.Data }
+func (m *testMessage) Seq() []byte       { return m.Message.Seqno }
+func (m *testMessage) Topics() []string  { return m.Message.TopicIDs }
+func (m *testMessage) Signature() []byte { return m.Message.Signature }
+func (m *testMessage) Key() []byte       { return m.Message.Key }
+
+func signedMessage(t *testing.T, sk ci.PrivKey, withKey bool) *testMessage {
+	id, err := peer.IDFromPrivateKey(sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &testMessage{pb.Message{
+		From:     []byte(id),
+		Data:     []byte("hello"),
+		Seqno:    []byte{0, 0, 0, 1},
+		TopicIDs: []string{"test"},
+	}}
+	data, err := m.Message.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Message.Signature, err = sk.Sign(append([]byte(signPrefix), data...)); err != nil {
+		t.Fatal(err)
+	}
+	if withKey {
+		if m.Message.Key, err = ci.MarshalPublicKey(sk.GetPublic()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return m
+}
+
+func TestVerify(t *testing.T) {
+	rsaKey, _, err := ci.GenerateKeyPair(ci.RSA, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edKey, _, err := ci.GenerateKeyPair(ci.Ed25519, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// RSA keys are attached to the message, ed25519 keys are inlined in the
+	// peer ID.
+	for _, m := range []*testMessage{signedMessage(t, rsaKey, true), signedMessage(t, edKey, false)} {
+		if err := Verify(m); err != nil {
+			t.Fatal(err)
+		}
+
+		m.Message.Data = []byte("tampered")
+		if err := Verify(m); err == nil {
+			t.Fatal("verified a tampered message")
+		}
+
+		m.Message.Signature = nil
+		if err := Verify(m); err != ErrUnsigned {
+			t.Fatalf("expected ErrUnsigned, got %v", err)
+		}
+	}
+
+	// The attached key must belong to the author.
+	m := signedMessage(t, rsaKey, true)
+	other := signedMessage(t, edKey, false)
+	m.Message.From = other.Message.From
+	if err := Verify(m); err == nil {
+		t.Fatal("verified a message signed by another key")
+	}
+}